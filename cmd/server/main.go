@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/MCCodingMan/TraceBuddy/config"
 	adapterHttp "github.com/MCCodingMan/TraceBuddy/pkg/adapters/http"
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/logger"
 	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/storage"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,26 +26,45 @@ func main() {
 	// 加载配置
 	cfg := config.Load()
 
-    repo, err := storage.NewPostgresRepository(cfg.DatabaseDSN)
-    if err != nil {
-        log.Fatalf("Failed to connect to Postgres: %v", err)
-    }
+	// tracebuddy migrate up|down|status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	repo, err := openRepository(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open %s database: %v", cfg.DatabaseDriver, err)
+	}
+
+	// 启动 logs 表的分区维护，随服务一起停止
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if cfg.PartitionInterval != "" {
+		if err := startPartitionManager(ctx, cfg, repo); err != nil {
+			log.Fatalf("Invalid partition config: %v", err)
+		}
+	}
 
 	// 初始化 Redis
 	redisRepo := storage.NewRedisRepository(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
 
 	// 初始化异步日志记录器
-	// 我们使用 ES repo 进行异步日志记录
-	// asyncLogger := logger.NewAsyncLogger(esRepo, 1000)
-	// defer asyncLogger.Close()
+	policy, err := logger.ParseOverflowPolicy(cfg.LogOverflowPolicy)
+	if err != nil {
+		log.Fatalf("Invalid LOG_OVERFLOW_POLICY: %v", err)
+	}
+	asyncLogger := logger.NewAsyncLogger(repo, cfg.LogBufferSize, logger.WithOverflowPolicy(policy))
 
 	// 初始化 Gin
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
-    r := gin.New()
-    r.Use(gin.Recovery())
-    r.Use(adapterHttp.CORSMiddleware())
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(adapterHttp.CORSMiddleware())
 
 	// 注册中间件
 	// logMiddleware := adapterHttp.NewLogMiddleware(asyncLogger)
@@ -46,12 +75,15 @@ func main() {
 	// 这里为了演示，我们假设所有 /api 路由都需要认证
 	// 但为了方便测试，我们只在 Export 接口上强制认证，或者在路由组中添加
 
-    logHandler := adapterHttp.NewLogHandler(repo, redisRepo)
-    authHandler := adapterHttp.NewAuthHandler(repo, jwtSecret)
+	logHandler := adapterHttp.NewLogHandler(repo, redisRepo)
+	authHandler := adapterHttp.NewAuthHandler(repo, jwtSecret)
 
 	// 注册登录接口 (不需要认证)
 	r.POST("/api/auth/login", authHandler.Login)
 
+	// 健康检查，附带日志记录器的入队、写入、失败和丢弃计数
+	r.GET("/health", adapterHttp.NewHealthHandler(asyncLogger).Health)
+
 	// 使用带认证的路由组
 	api := r.Group("/api")
 	api.Use(adapterHttp.AuthMiddleware(jwtSecret))
@@ -61,11 +93,127 @@ func main() {
 		api.POST("/logs/search", logHandler.SearchLogs)
 		api.GET("/logs/search", logHandler.SearchLogs)
 		api.POST("/logs/export", logHandler.ExportLogs)
+		api.GET("/traces/:id", logHandler.GetTrace)
 	}
 
-	// 启动服务器
-	log.Printf("Starting server on port %s", cfg.ServerPort)
-	if err := r.Run(":" + cfg.ServerPort); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// 延迟和错误率统计，与搜索接口使用相同的认证
+	stats := r.Group("/api/v1", adapterHttp.AuthMiddleware(jwtSecret), adapterHttp.AuditMiddleware())
+	{
+		stats.GET("/stats", logHandler.Stats)
+	}
+
+	// 按保留规则定期清理过期日志，管理员可以预览或手动触发
+	if cfg.RetentionRulesFile != "" {
+		job, err := newRetentionJob(cfg, repo)
+		if err != nil {
+			log.Fatalf("Invalid retention config: %v", err)
+		}
+		go job.Run(ctx)
+
+		retentionHandler := adapterHttp.NewRetentionHandler(job)
+		retention := api.Group("/retention", adapterHttp.RequireRole("admin"))
+		{
+			retention.GET("", retentionHandler.GetPolicy)
+			retention.POST("/preview", retentionHandler.Preview)
+			retention.POST("/purge", retentionHandler.Purge)
+		}
+	}
+
+	// 远程采集端使用 API Key 批量上报日志
+	ingestHandler := adapterHttp.NewIngestHandler(asyncLogger)
+	v1 := r.Group("/api/v1")
+	v1.Use(adapterHttp.APIKeyMiddleware(redisRepo))
+	{
+		v1.POST("/ingest", ingestHandler.Ingest)
+	}
+
+	// 启动服务器，收到 SIGINT/SIGTERM 后优雅关闭
+	srv := &http.Server{Addr: ":" + cfg.ServerPort, Handler: r}
+	go func() {
+		log.Printf("Starting server on port %s", cfg.ServerPort)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down server...")
+
+	// 先停止接收请求，再写完缓冲区中的日志，两者共用同一个期限
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown: %v", err)
+	}
+	unflushed, err := asyncLogger.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Printf("Logger shutdown: %v", err)
+	}
+	if unflushed > 0 {
+		log.Printf("%d log entries were not written to storage during shutdown", unflushed)
+	}
+	log.Println("Server stopped")
+}
+
+// repository 服务端使用的存储：日志读写、过期清理和用户查询
+type repository interface {
+	ports.LogRepository
+	ports.LogPurger
+	ports.UserRepository
+}
+
+// openRepository 按 DB_DRIVER 选择 PostgreSQL 或 SQLite
+func openRepository(cfg *config.Config) (repository, error) {
+	switch cfg.DatabaseDriver {
+	case "pgx", "postgres":
+		repo, err := storage.NewPostgresRepository(cfg.DatabaseDSN)
+		if err != nil {
+			return nil, err
+		}
+		return repo, nil
+	case "sqlite":
+		repo, err := storage.NewSQLiteRepository(cfg.DatabaseDSN)
+		if err != nil {
+			return nil, err
+		}
+		return repo, nil
+	}
+	return nil, fmt.Errorf("unsupported DB_DRIVER %q (want pgx or sqlite)", cfg.DatabaseDriver)
+}
+
+// startPartitionManager 在后台维护 logs 表的时间分区，只支持 PostgreSQL
+func startPartitionManager(ctx context.Context, cfg *config.Config, repo repository) error {
+	pg, ok := repo.(*storage.PostgresRepository)
+	if !ok {
+		return fmt.Errorf("LOG_PARTITION_INTERVAL requires DB_DRIVER=pgx")
+	}
+	interval, err := storage.ParsePartitionInterval(cfg.PartitionInterval)
+	if err != nil {
+		return err
+	}
+	manager, err := storage.NewPartitionManager(pg, storage.PartitionConfig{
+		Interval:  interval,
+		Premake:   cfg.PartitionPremake,
+		Retention: time.Duration(cfg.PartitionRetentionDays) * 24 * time.Hour,
+		Detach:    cfg.PartitionDetach,
+	})
+	if err != nil {
+		return err
+	}
+	go manager.Run(ctx)
+	return nil
+}
+
+// newRetentionJob 从 RETENTION_RULES_FILE 加载保留规则并创建清理任务
+func newRetentionJob(cfg *config.Config, repo repository) (*storage.RetentionJob, error) {
+	policy, err := storage.LoadRetentionPolicy(cfg.RetentionRulesFile)
+	if err != nil {
+		return nil, err
 	}
+	return storage.NewRetentionJob(repo, storage.RetentionConfig{
+		Policy:    policy,
+		BatchSize: cfg.RetentionBatchSize,
+		Interval:  time.Duration(cfg.RetentionIntervalMinutes) * time.Minute,
+	})
 }
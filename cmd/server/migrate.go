@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/config"
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/storage"
+)
+
+const migrateUsage = `usage: tracebuddy migrate up|down [n]|status
+
+  up        执行所有未执行的迁移
+  down [n]  回滚最近执行的 n 个迁移，默认 1
+  status    查看迁移的执行状态`
+
+// runMigrate 执行 migrate 子命令，使用与服务相同的 DB_DRIVER / DB_DSN
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 || (args[0] != "up" && args[0] != "down" && args[0] != "status") {
+		return errors.New(migrateUsage)
+	}
+	m, err := storage.OpenMigrator(cfg.DatabaseDriver, cfg.DatabaseDSN)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+		}
+		rolled, err := m.Down(ctx, steps)
+		for _, mig := range rolled {
+			fmt.Printf("rolled back %04d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+		for _, s := range statuses {
+			state := "applied " + s.AppliedAt.Local().Format(time.RFC3339)
+			switch {
+			case s.Unknown:
+				state = "unknown (applied by a newer version)"
+			case s.Pending:
+				state = "pending"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, state)
+		}
+		return w.Flush()
+	}
+	return nil
+}
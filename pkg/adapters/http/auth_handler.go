@@ -30,24 +30,24 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		Password string `json:"password" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		abortError(c, http.StatusBadRequest, CodeBadRequest, err.Error())
 		return
 	}
 
 	// 从数据库查找用户
     user, err := h.userRepo.FindUserByUsername(c.Request.Context(), req.Username)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Service unavailable"})
+		abortError(c, http.StatusInternalServerError, CodeUnavailable, "Service unavailable")
 		return
 	}
 	if user == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		abortError(c, http.StatusUnauthorized, CodeUnauthorized, "Invalid credentials")
 		return
 	}
 
 	// 验证密码
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		abortError(c, http.StatusUnauthorized, CodeUnauthorized, "Invalid credentials")
 		return
 	}
 
@@ -60,7 +60,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	tokenString, err := token.SignedString(h.jwtSecret)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		abortError(c, http.StatusInternalServerError, CodeInternal, "Failed to generate token")
 		return
 	}
 
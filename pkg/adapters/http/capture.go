@@ -0,0 +1,162 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// skipContentType 判断该内容类型的 Body 是否应完全跳过捕获
+func (o *options) skipContentType(contentType string) bool {
+	if contentType == "" {
+		return false
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, t := range o.skipContentTypes {
+		t = strings.ToLower(t)
+		if strings.HasSuffix(t, "/") {
+			if strings.HasPrefix(mediaType, t) {
+				return true
+			}
+		} else if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// requestBodyCapture 替换原始请求体：预读不超过上限的前缀用于记录，
+// 之后将前缀和剩余数据原样交给处理函数，请求体不会被整体缓冲第二次
+type requestBodyCapture struct {
+	src           io.ReadCloser
+	prefix        []byte
+	off           int
+	err           error // 预读阶段遇到的错误，前缀读完后返回给调用方
+	extra         int64 // 前缀之后从 src 读取的字节数
+	eof           bool
+	limit         int64
+	contentLength int64
+}
+
+func newRequestBodyCapture(src io.ReadCloser, limit, contentLength int64) *requestBodyCapture {
+	c := &requestBodyCapture{src: src, limit: limit, contentLength: contentLength}
+	c.prefix, c.err = io.ReadAll(io.LimitReader(src, limit+1))
+	if c.err == nil && int64(len(c.prefix)) <= limit {
+		c.err = io.EOF
+		c.eof = true
+	}
+	return c
+}
+
+func (c *requestBodyCapture) Read(p []byte) (int, error) {
+	if c.off < len(c.prefix) {
+		n := copy(p, c.prefix[c.off:])
+		c.off += n
+		return n, nil
+	}
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.src.Read(p)
+	c.extra += int64(n)
+	if err == io.EOF {
+		c.eof = true
+	}
+	return n, err
+}
+
+func (c *requestBodyCapture) Close() error {
+	return c.src.Close()
+}
+
+// Captured 返回捕获到的 Body 前缀
+func (c *requestBodyCapture) Captured() []byte {
+	if int64(len(c.prefix)) > c.limit {
+		return c.prefix[:c.limit]
+	}
+	return c.prefix
+}
+
+// Size 返回请求体的原始字节数；处理函数未读完时优先使用 Content-Length
+func (c *requestBodyCapture) Size() int64 {
+	total := int64(len(c.prefix)) + c.extra
+	if !c.eof && c.contentLength > total {
+		return c.contentLength
+	}
+	return total
+}
+
+// Truncated 表示记录的 Body 是否只是原始请求体的前缀
+func (c *requestBodyCapture) Truncated() bool {
+	return c.Size() > c.limit
+}
+
+// limitedBuffer 只保留前 limit 个字节，同时统计写入的总字节数
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int64
+	total int64
+}
+
+func (b *limitedBuffer) Write(p []byte) {
+	b.total += int64(len(p))
+	if remain := b.limit - int64(b.buf.Len()); remain > 0 {
+		if int64(len(p)) > remain {
+			p = p[:remain]
+		}
+		b.buf.Write(p)
+	}
+}
+
+func (b *limitedBuffer) WriteString(s string) {
+	b.total += int64(len(s))
+	if remain := b.limit - int64(b.buf.Len()); remain > 0 {
+		if int64(len(s)) > remain {
+			s = s[:remain]
+		}
+		b.buf.WriteString(s)
+	}
+}
+
+// Truncated 表示缓冲区是否丢弃了超出上限的部分；未启用捕获时始终为 false
+func (b *limitedBuffer) Truncated() bool {
+	return b.limit > 0 && b.total > int64(b.buf.Len())
+}
+
+// bodyLogWriter 包装 gin.ResponseWriter，按上限捕获响应体
+type bodyLogWriter struct {
+	gin.ResponseWriter
+	body    limitedBuffer
+	opts    *options
+	decided bool
+}
+
+func newBodyLogWriter(w gin.ResponseWriter, opts *options) *bodyLogWriter {
+	return &bodyLogWriter{ResponseWriter: w, opts: opts}
+}
+
+// decide 在首次写入时根据 Content-Type 决定是否捕获响应体
+func (w *bodyLogWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	w.body.limit = w.opts.responseLimit(w.Header())
+}
+
+func (w *bodyLogWriter) Write(b []byte) (int, error) {
+	w.decide()
+	n, err := w.ResponseWriter.Write(b)
+	w.body.Write(b[:n])
+	return n, err
+}
+
+func (w *bodyLogWriter) WriteString(s string) (int, error) {
+	w.decide()
+	n, err := w.ResponseWriter.WriteString(s)
+	w.body.WriteString(s[:n])
+	return n, err
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRequestBodyCapture(t *testing.T) {
+	payload := strings.Repeat("a", 100)
+	c := newRequestBodyCapture(io.NopCloser(strings.NewReader(payload)), 10, int64(len(payload)))
+
+	got, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatalf("读取请求体失败: %v", err)
+	}
+	if string(got) != payload {
+		t.Errorf("处理函数应收到完整请求体，得到 %d 字节", len(got))
+	}
+	if string(c.Captured()) != payload[:10] {
+		t.Errorf("期望捕获前 10 个字节，得到 %q", c.Captured())
+	}
+	if c.Size() != 100 || !c.Truncated() {
+		t.Errorf("期望 Size=100 且 Truncated=true，得到 %d %v", c.Size(), c.Truncated())
+	}
+}
+
+func TestRequestBodyCaptureUnread(t *testing.T) {
+	c := newRequestBodyCapture(io.NopCloser(strings.NewReader("0123456789abc")), 4, 13)
+	if c.Size() != 13 || !c.Truncated() {
+		t.Errorf("处理函数未读取时应使用 Content-Length，得到 %d %v", c.Size(), c.Truncated())
+	}
+
+	small := newRequestBodyCapture(io.NopCloser(strings.NewReader("ok")), 4, -1)
+	if small.Size() != 2 || small.Truncated() {
+		t.Errorf("期望 Size=2 且未截断，得到 %d %v", small.Size(), small.Truncated())
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := limitedBuffer{limit: 5}
+	b.Write([]byte("abc"))
+	b.WriteString("defgh")
+	if b.buf.String() != "abcde" || b.total != 8 || !b.Truncated() {
+		t.Errorf("期望保留 abcde 且总数为 8，得到 %q %d", b.buf.String(), b.total)
+	}
+}
+
+func TestSkipContentType(t *testing.T) {
+	o := defaultOptions()
+	if !o.skipContentType("image/png") || !o.skipContentType("multipart/form-data; boundary=x") {
+		t.Error("期望跳过二进制和表单上传类型")
+	}
+	if o.skipContentType("application/json; charset=utf-8") {
+		t.Error("不应跳过 JSON")
+	}
+}
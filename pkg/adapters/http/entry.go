@@ -0,0 +1,77 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/utils"
+)
+
+// wrapRequestBody 按配置替换请求体，只预读捕获上限内的前缀；无需捕获时返回 nil
+func (o *options) wrapRequestBody(r *http.Request) *requestBodyCapture {
+	if r.Body == nil || r.Body == http.NoBody ||
+		o.maxRequestBody <= 0 || o.skipContentType(r.Header.Get("Content-Type")) {
+		return nil
+	}
+	body := newRequestBodyCapture(r.Body, o.maxRequestBody, r.ContentLength)
+	r.Body = body
+	return body
+}
+
+// responseLimit 根据响应的 Content-Type 返回响应体的捕获上限，0 表示不捕获
+func (o *options) responseLimit(header http.Header) int64 {
+	if o.maxResponseBody <= 0 || o.skipContentType(header.Get("Content-Type")) {
+		return 0
+	}
+	return o.maxResponseBody
+}
+
+// newEntry 根据请求和链路信息创建日志条目，响应部分由 setResponse 补充
+// Route 缺省为规范化后的路径，能取得路由模板的中间件再覆盖
+func (o *options) newEntry(kind, trackID string, span utils.TraceContext, start time.Time, r *http.Request, reqBody *requestBodyCapture) domain.LogEntry {
+	entry := domain.LogEntry{
+		ID:           utils.GenerateEntryID(),
+		TrackID:      trackID,
+		TraceID:      span.TraceID,
+		SpanID:       span.SpanID,
+		ParentSpanID: span.ParentSpanID,
+		Kind:         kind,
+		Timestamp:    start,
+		Route:        NormalizePath(r.URL.Path),
+		Request: domain.RequestInfo{
+			Method:      r.Method,
+			URL:         r.URL.String(),
+			Proto:       r.Proto,
+			Headers:     convertHeaders(r.Header),
+			QueryParams: convertQueryParams(r.URL.Query()),
+		},
+	}
+	if reqBody != nil {
+		entry.Request.Body = decodeBody(reqBody.Captured(), r.Header.Get("Content-Type"))
+		entry.Request.Size = reqBody.Size()
+		entry.Request.BodyTruncated = reqBody.Truncated()
+	} else if r.ContentLength > 0 {
+		entry.Request.Size = r.ContentLength
+	}
+	return entry
+}
+
+// setResponse 填充响应部分
+func (o *options) setResponse(entry *domain.LogEntry, status int, header http.Header, body *limitedBuffer) {
+	entry.Response = domain.ResponseInfo{
+		StatusCode:    status,
+		Headers:       convertHeaders(header),
+		Body:          decodeBody(body.buf.Bytes(), header.Get("Content-Type")),
+		Size:          body.total,
+		BodyTruncated: body.Truncated(),
+	}
+}
+
+// finish 计算耗时并脱敏，之后条目即可写入记录器
+func (o *options) finish(entry *domain.LogEntry) {
+	entry.DurationMs = time.Since(entry.Timestamp).Milliseconds()
+	if o.redactor != nil {
+		o.redactor.RedactEntry(entry)
+	}
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+	ql "github.com/MCCodingMan/TraceBuddy/pkg/core/query"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 错误响应的错误码，搜索参数的校验错误使用 ports 中定义的 Code* 常量
+const (
+	CodeInvalidJSON     = "invalid_json"      // 请求体不是合法的 JSON 或类型不符
+	CodeUnknownField    = "unknown_field"     // 请求中有未知的字段或参数
+	CodeBadRequest      = "bad_request"       // 其他无效的请求
+	CodeUnauthorized    = "unauthorized"      // 未认证或凭据无效
+	CodeForbidden       = "forbidden"         // 权限不足
+	CodeNotFound        = "not_found"         // 资源不存在
+	CodePayloadTooLarge = "payload_too_large" // 请求体超过大小限制
+	CodeUnavailable     = "unavailable"       // 依赖的服务暂时不可用，可以稍后重试
+	CodeNotImplemented  = "not_implemented"   // 当前存储不支持该功能
+	CodeInternal        = "internal"          // 服务端错误
+)
+
+// errorBody 构造统一的错误响应：error 为错误说明，code 为机器可读的错误码，
+// 参数错误另有 field (参数名)，语法错误另有 position (从 0 开始的字符偏移)
+func errorBody(code, msg string) gin.H {
+	return gin.H{"error": msg, "code": code}
+}
+
+// abortError 以统一的格式返回错误并中止后续的处理函数
+func abortError(c *gin.Context, status int, code, msg string) {
+	c.AbortWithStatusJSON(status, errorBody(code, msg))
+}
+
+// abortInvalid 返回请求参数的错误：校验错误附带参数名，语法错误另附带位置，其他错误按 bad_request 返回
+func abortInvalid(c *gin.Context, err error) {
+	var invalid *ports.ValidationError
+	if !errors.As(err, &invalid) {
+		abortError(c, http.StatusBadRequest, CodeBadRequest, err.Error())
+		return
+	}
+	body := errorBody(invalid.Code, invalid.Error())
+	body["field"] = invalid.Field
+	var syntaxErr *ql.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		body["position"] = syntaxErr.Pos
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, body)
+}
+
+// bindStrictJSON 解码 JSON 请求体，未知字段和类型不符都返回错误，避免拼错的字段被静默忽略；
+// 请求体为空时保持 v 不变
+func bindStrictJSON(c *gin.Context, v interface{}) error {
+	if c.Request.Body == nil {
+		return nil
+	}
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err == nil && dec.More() {
+		err = errors.New("unexpected data after JSON value")
+	}
+	return err
+}
+
+// abortJSONError 返回请求体的解码错误，能确定出错的字段时附带 field
+func abortJSONError(c *gin.Context, err error) {
+	body := errorBody(CodeInvalidJSON, err.Error())
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		body["field"] = typeErr.Field
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json 没有为未知字段定义错误类型
+		body["code"] = CodeUnknownField
+		body["field"] = strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, body)
+}
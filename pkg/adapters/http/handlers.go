@@ -3,7 +3,9 @@ package http
 import (
 	"context"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"crypto/sha256"
 	"encoding/hex"
@@ -12,6 +14,7 @@ import (
 	"time"
 
 	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/storage"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
 	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
 
 	"github.com/gin-gonic/gin"
@@ -33,45 +36,207 @@ func NewLogHandler(repo ports.LogRepository, redisRepo *storage.RedisRepository)
 func (h *LogHandler) GetLogByID(c *gin.Context) {
 	trackID := c.Param("track_id")
 	if trackID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "track_id is required"})
+		abortError(c, http.StatusBadRequest, CodeBadRequest, "track_id is required")
 		return
 	}
 
 	logEntry, err := h.repo.FindByID(c.Request.Context(), trackID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortError(c, http.StatusInternalServerError, CodeInternal, err.Error())
 		return
 	}
 	if logEntry == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "log not found"})
+		abortError(c, http.StatusNotFound, CodeNotFound, "log not found")
 		return
 	}
 
 	c.JSON(http.StatusOK, logEntry)
 }
 
-// SearchLogs 搜索日志
-func (h *LogHandler) SearchLogs(c *gin.Context) {
+// GetTrace 返回同一链路经过的所有服务的日志，按时间升序
+func (h *LogHandler) GetTrace(c *gin.Context) {
+	traceID := c.Param("id")
+	if traceID == "" {
+		abortError(c, http.StatusBadRequest, CodeBadRequest, "trace id is required")
+		return
+	}
+
+	entries, err := h.repo.FindByTrace(c.Request.Context(), traceID)
+	if err != nil {
+		abortError(c, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	if len(entries) == 0 {
+		abortError(c, http.StatusNotFound, CodeNotFound, "trace not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"trace_id": traceID,
+		"data":     entries,
+		"total":    len(entries),
+	})
+}
+
+// searchParamNames GET 搜索支持的参数，json. 开头的参数为 JSON 条件，exclude. 开头的参数为排除条件
+var searchParamNames = map[string]bool{
+	"page": true, "size": true, "start_time": true, "end_time": true, "method": true, "status": true,
+	"path": true, "level": true, "keyword": true, "cursor": true, "count": true, "q": true, "text": true, "sort": true,
+}
+
+// filterParamNames LogFilter 对应的参数
+var filterParamNames = map[string]bool{
+	"status_min": true, "status_max": true, "status_classes": true, "methods": true, "duration_min": true,
+	"duration_max": true, "client_ips": true, "services": true, "environments": true, "routes": true,
+}
+
+// searchParams 读取 GET 请求的搜索参数，未知参数和无法解析的数字返回 *ports.ValidationError
+func searchParams(params url.Values) (ports.LogSearchQuery, error) {
 	var query ports.LogSearchQuery
-	if err := c.ShouldBindJSON(&query); err != nil {
-		if query.Page == 0 && query.Size == 0 {
-			query.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
-			query.Size, _ = strconv.Atoi(c.DefaultQuery("size", "10"))
-			query.StartTime = c.Query("start_time")
-			query.EndTime = c.Query("end_time")
-			query.Method = c.Query("method")
-			query.Status, _ = strconv.Atoi(c.Query("status"))
-			query.Path = c.Query("path")
-			query.Level = c.Query("level")
-			query.Keyword = c.Query("keyword")
+	for key := range params {
+		name := strings.TrimPrefix(key, "exclude.")
+		if !searchParamNames[key] && !filterParamNames[name] && !strings.HasPrefix(key, "json.") {
+			return query, &ports.ValidationError{Field: key, Code: CodeUnknownField, Msg: "unknown parameter"}
+		}
+	}
+
+	var err error
+	number := func(name string) int64 {
+		v := params.Get(name)
+		if v == "" {
+			return 0
+		}
+		n, perr := strconv.ParseInt(v, 10, 64)
+		if perr != nil && err == nil {
+			err = &ports.ValidationError{Field: name, Code: ports.CodeInvalidValue, Msg: "expects an integer"}
+		}
+		return n
+	}
+	query.Page = int(number("page"))
+	query.Size = int(number("size"))
+	query.Status = int(number("status"))
+	if err != nil {
+		return query, err
+	}
+	query.StartTime = params.Get("start_time")
+	query.EndTime = params.Get("end_time")
+	query.Method = params.Get("method")
+	query.Path = params.Get("path")
+	query.Level = params.Get("level")
+	query.Keyword = params.Get("keyword")
+	query.Cursor = params.Get("cursor")
+	query.Count = params.Get("count")
+	query.Q = params.Get("q")
+	query.Text = params.Get("text")
+	query.Sort = params.Get("sort")
+	query.JSON = jsonFilters(params)
+
+	include, err := logFilter(params, "")
+	if err != nil {
+		return query, err
+	}
+	if include != nil {
+		query.LogFilter = *include
+	}
+	query.Exclude, err = logFilter(params, "exclude.")
+	return query, err
+}
+
+// jsonFilters 读取 json.<列名>.<路径>=<值> 形式的查询参数，值是合法的 JSON 时按 JSON 解析，否则作为字符串
+func jsonFilters(params url.Values) map[string]interface{} {
+	var filters map[string]interface{}
+	for key, values := range params {
+		field, ok := strings.CutPrefix(key, "json.")
+		if !ok || len(values) == 0 {
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(values[0]), &v); err != nil {
+			v = values[0]
+		}
+		if filters == nil {
+			filters = map[string]interface{}{}
 		}
+		filters[field] = v
 	}
+	return filters
+}
 
-	if query.Page <= 0 {
-		query.Page = 1
+// logFilter 读取 GET 参数中的多值和范围筛选，prefix 为空或 exclude.，多个值用逗号分隔或重复参数
+// 没有设置任何筛选参数时返回 nil
+func logFilter(params url.Values, prefix string) (*ports.LogFilter, error) {
+	var (
+		filter ports.LogFilter
+		set    bool
+		err    error
+	)
+	list := func(name string) []string {
+		var out []string
+		for _, v := range params[prefix+name] {
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					out = append(out, item)
+				}
+			}
+		}
+		set = set || len(out) > 0
+		return out
 	}
-	if query.Size <= 0 {
-		query.Size = 10
+	number := func(name string) int64 {
+		v := params.Get(prefix + name)
+		if v == "" {
+			return 0
+		}
+		set = true
+		n, perr := strconv.ParseInt(v, 10, 64)
+		if perr != nil && err == nil {
+			err = &ports.ValidationError{Field: prefix + name, Code: ports.CodeInvalidValue, Msg: "expects an integer"}
+		}
+		return n
+	}
+
+	filter.StatusMin = int(number("status_min"))
+	filter.StatusMax = int(number("status_max"))
+	filter.StatusClasses = list("status_classes")
+	filter.Methods = list("methods")
+	filter.DurationMin = number("duration_min")
+	filter.DurationMax = number("duration_max")
+	filter.ClientIPs = list("client_ips")
+	filter.Services = list("services")
+	filter.Environments = list("environments")
+	filter.Routes = list("routes")
+	if err != nil || !set {
+		return nil, err
+	}
+	return &filter, nil
+}
+
+// bindSearchQuery 读取并校验搜索参数：GET 请求读取查询参数，其他请求读取 JSON 请求体
+// 参数无效时返回 400 并返回 false；校验通过的查询中相对时间已转换为绝对时间
+func bindSearchQuery(c *gin.Context) (ports.LogSearchQuery, bool) {
+	var query ports.LogSearchQuery
+	if c.Request.Method == http.MethodGet {
+		var err error
+		if query, err = searchParams(c.Request.URL.Query()); err != nil {
+			abortInvalid(c, err)
+			return query, false
+		}
+	} else if err := bindStrictJSON(c, &query); err != nil {
+		abortJSONError(c, err)
+		return query, false
+	}
+	if err := query.Normalize(time.Now()); err != nil {
+		abortInvalid(c, err)
+		return query, false
+	}
+	return query, true
+}
+
+// SearchLogs 搜索日志
+func (h *LogHandler) SearchLogs(c *gin.Context) {
+	query, ok := bindSearchQuery(c)
+	if !ok {
+		return
 	}
 
 	// Generate cache key
@@ -84,19 +249,14 @@ func (h *LogHandler) SearchLogs(c *gin.Context) {
 		logs, total, err := h.redisRepo.GetCachedSearchResult(c.Request.Context(), cacheKey)
 		if err == nil && logs != nil {
 			c.Header("X-Cache", "HIT")
-			c.JSON(http.StatusOK, gin.H{
-				"data":  logs,
-				"total": total,
-				"page":  query.Page,
-				"size":  query.Size,
-			})
+			c.JSON(http.StatusOK, searchResponse(query, logs, total))
 			return
 		}
 	}
 
 	logs, total, err := h.repo.Search(c.Request.Context(), query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortError(c, http.StatusInternalServerError, CodeInternal, err.Error())
 		return
 	}
 
@@ -110,19 +270,31 @@ func (h *LogHandler) SearchLogs(c *gin.Context) {
 	}
 
 	c.Header("X-Cache", "MISS")
-	c.JSON(http.StatusOK, gin.H{
-		"data":  logs,
-		"total": total,
-		"page":  query.Page,
-		"size":  query.Size,
-	})
+	c.JSON(http.StatusOK, searchResponse(query, logs, total))
+}
+
+// searchResponse 组装搜索结果；当前页已满时返回 next_cursor，用于按键集继续翻页，
+// count=none 时不返回 total
+func searchResponse(query ports.LogSearchQuery, logs []domain.LogEntry, total int64) gin.H {
+	resp := gin.H{
+		"data": logs,
+		"page": query.Page,
+		"size": query.Size,
+	}
+	if total >= 0 {
+		resp["total"] = total
+	}
+	// 按相关度排序时不能按键集翻页
+	if len(logs) > 0 && len(logs) >= query.Size && query.SortOrder() == ports.SortTime {
+		resp["next_cursor"] = ports.CursorAfter(logs[len(logs)-1])
+	}
+	return resp
 }
 
 // ExportLogs 异步导出日志
 func (h *LogHandler) ExportLogs(c *gin.Context) {
-	var query ports.LogSearchQuery
-	if err := c.ShouldBindJSON(&query); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query"})
+	query, ok := bindSearchQuery(c)
+	if !ok {
 		return
 	}
 
@@ -141,6 +313,48 @@ func (h *LogHandler) ExportLogs(c *gin.Context) {
 	})
 }
 
+// statsParamNames 统计接口在搜索参数之外支持的参数
+var statsParamNames = []string{"group_by", "interval", "order_by"}
+
+// Stats 按路由、方法、服务或状态码类别统计请求数、错误率、耗时分位数和平均响应大小，并按时间段分桶
+// 筛选参数与 GET 搜索相同，size 为最多返回的分组数量
+func (h *LogHandler) Stats(c *gin.Context) {
+	analyzer, ok := h.repo.(ports.LogAnalyzer)
+	if !ok {
+		abortError(c, http.StatusNotImplemented, CodeNotImplemented, "storage does not support stats")
+		return
+	}
+
+	params := c.Request.URL.Query()
+	query := ports.StatsQuery{GroupBy: params.Get("group_by"), Interval: params.Get("interval"), OrderBy: params.Get("order_by")}
+	for _, name := range statsParamNames {
+		params.Del(name)
+	}
+	var err error
+	if query.LogSearchQuery, err = searchParams(params); err != nil {
+		abortInvalid(c, err)
+		return
+	}
+	if err := query.Normalize(time.Now()); err != nil {
+		abortInvalid(c, err)
+		return
+	}
+
+	groups, err := analyzer.Stats(c.Request.Context(), query)
+	if err != nil {
+		abortError(c, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"group_by":   query.GroupBy,
+		"order_by":   query.OrderBy,
+		"interval":   query.Interval,
+		"start_time": query.StartTime,
+		"end_time":   query.EndTime,
+		"data":       groups,
+	})
+}
+
 func (h *LogHandler) RegisterRoutes(router *gin.Engine) {
 	api := router.Group("/api")
 	{
@@ -148,5 +362,7 @@ func (h *LogHandler) RegisterRoutes(router *gin.Engine) {
 		api.POST("/logs/search", h.SearchLogs)
 		api.GET("/logs/search", h.SearchLogs) // 支持 GET 进行简单搜索
 		api.POST("/logs/export", h.ExportLogs)
+		api.GET("/traces/:id", h.GetTrace)
 	}
+	router.GET("/api/v1/stats", h.Stats)
 }
@@ -0,0 +1,377 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/storage"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestLogRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	repo := storage.NewMemoryRepository()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	err := repo.SaveBatch(context.Background(), []domain.LogEntry{
+		{ID: "a", TrackID: "trace-1", Timestamp: base, Level: "info",
+			Request: domain.RequestInfo{Method: "GET", URL: "/orders"}, Response: domain.ResponseInfo{StatusCode: 200}},
+		{ID: "b", TrackID: "trace-1", Timestamp: base.Add(time.Second), Level: "error",
+			Request: domain.RequestInfo{Method: "POST", URL: "/orders"}, Response: domain.ResponseInfo{StatusCode: 500}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewLogHandler(repo, nil).RegisterRoutes(r)
+	return r
+}
+
+func serve(r *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetLogByID(t *testing.T) {
+	r := newTestLogRouter(t)
+
+	w := serve(r, http.MethodGet, "/api/logs/a", "")
+	var entry domain.LogEntry
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &entry) != nil || entry.ID != "a" {
+		t.Errorf("期望返回条目 a，得到 %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(r, http.MethodGet, "/api/logs/missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("不存在的条目应返回 404，得到 %d", w.Code)
+	}
+}
+
+func TestGetTrace(t *testing.T) {
+	r := newTestLogRouter(t)
+
+	w := serve(r, http.MethodGet, "/api/traces/trace-1", "")
+	var resp struct {
+		Data  []domain.LogEntry `json:"data"`
+		Total int               `json:"total"`
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil || resp.Total != 2 || resp.Data[0].ID != "a" {
+		t.Errorf("期望按时间升序返回 2 条，得到 %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(r, http.MethodGet, "/api/traces/missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("不存在的链路应返回 404，得到 %d", w.Code)
+	}
+}
+
+func TestSearchLogs(t *testing.T) {
+	r := newTestLogRouter(t)
+
+	for _, w := range []*httptest.ResponseRecorder{
+		serve(r, http.MethodGet, "/api/logs/search?level=error", ""),
+		serve(r, http.MethodPost, "/api/logs/search", `{"level":"error"}`),
+	} {
+		var resp struct {
+			Data  []domain.LogEntry `json:"data"`
+			Total int64             `json:"total"`
+			Page  int               `json:"page"`
+			Size  int               `json:"size"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("搜索失败: %d %s", w.Code, w.Body.String())
+		}
+		if resp.Total != 1 || resp.Data[0].ID != "b" || resp.Page != 1 || resp.Size != 10 {
+			t.Errorf("搜索结果错误: %+v", resp)
+		}
+	}
+}
+
+func TestSearchLogsCursor(t *testing.T) {
+	r := newTestLogRouter(t)
+
+	var resp struct {
+		Data       []domain.LogEntry `json:"data"`
+		Total      *int64            `json:"total"`
+		NextCursor string            `json:"next_cursor"`
+	}
+	w := serve(r, http.MethodGet, "/api/logs/search?size=1&count=none", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Data) != 1 || resp.Data[0].ID != "b" {
+		t.Fatalf("第一页: %d %s", w.Code, w.Body.String())
+	}
+	if resp.Total != nil || resp.NextCursor == "" {
+		t.Errorf("count=none 时不应返回 total，且应返回 next_cursor: %s", w.Body.String())
+	}
+
+	w = serve(r, http.MethodPost, "/api/logs/search", `{"size":1,"cursor":"`+resp.NextCursor+`"}`)
+	resp.Data = nil
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Data) != 1 || resp.Data[0].ID != "a" {
+		t.Fatalf("第二页: %d %s", w.Code, w.Body.String())
+	}
+	if resp.Total == nil || *resp.Total != 2 {
+		t.Errorf("默认应精确统计总数: %s", w.Body.String())
+	}
+
+	for _, target := range []string{"/api/logs/search?cursor=bogus", "/api/logs/search?count=maybe"} {
+		if w := serve(r, http.MethodGet, target, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s 应返回 400，得到 %d", target, w.Code)
+		}
+	}
+}
+
+func TestSearchLogsQuery(t *testing.T) {
+	r := newTestLogRouter(t)
+
+	var resp struct {
+		Data  []domain.LogEntry `json:"data"`
+		Total int64             `json:"total"`
+	}
+	w := serve(r, http.MethodGet, "/api/logs/search?q="+url.QueryEscape("status>=500 AND method:post"), "")
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Total != 1 || resp.Data[0].ID != "b" {
+		t.Fatalf("查询语言搜索: %d %s", w.Code, w.Body.String())
+	}
+
+	w = serve(r, http.MethodPost, "/api/logs/search", `{"q":"status>=500 AND (method:"}`)
+	var errResp struct {
+		Error    string `json:"error"`
+		Position *int   `json:"position"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &errResp); err != nil || w.Code != http.StatusBadRequest {
+		t.Fatalf("语法错误应返回 400: %d %s", w.Code, w.Body.String())
+	}
+	if errResp.Position == nil || *errResp.Position != 24 {
+		t.Errorf("应返回错误位置 24: %s", w.Body.String())
+	}
+}
+
+func TestSearchLogsJSONFilters(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	repo.Save(context.Background(), domain.LogEntry{ID: "a", Timestamp: time.Now(),
+		Request:  domain.RequestInfo{Headers: map[string]string{"X-Tenant": "acme"}},
+		Response: domain.ResponseInfo{Body: map[string]interface{}{"error": map[string]interface{}{"code": "INSUFFICIENT_FUNDS", "status": 402}}}})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewLogHandler(repo, nil).RegisterRoutes(r)
+
+	for _, w := range []*httptest.ResponseRecorder{
+		serve(r, http.MethodGet, "/api/logs/search?json.response_body.error.code=INSUFFICIENT_FUNDS&json.request_headers.X-Tenant=acme", ""),
+		serve(r, http.MethodGet, "/api/logs/search?json.response_body.error.status=402", ""),
+		serve(r, http.MethodPost, "/api/logs/search", `{"json":{"response_body.error.code":"INSUFFICIENT_FUNDS"}}`),
+	} {
+		var resp struct {
+			Total int64 `json:"total"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Total != 1 {
+			t.Errorf("JSON 条件搜索: %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	if w := serve(r, http.MethodPost, "/api/logs/search", `{"json":{"method.x":"GET"}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("未知字段应返回 400，得到 %d", w.Code)
+	}
+}
+
+func TestSearchLogsText(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo.SaveBatch(context.Background(), []domain.LogEntry{
+		{ID: "a", Timestamp: base, Message: "upstream timeout"},
+		{ID: "b", Timestamp: base.Add(time.Second), Message: "connection reset",
+			Response: domain.ResponseInfo{Body: map[string]interface{}{"error": "timeout after 30s"}}},
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewLogHandler(repo, nil).RegisterRoutes(r)
+
+	var resp struct {
+		Data       []domain.LogEntry `json:"data"`
+		NextCursor string            `json:"next_cursor"`
+	}
+	w := serve(r, http.MethodGet, "/api/logs/search?text=timeout&size=1", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Data) != 1 || resp.Data[0].ID != "a" {
+		t.Fatalf("全文搜索应按相关度返回 a: %d %s", w.Code, w.Body.String())
+	}
+	if resp.Data[0].Highlight != "upstream <mark>timeout</mark>" || resp.NextCursor != "" {
+		t.Errorf("摘要或游标错误: %s", w.Body.String())
+	}
+
+	resp.Data, resp.NextCursor = nil, ""
+	w = serve(r, http.MethodPost, "/api/logs/search", `{"text":"timeout","sort":"time","size":1}`)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Data) != 1 || resp.Data[0].ID != "b" || resp.NextCursor == "" {
+		t.Errorf("按时间排序: %d %s", w.Code, w.Body.String())
+	}
+
+	for _, target := range []string{
+		"/api/logs/search?text=" + url.QueryEscape(`"open`),
+		"/api/logs/search?sort=random",
+		"/api/logs/search?text=timeout&cursor=" + resp.NextCursor,
+	} {
+		if w := serve(r, http.MethodGet, target, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s 应返回 400，得到 %d", target, w.Code)
+		}
+	}
+}
+
+func TestSearchLogsFilterLists(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo.SaveBatch(context.Background(), []domain.LogEntry{
+		{ID: "a", Timestamp: base, ClientIP: "10.0.0.7", Service: "orders",
+			Request: domain.RequestInfo{Method: "GET"}, Response: domain.ResponseInfo{StatusCode: 502}},
+		{ID: "b", Timestamp: base.Add(time.Second), ClientIP: "172.16.0.1", Service: "healthcheck",
+			Request: domain.RequestInfo{Method: "HEAD"}, Response: domain.ResponseInfo{StatusCode: 503}},
+		{ID: "c", Timestamp: base.Add(2 * time.Second), ClientIP: "10.0.0.8", Service: "orders",
+			Request: domain.RequestInfo{Method: "POST"}, Response: domain.ResponseInfo{StatusCode: 201}},
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewLogHandler(repo, nil).RegisterRoutes(r)
+
+	cases := map[string]*httptest.ResponseRecorder{
+		"a":   serve(r, http.MethodGet, "/api/logs/search?status_classes=5xx&methods=GET,HEAD&exclude.services=healthcheck", ""),
+		"a,c": serve(r, http.MethodGet, "/api/logs/search?client_ips=10.0.0.0/8", ""),
+		"b":   serve(r, http.MethodGet, "/api/logs/search?methods=get&methods=head&exclude.client_ips=10.0.0.0/8", ""),
+		"c":   serve(r, http.MethodPost, "/api/logs/search", `{"services":["orders"],"exclude":{"status_classes":["5xx"]}}`),
+	}
+	for want, w := range cases {
+		var resp struct {
+			Data []domain.LogEntry `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%d %s", w.Code, w.Body.String())
+		}
+		got := []string{}
+		for _, e := range resp.Data {
+			got = append(got, e.ID)
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != want {
+			t.Errorf("期望 %s，得到 %v", want, got)
+		}
+	}
+
+	for target, field := range map[string]string{
+		"/api/logs/search?status_classes=6xx":           "status_classes",
+		"/api/logs/search?duration_min=fast":            "duration_min",
+		"/api/logs/search?exclude.client_ips=10.0.0.0/": "exclude.client_ips",
+	} {
+		w := serve(r, http.MethodGet, target, "")
+		var errResp struct {
+			Field string `json:"field"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &errResp); err != nil || w.Code != http.StatusBadRequest || errResp.Field != field {
+			t.Errorf("%s 应返回 400 和字段 %s: %d %s", target, field, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSearchLogsValidation(t *testing.T) {
+	r := newTestLogRouter(t)
+
+	cases := []struct {
+		method, target, body string
+		code, field          string
+	}{
+		{http.MethodPost, "/api/logs/search", `{"stauts": 500}`, CodeUnknownField, "stauts"},
+		{http.MethodPost, "/api/logs/search", `{"status": "500"}`, CodeInvalidJSON, "status"},
+		{http.MethodPost, "/api/logs/search", `{"status": 500`, CodeInvalidJSON, ""},
+		{http.MethodPost, "/api/logs/search", `{"size": 5000}`, ports.CodeOutOfRange, "size"},
+		{http.MethodPost, "/api/logs/search", `{"start_time": "yesterday"}`, ports.CodeInvalidTime, "start_time"},
+		{http.MethodGet, "/api/logs/search?stauts=500", "", CodeUnknownField, "stauts"},
+		{http.MethodGet, "/api/logs/search?page=two", "", ports.CodeInvalidValue, "page"},
+		{http.MethodGet, "/api/logs/search?end_time=now-15x", "", ports.CodeInvalidTime, "end_time"},
+		{http.MethodGet, "/api/logs/search?count=all", "", ports.CodeInvalidValue, "count"},
+	}
+	for _, tc := range cases {
+		w := serve(r, tc.method, tc.target, tc.body)
+		var resp struct {
+			Error string `json:"error"`
+			Code  string `json:"code"`
+			Field string `json:"field"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusBadRequest ||
+			resp.Error == "" || resp.Code != tc.code || resp.Field != tc.field {
+			t.Errorf("%s %s %s: 期望 400 %s %s，得到 %d %s", tc.method, tc.target, tc.body, tc.code, tc.field, w.Code, w.Body.String())
+		}
+	}
+
+	// 相对时间和 Unix 时间戳
+	for _, target := range []string{
+		"/api/logs/search?start_time=now-36500d&end_time=now",
+		"/api/logs/search?start_time=1714564800&end_time=" + url.QueryEscape("2024-05-01T12:01:00Z"),
+	} {
+		var resp struct {
+			Total int64 `json:"total"`
+		}
+		w := serve(r, http.MethodGet, target, "")
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK || resp.Total == 0 {
+			t.Errorf("%s: %d %s", target, w.Code, w.Body.String())
+		}
+	}
+
+	w := serve(r, http.MethodGet, "/api/logs/missing", "")
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), `"code":"not_found"`) {
+		t.Errorf("404 应使用统一的错误格式: %s", w.Body.String())
+	}
+}
+
+func TestStats(t *testing.T) {
+	r := newTestLogRouter(t)
+
+	w := serve(r, http.MethodGet, "/api/v1/stats?group_by=method&interval=1h&start_time=2024-05-01T00:00:00Z&end_time=2024-05-02T00:00:00Z&path=orders", "")
+	var resp struct {
+		GroupBy  string             `json:"group_by"`
+		OrderBy  string             `json:"order_by"`
+		Interval string             `json:"interval"`
+		Data     []ports.StatsGroup `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("%d %s", w.Code, w.Body.String())
+	}
+	if resp.GroupBy != "method" || resp.OrderBy != "count" || resp.Interval != "1h" || len(resp.Data) != 2 {
+		t.Fatalf("响应不符合预期: %s", w.Body.String())
+	}
+	for _, g := range resp.Data {
+		if g.Count != 1 || len(g.Buckets) != 1 || !g.Buckets[0].Time.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+			t.Errorf("分组 %s 不符合预期: %+v", g.Key, g)
+		}
+		if wantRate := map[string]float64{"GET": 0, "POST": 1}[g.Key]; g.ErrorRate != wantRate {
+			t.Errorf("%s 的错误率为 %v，期望 %v", g.Key, g.ErrorRate, wantRate)
+		}
+	}
+
+	// 缺省统计最近 24 小时，时间段宽度自动选择
+	w = serve(r, http.MethodGet, "/api/v1/stats", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK || resp.Interval != "15m" || len(resp.Data) != 0 {
+		t.Errorf("缺省参数: %d %s", w.Code, w.Body.String())
+	}
+
+	for target, field := range map[string]string{
+		"/api/v1/stats?group_by=host":                  "group_by",
+		"/api/v1/stats?order_by=p95":                   "order_by",
+		"/api/v1/stats?interval=1s&start_time=now-7d":  "interval",
+		"/api/v1/stats?interval=soon":                  "interval",
+		"/api/v1/stats?bucket=1m":                      "bucket",
+		"/api/v1/stats?start_time=2024-05-01T00:00:00": "start_time",
+	} {
+		w := serve(r, http.MethodGet, target, "")
+		var resp struct {
+			Field string `json:"field"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusBadRequest || resp.Field != field {
+			t.Errorf("%s: 期望 400 field=%s，得到 %d %s", target, field, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler 健康检查，同时报告异步日志记录器的运行计数
+type HealthHandler struct {
+	logger *logger.AsyncLogger
+}
+
+func NewHealthHandler(l *logger.AsyncLogger) *HealthHandler {
+	return &HealthHandler{logger: l}
+}
+
+// Health 返回服务状态
+func (h *HealthHandler) Health(c *gin.Context) {
+	resp := gin.H{"status": "ok"}
+	if h.logger != nil {
+		resp["logger"] = h.logger.Stats()
+	}
+	c.JSON(http.StatusOK, resp)
+}
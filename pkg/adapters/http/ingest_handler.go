@@ -0,0 +1,304 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/logger"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxIngestClockSkew 允许采集端时间戳超前服务端的最大偏差
+const maxIngestClockSkew = 5 * time.Minute
+
+// maxIngestIDLength Track ID 的最大长度
+const maxIngestIDLength = 128
+
+// IngestHandler 接收远程采集端批量上报的日志，校验并规范化后写入异步记录器
+type IngestHandler struct {
+	logger *logger.AsyncLogger
+	opts   options
+}
+
+func NewIngestHandler(l *logger.AsyncLogger, opts ...Option) *IngestHandler {
+	return &IngestHandler{logger: l, opts: newOptions(opts)}
+}
+
+// IngestError 单条日志被拒绝的原因，Index 为条目在请求中的位置 (从 0 开始，NDJSON 不计空行)
+// Retryable 表示条目本身有效，因缓冲区已满或服务正在关闭而被拒绝，采集端可稍后重发
+type IngestError struct {
+	Index     int    `json:"index"`
+	Error     string `json:"error"`
+	Retryable bool   `json:"retryable,omitempty"`
+}
+
+// IngestResult 采集接口的响应，全部被拒绝时另有统一错误格式的 error 和 code
+type IngestResult struct {
+	Accepted int           `json:"accepted"`
+	Rejected int           `json:"rejected"`
+	Errors   []IngestError `json:"errors,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Code     string        `json:"code,omitempty"`
+}
+
+// Ingest 接收 NDJSON、JSON 数组或单个 JSON 对象形式的 domain.LogEntry，请求体可以 gzip 压缩
+// 部分条目被拒绝时仍返回 202，逐条错误见 errors，其中 retryable 的条目可以重发；全部被拒绝时返回 400，
+// 因缓冲区已满或服务正在关闭而全部被拒绝时返回 503，采集端可稍后重试
+func (h *IngestHandler) Ingest(c *gin.Context) {
+	data, err := h.readBody(c)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			abortError(c, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+			return
+		}
+		abortError(c, http.StatusBadRequest, CodeBadRequest, "Failed to read request body")
+		return
+	}
+
+	items, err := splitIngestBody(data, c.ContentType())
+	if err != nil {
+		abortError(c, http.StatusBadRequest, CodeInvalidJSON, err.Error())
+		return
+	}
+	if len(items) == 0 {
+		abortError(c, http.StatusBadRequest, CodeBadRequest, "no log entries in request body")
+		return
+	}
+
+	now := time.Now()
+	apiKey := c.GetHeader(HeaderAPIKey)
+	result := IngestResult{}
+	unavailable := 0
+	for i, raw := range items {
+		entry, err := h.parseEntry(raw, apiKey, now)
+		retryable := false
+		if err == nil {
+			err = h.logger.Log(entry)
+			retryable = errors.Is(err, logger.ErrBufferFull) || errors.Is(err, logger.ErrClosed)
+			if retryable {
+				unavailable++
+			}
+		}
+		if err != nil {
+			result.Rejected++
+			result.Errors = append(result.Errors, IngestError{Index: i, Error: err.Error(), Retryable: retryable})
+			continue
+		}
+		result.Accepted++
+	}
+
+	status := http.StatusAccepted
+	if result.Accepted == 0 {
+		status, result.Code = http.StatusBadRequest, CodeBadRequest
+		result.Error = "all log entries were rejected"
+		if unavailable > 0 {
+			status, result.Code = http.StatusServiceUnavailable, CodeUnavailable
+			c.Header("Retry-After", "1")
+		}
+	}
+	c.JSON(status, result)
+}
+
+// readBody 读取请求体，支持 Content-Encoding: gzip
+// 压缩前和解压后的大小都受 maxIngestBody 限制
+func (h *IngestHandler) readBody(c *gin.Context) ([]byte, error) {
+	limit := h.opts.maxIngestBody
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	if !strings.EqualFold(c.GetHeader("Content-Encoding"), "gzip") {
+		return io.ReadAll(body)
+	}
+
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, &http.MaxBytesError{Limit: limit}
+	}
+	return data, nil
+}
+
+// splitIngestBody 把请求体拆分为单条 JSON
+// NDJSON 按行拆分，单行错误只影响该条；JSON 数组或对象语法错误时整个请求无效
+func splitIngestBody(data []byte, contentType string) ([]json.RawMessage, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	ndjson := strings.Contains(contentType, "ndjson") || strings.Contains(contentType, "jsonlines")
+	if !ndjson {
+		switch {
+		case data[0] == '[':
+			var items []json.RawMessage
+			if err := json.Unmarshal(data, &items); err != nil {
+				return nil, fmt.Errorf("invalid JSON array: %v", err)
+			}
+			return items, nil
+		case json.Valid(data):
+			return []json.RawMessage{data}, nil
+		}
+		// 未声明 NDJSON 但由多行对象组成时按 NDJSON 处理
+	}
+
+	var items []json.RawMessage
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			items = append(items, line)
+		}
+	}
+	return items, nil
+}
+
+// parseEntry 解析单条日志，规范化后按配置脱敏；上报的 ID 在 apiKey 命名空间下派生
+func (h *IngestHandler) parseEntry(raw json.RawMessage, apiKey string, now time.Time) (domain.LogEntry, error) {
+	var entry domain.LogEntry
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&entry); err != nil {
+		return entry, fmt.Errorf("invalid JSON: %v", err)
+	}
+	if err := normalizeIngestEntry(&entry, apiKey, now); err != nil {
+		return entry, err
+	}
+	if h.opts.redactor != nil {
+		h.opts.redactor.RedactEntry(&entry)
+	}
+	return entry, nil
+}
+
+// normalizeIngestEntry 校验远程上报的条目并补齐缺省字段：
+// ID、Track ID/trace-id、时间戳、Kind、Level 和 Route，Method 统一为大写。
+// 上报的 ID 必须是 UUID，按 API Key 派生为存储 ID，重发时幂等且无法覆盖其他采集端的条目
+func normalizeIngestEntry(e *domain.LogEntry, apiKey string, now time.Time) error {
+	e.Request.Method = strings.ToUpper(strings.TrimSpace(e.Request.Method))
+	if e.Request.Method == "" && e.Message == "" {
+		return errors.New("request.method or message is required")
+	}
+	if e.Request.Method != "" && !isHTTPToken(e.Request.Method) {
+		return fmt.Errorf("invalid request.method %q", e.Request.Method)
+	}
+	if e.Request.URL != "" {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			return fmt.Errorf("invalid request.url: %v", err)
+		}
+		if e.Route == "" {
+			e.Route = NormalizePath(u.Path)
+		}
+	}
+	if code := e.Response.StatusCode; code != 0 && (code < 100 || code > 599) {
+		return fmt.Errorf("invalid response.status_code %d", code)
+	}
+	if e.DurationMs < 0 || e.Request.Size < 0 || e.Response.Size < 0 {
+		return errors.New("duration_ms and sizes must not be negative")
+	}
+
+	if e.Timestamp.IsZero() {
+		e.Timestamp = now
+	} else if e.Timestamp.After(now.Add(maxIngestClockSkew)) {
+		return fmt.Errorf("timestamp %s is in the future", e.Timestamp.Format(time.RFC3339))
+	}
+
+	e.Kind = strings.ToLower(strings.TrimSpace(e.Kind))
+	switch e.Kind {
+	case "":
+		e.Kind = domain.KindServer
+	case domain.KindServer, domain.KindClient:
+	default:
+		return fmt.Errorf("invalid kind %q", e.Kind)
+	}
+
+	e.Level = strings.ToLower(strings.TrimSpace(e.Level))
+	switch e.Level {
+	case "":
+		switch {
+		case e.Response.StatusCode >= 500:
+			e.Level = "error"
+		case e.Response.StatusCode >= 400:
+			e.Level = "warn"
+		default:
+			e.Level = "info"
+		}
+	case "warning":
+		e.Level = "warn"
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("invalid level %q", e.Level)
+	}
+
+	if err := normalizeIngestTrace(e); err != nil {
+		return err
+	}
+	if e.ID == "" {
+		e.ID = utils.GenerateEntryID()
+		return nil
+	}
+	id, err := utils.NamespacedEntryID(apiKey, e.ID)
+	if err != nil {
+		return fmt.Errorf("invalid id %q: must be a UUID", e.ID)
+	}
+	e.ID = id
+	return nil
+}
+
+// normalizeIngestTrace 校验链路字段，Track ID 与 trace-id 缺一时互相推导，都缺失时生成新链路
+func normalizeIngestTrace(e *domain.LogEntry) error {
+	e.TrackID = strings.TrimSpace(e.TrackID)
+	e.TraceID = strings.ToLower(strings.TrimSpace(e.TraceID))
+	e.SpanID = strings.ToLower(strings.TrimSpace(e.SpanID))
+	e.ParentSpanID = strings.ToLower(strings.TrimSpace(e.ParentSpanID))
+
+	if e.TraceID != "" && !utils.IsValidTraceID(e.TraceID) {
+		return fmt.Errorf("invalid trace_id %q", e.TraceID)
+	}
+	if e.SpanID != "" && !utils.IsValidSpanID(e.SpanID) {
+		return fmt.Errorf("invalid span_id %q", e.SpanID)
+	}
+	if e.ParentSpanID != "" && !utils.IsValidSpanID(e.ParentSpanID) {
+		return fmt.Errorf("invalid parent_span_id %q", e.ParentSpanID)
+	}
+	if len(e.TrackID) > maxIngestIDLength {
+		return fmt.Errorf("track_id exceeds %d characters", maxIngestIDLength)
+	}
+
+	switch {
+	case e.TrackID == "" && e.TraceID != "":
+		e.TrackID = utils.TrackIDFromTraceID(e.TraceID)
+	case e.TrackID == "":
+		e.TrackID = utils.GenerateTrackID()
+		e.TraceID, _ = utils.TraceIDFromTrackID(e.TrackID)
+	case e.TraceID == "":
+		// 非 UUID 形式的 Track ID 没有对应的 trace-id，保持为空
+		e.TraceID, _ = utils.TraceIDFromTrackID(e.TrackID)
+	}
+	return nil
+}
+
+// isHTTPToken 检查 Method 是否只包含 RFC 9110 token 字符
+func isHTTPToken(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`"(),/:;<=>?@[\]{}`, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/logger"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type staticKeys map[string]bool
+
+func (k staticKeys) ValidateAPIKey(ctx context.Context, apiKey string) (bool, error) {
+	return k[apiKey], nil
+}
+
+func newIngestRouter(l *logger.AsyncLogger) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/v1/ingest", APIKeyMiddleware(staticKeys{"secret": true, "other": true}), NewIngestHandler(l).Ingest)
+	return r
+}
+
+func TestIngestNDJSON(t *testing.T) {
+	repo := &captureRepo{}
+	l := logger.NewAsyncLogger(repo, 10)
+	r := newIngestRouter(l)
+
+	body := strings.Join([]string{
+		`{"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","request":{"method":"post","url":"/orders/42?debug=1","headers":{"Authorization":"Bearer x"}},"response":{"status_code":502}}`,
+		``,
+		`{"request":{"method":"GET"},"kind":"bogus"}`,
+		`not json`,
+		`{"message":"worker started","level":"WARNING"}`,
+	}, "\n")
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ingest", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set(HeaderAPIKey, "secret")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	l.Close()
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("期望 202，得到 %d: %s", w.Code, w.Body.String())
+	}
+	var result IngestResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Accepted != 2 || result.Rejected != 2 || result.Errors[0].Index != 1 || result.Errors[1].Index != 2 {
+		t.Errorf("逐条结果错误: %+v", result)
+	}
+
+	if len(repo.entries) != 2 {
+		t.Fatalf("期望写入 2 条，得到 %d", len(repo.entries))
+	}
+	e := repo.entries[0]
+	if e.ID == "" || e.Kind != domain.KindServer || e.Level != "error" || e.Request.Method != "POST" || e.Route != "/orders/:id" {
+		t.Errorf("条目未规范化: %+v", e)
+	}
+	if e.TrackID != "4bf92f35-77b3-4da6-a3ce-929d0e0e4736" {
+		t.Errorf("Track ID 应由 trace-id 推导，得到 %s", e.TrackID)
+	}
+	if e.Request.Headers["Authorization"] != DefaultPlaceholder {
+		t.Errorf("上报的条目应被脱敏，得到 %q", e.Request.Headers["Authorization"])
+	}
+	if repo.entries[1].Level != "warn" || repo.entries[1].TraceID == "" {
+		t.Errorf("条目未规范化: %+v", repo.entries[1])
+	}
+}
+
+func TestIngestRejectsRequests(t *testing.T) {
+	l := logger.NewAsyncLogger(&captureRepo{}, 10)
+	defer l.Close()
+	r := newIngestRouter(l)
+
+	cases := []struct {
+		name, key, body string
+		want            int
+	}{
+		{"缺少 API Key", "", `[{"message":"x"}]`, http.StatusUnauthorized},
+		{"无效 API Key", "wrong", `[{"message":"x"}]`, http.StatusUnauthorized},
+		{"数组语法错误", "secret", `[{"message":"x"}`, http.StatusBadRequest},
+		{"空请求体", "secret", ``, http.StatusBadRequest},
+		{"全部无效", "secret", `[{"level":"info"}]`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/ingest", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.key != "" {
+			req.Header.Set(HeaderAPIKey, tc.key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s: 期望 %d，得到 %d: %s", tc.name, tc.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestIngestNamespacesEntryIDs(t *testing.T) {
+	repo := &captureRepo{}
+	l := logger.NewAsyncLogger(repo, 10)
+	r := newIngestRouter(l)
+
+	const id = "0190f7a2-5b1c-7c3e-8a4d-2f6e9b8c1d0a"
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/ingest", strings.NewReader(body))
+		req.Header.Set(HeaderAPIKey, key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w := post("secret", `[{"id":"`+id+`","message":"a"},{"id":"`+strings.ToUpper(id)+`","message":"a"},{"id":"order-1","message":"b"}]`)
+	post("other", `[{"id":"`+id+`","message":"a"}]`)
+	l.Close()
+
+	var result IngestResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Accepted != 2 || len(result.Errors) != 1 || result.Errors[0].Index != 2 {
+		t.Errorf("非 UUID 的 id 应被拒绝: %+v", result)
+	}
+	if len(repo.entries) != 3 {
+		t.Fatalf("期望写入 3 条，得到 %d", len(repo.entries))
+	}
+	ids := []string{repo.entries[0].ID, repo.entries[1].ID, repo.entries[2].ID}
+	if ids[0] == id || ids[0] != ids[1] {
+		t.Errorf("同一 API Key 下相同的 id 应得到稳定的派生 ID，得到 %v", ids)
+	}
+	if ids[2] == ids[0] {
+		t.Errorf("不同 API Key 下的 id 不应冲突，得到 %v", ids)
+	}
+}
+
+func TestIngestRetryableRejections(t *testing.T) {
+	l := logger.NewAsyncLogger(&captureRepo{}, 10)
+	l.Close()
+	r := newIngestRouter(l)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ingest", strings.NewReader(`[{"message":"x"},{"level":"info"}]`))
+	req.Header.Set(HeaderAPIKey, "secret")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("服务关闭时期望 503 和 Retry-After，得到 %d: %s", w.Code, w.Body.String())
+	}
+	var result IngestResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Errors) != 2 || !result.Errors[0].Retryable || result.Errors[1].Retryable {
+		t.Errorf("只有因服务关闭被拒绝的条目可以重试: %+v", result.Errors)
+	}
+}
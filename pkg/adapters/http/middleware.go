@@ -1,9 +1,6 @@
 package http
 
 import (
-	"bytes"
-	"encoding/json"
-	"io"
 	"log"
 	"net/http"
 	"strings"
@@ -11,6 +8,7 @@ import (
 
 	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/logger"
 	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
 	"github.com/MCCodingMan/TraceBuddy/pkg/utils"
 
 	"github.com/gin-gonic/gin"
@@ -19,71 +17,45 @@ import (
 
 type LogMiddleware struct {
 	logger *logger.AsyncLogger
+	opts   options
 }
 
-func NewLogMiddleware(l *logger.AsyncLogger) *LogMiddleware {
-	return &LogMiddleware{logger: l}
-}
-
-type bodyLogWriter struct {
-	gin.ResponseWriter
-	body *bytes.Buffer
-}
-
-func (w bodyLogWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
-	return w.ResponseWriter.Write(b)
+func NewLogMiddleware(l *logger.AsyncLogger, opts ...Option) *LogMiddleware {
+	return &LogMiddleware{logger: l, opts: newOptions(opts)}
 }
 
 func (m *LogMiddleware) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 
-		// 生成或获取 Track ID
-		trackID := c.GetHeader("X-Trace-Id")
-		if trackID == "" {
-			trackID = utils.GenerateTrackID()
-		}
-		c.Header("X-Trace-Id", trackID)
+		// 解析 traceparent / X-Trace-Id，生成本次请求的 span
+		trackID, span := resolveTrace(c.Request.Header, m.opts.traceMode)
+		writeTraceHeaders(c.Writer.Header(), trackID, span, m.opts.traceMode)
+		c.Set("track_id", trackID)
+		c.Set("trace_id", span.TraceID)
+		c.Set("span_id", span.SpanID)
+		c.Request = c.Request.WithContext(utils.ContextWithTrace(c.Request.Context(), trackID, span))
 
-		// 读取请求体
-		var reqBodyBytes []byte
-		if c.Request.Body != nil {
-			reqBodyBytes, _ = io.ReadAll(c.Request.Body)
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(reqBodyBytes))
-		}
+		// 包装请求体，只预读捕获上限内的前缀
+		reqBody := m.opts.wrapRequestBody(c.Request)
 
 		// 包装 Response Writer 以捕获响应体
-		blw := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer}
+		blw := newBodyLogWriter(c.Writer, &m.opts)
 		c.Writer = blw
 
 		// 处理请求
 		c.Next()
 
-		// 计算耗时
-		duration := time.Since(start).Milliseconds()
-
 		// 准备日志条目
-		entry := domain.LogEntry{
-			TrackID:    trackID,
-			Timestamp:  start,
-			DurationMs: duration,
-			ClientIP:   c.ClientIP(),
-			Request: domain.RequestInfo{
-				Method:      c.Request.Method,
-				URL:         c.Request.URL.String(),
-				Proto:       c.Request.Proto,
-				Headers:     convertHeaders(c.Request.Header),
-				QueryParams: convertQueryParams(c.Request.URL.Query()),
-				Body:        maskSensitiveData(reqBodyBytes),
-			},
-			Response: domain.ResponseInfo{
-				StatusCode: c.Writer.Status(),
-				Headers:    convertHeaders(c.Writer.Header()),
-				Body:       maskSensitiveData(blw.body.Bytes()),
-				Size:       int64(blw.Size()),
-			},
+		entry := m.opts.newEntry(domain.KindServer, trackID, span, start, c.Request, reqBody)
+		entry.ClientIP = c.ClientIP()
+		// 未匹配任何路由时 FullPath 为空，保留规范化后的路径
+		if route := c.FullPath(); route != "" {
+			entry.Route = route
+			entry.Handler = c.HandlerName()
 		}
+		m.opts.setResponse(&entry, c.Writer.Status(), c.Writer.Header(), &blw.body)
+		m.opts.finish(&entry)
 
 		// 发送到异步记录器
 		m.logger.Log(entry)
@@ -110,40 +82,19 @@ func convertQueryParams(q map[string][]string) map[string]string {
 	return params
 }
 
-func maskSensitiveData(body []byte) interface{} {
-	if len(body) == 0 {
-		return nil
-	}
-
-	var data map[string]interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		return string(body) // 如果不是 JSON，则返回原始字符串
-	}
-
-	maskKeys := []string{"password", "token", "secret", "authorization"}
-	for k := range data {
-		for _, maskKey := range maskKeys {
-			if k == maskKey {
-				data[k] = "***"
-			}
-		}
-	}
-	return data
-}
-
 // AuthMiddleware JWT Token 认证
 func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			abortError(c, http.StatusUnauthorized, CodeUnauthorized, "Authorization header required")
 			return
 		}
 
 		// 提取 Bearer Token
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format"})
+			abortError(c, http.StatusUnauthorized, CodeUnauthorized, "Invalid authorization format")
 			return
 		}
 
@@ -158,7 +109,7 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 		})
 
 		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			abortError(c, http.StatusUnauthorized, CodeUnauthorized, "Invalid or expired token")
 			return
 		}
 
@@ -172,6 +123,44 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 	}
 }
 
+// RequireRole 要求 AuthMiddleware 解析出的用户角色为 role，否则返回 403
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != role {
+			abortError(c, http.StatusForbidden, CodeForbidden, "Insufficient permissions")
+			return
+		}
+		c.Next()
+	}
+}
+
+// HeaderAPIKey 远程采集端携带 API Key 的请求头
+const HeaderAPIKey = "X-API-Key"
+
+// APIKeyMiddleware 校验 X-API-Key，用于不持有 JWT 的远程采集端
+func APIKeyMiddleware(v ports.APIKeyValidator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader(HeaderAPIKey)
+		if apiKey == "" {
+			abortError(c, http.StatusUnauthorized, CodeUnauthorized, "X-API-Key header required")
+			return
+		}
+
+		ok, err := v.ValidateAPIKey(c.Request.Context(), apiKey)
+		if err != nil {
+			log.Printf("Failed to validate API key: %v", err)
+			abortError(c, http.StatusServiceUnavailable, CodeUnavailable, "API key validation unavailable")
+			return
+		}
+		if !ok {
+			abortError(c, http.StatusUnauthorized, CodeUnauthorized, "Invalid API key")
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // AuditMiddleware 审计日志中间件
 func AuditMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -193,7 +182,7 @@ func CORSMiddleware() gin.HandlerFunc {
             c.Header("Access-Control-Allow-Origin", "*")
         }
         c.Header("Access-Control-Allow-Credentials", "true")
-        c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Trace-Id")
+        c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Trace-Id, X-API-Key, traceparent, tracestate")
         c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
         if c.Request.Method == "OPTIONS" {
             c.AbortWithStatus(204)
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/utils"
+)
+
+// HTTPMiddleware 返回标准库形式的中间件，适用于 net/http、chi 等框架，
+// 记录的日志条目与 Handler() 完全一致
+func (m *LogMiddleware) HTTPMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			trackID, span := resolveTrace(r.Header, m.opts.traceMode)
+			writeTraceHeaders(w.Header(), trackID, span, m.opts.traceMode)
+			r = r.WithContext(utils.ContextWithTrace(r.Context(), trackID, span))
+
+			reqBody := m.opts.wrapRequestBody(r)
+			rw := &responseCapture{ResponseWriter: w, opts: &m.opts}
+
+			next.ServeHTTP(rw, r)
+
+			entry := m.opts.newEntry(domain.KindServer, trackID, span, start, r, reqBody)
+			entry.ClientIP = remoteIP(r)
+			// http.ServeMux 匹配后在请求上记录模式，其他路由器没有时保留规范化后的路径
+			if r.Pattern != "" {
+				entry.Route = patternRoute(r.Pattern)
+			}
+			m.opts.setResponse(&entry, rw.Status(), w.Header(), &rw.body)
+			if rw.hijacked {
+				// 连接被接管 (如 WebSocket 升级) 后的数据不经过 ResponseWriter，无法捕获响应体
+				entry.Message = "connection hijacked, response body not captured"
+			}
+			m.opts.finish(&entry)
+
+			m.logger.Log(entry)
+		})
+	}
+}
+
+// responseCapture 包装 http.ResponseWriter，记录状态码并按上限捕获响应体
+type responseCapture struct {
+	http.ResponseWriter
+	opts        *options
+	status      int
+	wroteHeader bool
+	hijacked    bool
+	body        limitedBuffer
+}
+
+func (w *responseCapture) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = code
+		w.body.limit = w.opts.responseLimit(w.Header())
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseCapture) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.body.Write(b[:n])
+	return n, err
+}
+
+// Flush 支持流式响应
+func (w *responseCapture) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 支持 WebSocket 等协议升级，接管连接后不再捕获响应
+// 处理函数未写入状态码时按 101 Switching Protocols 记录
+func (w *responseCapture) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http: underlying ResponseWriter does not implement http.Hijacker")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+		if !w.wroteHeader {
+			w.wroteHeader = true
+			w.status = http.StatusSwitchingProtocols
+		}
+	}
+	return conn, rw, err
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (w *responseCapture) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status 返回响应状态码，处理函数未写入任何内容时为 200
+func (w *responseCapture) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// remoteIP 与 gin 默认的 ClientIP 行为一致：优先 X-Forwarded-For，其次 X-Real-Ip，最后是连接地址
+func remoteIP(r *http.Request) string {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		first, _, _ := strings.Cut(xff, ",")
+		if ip := strings.TrimSpace(first); ip != "" {
+			return ip
+		}
+	}
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-Ip")); ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
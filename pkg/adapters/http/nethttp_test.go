@@ -0,0 +1,170 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/logger"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+	"github.com/MCCodingMan/TraceBuddy/pkg/utils"
+)
+
+// captureRepo 记录所有写入的日志条目
+type captureRepo struct {
+	mu      sync.Mutex
+	entries []domain.LogEntry
+}
+
+func (r *captureRepo) Save(ctx context.Context, entry domain.LogEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func (r *captureRepo) FindByID(ctx context.Context, id string) (*domain.LogEntry, error) {
+	return nil, nil
+}
+
+func (r *captureRepo) FindByTrace(ctx context.Context, traceID string) ([]domain.LogEntry, error) {
+	return nil, nil
+}
+
+func (r *captureRepo) Search(ctx context.Context, query ports.LogSearchQuery) ([]domain.LogEntry, int64, error) {
+	return nil, 0, nil
+}
+
+func TestHTTPMiddlewareAndTransport(t *testing.T) {
+	var downstreamTraceparent string
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstreamTraceparent = r.Header.Get(HeaderTraceparent)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer downstream.Close()
+
+	repo := &captureRepo{}
+	l := logger.NewAsyncLogger(repo, 10)
+	m := NewLogMiddleware(l)
+	client := &http.Client{Transport: NewTransport(l, nil)}
+
+	handler := m.HTTPMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, downstream.URL+"/child", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("出站请求失败: %v", err)
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"password":"x"}`))
+	req.Header.Set(HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	l.Close()
+
+	if rec.Code != http.StatusCreated || rec.Body.String() != `{"password":"x"}` {
+		t.Fatalf("处理函数应收到完整请求体，得到 %d %s", rec.Code, rec.Body.String())
+	}
+	if len(repo.entries) != 2 {
+		t.Fatalf("期望 2 条日志，得到 %d", len(repo.entries))
+	}
+
+	var server, client2 domain.LogEntry
+	for _, e := range repo.entries {
+		if e.Kind == domain.KindServer {
+			server = e
+		} else {
+			client2 = e
+		}
+	}
+	if server.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("服务端条目链路信息错误: %+v", server)
+	}
+	if server.Response.StatusCode != http.StatusCreated || server.ClientIP == "" {
+		t.Errorf("服务端条目响应信息错误: %+v", server.Response)
+	}
+	if body := server.Request.Body.(map[string]interface{}); body["password"] != DefaultPlaceholder {
+		t.Errorf("请求体应脱敏: %v", body)
+	}
+
+	if client2.Kind != domain.KindClient || client2.TraceID != server.TraceID || client2.ParentSpanID != server.SpanID {
+		t.Errorf("客户端条目应挂在服务端 span 下: %+v", client2)
+	}
+	tc, err := utils.ParseTraceparent(downstreamTraceparent)
+	if err != nil || tc.TraceID != server.TraceID || tc.SpanID != client2.SpanID {
+		t.Errorf("下游应收到客户端 span 的 traceparent，得到 %q", downstreamTraceparent)
+	}
+	if client2.Response.StatusCode != http.StatusOK || client2.Response.Size != int64(len(`{"ok":true}`)) {
+		t.Errorf("客户端条目响应信息错误: %+v", client2.Response)
+	}
+}
+
+func TestHTTPMiddlewareHijack(t *testing.T) {
+	repo := &captureRepo{}
+	l := logger.NewAsyncLogger(repo, 10)
+	m := NewLogMiddleware(l)
+
+	// 简化的协议升级：握手后按行回显
+	upgrade := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		line, _ := rw.ReadString('\n')
+		rw.WriteString(line)
+		rw.Flush()
+	})
+	// 接管的连接不受 srv.Close 等待，处理完成后再关闭记录器
+	done := make(chan struct{})
+	handler := m.HTTPMiddleware()(upgrade)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: example.com\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("升级失败: %v %v", resp, err)
+	}
+	io.WriteString(conn, "ping\n")
+	if line, _ := br.ReadString('\n'); line != "ping\n" {
+		t.Fatalf("升级后的连接应可用，得到 %q", line)
+	}
+	conn.Close()
+	<-done
+	l.Close()
+
+	if len(repo.entries) != 1 {
+		t.Fatalf("期望 1 条日志，得到 %d", len(repo.entries))
+	}
+	e := repo.entries[0]
+	if e.Response.StatusCode != http.StatusSwitchingProtocols || e.Response.Body != nil || e.Message == "" {
+		t.Errorf("接管连接的请求应记录 101 且标记未捕获响应体: %+v", e.Response)
+	}
+}
@@ -0,0 +1,87 @@
+package http
+
+// DefaultMaxBodyBytes 默认的请求体/响应体捕获上限 (64 KiB)
+const DefaultMaxBodyBytes int64 = 64 << 10
+
+// DefaultMaxIngestBytes 采集接口单次请求体的默认上限 (8 MiB)
+const DefaultMaxIngestBytes int64 = 8 << 20
+
+// defaultSkipContentTypes 默认不捕获 Body 的内容类型
+var defaultSkipContentTypes = []string{
+	"multipart/form-data",
+	"application/octet-stream",
+	"application/zip",
+	"image/",
+	"audio/",
+	"video/",
+}
+
+// options 日志中间件的可选配置
+type options struct {
+	maxRequestBody   int64
+	maxResponseBody  int64
+	skipContentTypes []string
+	redactor         *Redactor
+	traceMode        TraceMode
+	maxIngestBody    int64
+}
+
+// Option 用于定制日志中间件
+type Option func(*options)
+
+func defaultOptions() options {
+	return options{
+		maxRequestBody:   DefaultMaxBodyBytes,
+		maxResponseBody:  DefaultMaxBodyBytes,
+		skipContentTypes: defaultSkipContentTypes,
+		redactor:         DefaultRedactor(),
+		maxIngestBody:    DefaultMaxIngestBytes,
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithMaxBodyBytes 设置请求体和响应体各自的捕获上限，<= 0 表示不捕获对应方向的 Body
+func WithMaxBodyBytes(request, response int64) Option {
+	return func(o *options) {
+		o.maxRequestBody = request
+		o.maxResponseBody = response
+	}
+}
+
+// WithSkipContentTypes 设置完全跳过 Body 捕获的内容类型 (覆盖默认列表)
+// 以 "/" 结尾的条目按前缀匹配，例如 "image/"
+func WithSkipContentTypes(types ...string) Option {
+	return func(o *options) {
+		o.skipContentTypes = types
+	}
+}
+
+// WithRedactor 设置脱敏引擎，nil 表示不脱敏
+func WithRedactor(r *Redactor) Option {
+	return func(o *options) {
+		o.redactor = r
+	}
+}
+
+// WithTraceMode 设置链路头的读写模式，默认同时支持 traceparent 和 X-Trace-Id
+func WithTraceMode(mode TraceMode) Option {
+	return func(o *options) {
+		o.traceMode = mode
+	}
+}
+
+// WithMaxIngestBytes 设置采集接口单次请求体的上限，超出时返回 413
+func WithMaxIngestBytes(n int64) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.maxIngestBody = n
+		}
+	}
+}
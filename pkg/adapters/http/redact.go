@@ -0,0 +1,382 @@
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+)
+
+// MaskStrategy 脱敏策略
+type MaskStrategy string
+
+const (
+	MaskFull    MaskStrategy = "mask"    // 整体替换为占位符
+	MaskHash    MaskStrategy = "hash"    // 替换为 HMAC-SHA256 摘要，相同取值可关联
+	MaskPartial MaskStrategy = "partial" // 仅保留首尾若干字符
+)
+
+// DefaultPlaceholder 默认的脱敏占位符
+const DefaultPlaceholder = "***"
+
+// RedactRule 描述一条脱敏规则，Key、Path、ValuePattern 三者需且只能设置一个
+type RedactRule struct {
+	Key          string       `json:"key,omitempty"`           // 字段名，大小写不敏感，支持 glob，如 "*token*"
+	Path         string       `json:"path,omitempty"`          // Body 中的 JSON 路径，如 "$.user.cards[*].number"
+	ValuePattern string       `json:"value_pattern,omitempty"` // 匹配字符串值的正则，只替换匹配到的部分
+	Strategy     MaskStrategy `json:"strategy,omitempty"`      // 默认为 MaskFull
+	KeepPrefix   int          `json:"keep_prefix,omitempty"`   // MaskPartial 保留的前缀字符数
+	KeepSuffix   int          `json:"keep_suffix,omitempty"`   // MaskPartial 保留的后缀字符数
+}
+
+// RedactorConfig 脱敏引擎配置
+type RedactorConfig struct {
+	Rules       []RedactRule
+	HashKey     string // MaskHash 使用的 HMAC 密钥，建议在生产环境设置
+	Placeholder string // 默认为 DefaultPlaceholder
+}
+
+// DefaultRedactRules 返回内置的脱敏规则
+func DefaultRedactRules() []RedactRule {
+	rules := []RedactRule{}
+	for _, key := range []string{
+		"password", "passwd", "pwd", "secret", "*_secret", "*token*",
+		"authorization", "proxy-authorization", "cookie", "set-cookie",
+		"x-api-key", "api_key", "apikey", "private_key",
+	} {
+		rules = append(rules, RedactRule{Key: key})
+	}
+	// 出现在任意字符串中的 JWT
+	rules = append(rules, RedactRule{ValuePattern: `eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`})
+	return rules
+}
+
+type compiledRule struct {
+	RedactRule
+	key      string         // 小写的 glob
+	segments []string       // 小写的路径段
+	value    *regexp.Regexp // ValuePattern
+	raw      []*regexp.Regexp
+}
+
+// Redactor 按规则对日志中的 Header、查询参数、URL 和 Body 脱敏
+type Redactor struct {
+	keyRules    []*compiledRule
+	pathRules   []*compiledRule
+	valueRules  []*compiledRule
+	hashKey     []byte
+	placeholder string
+}
+
+var defaultRedactor = mustRedactor(RedactorConfig{Rules: DefaultRedactRules()})
+
+func mustRedactor(cfg RedactorConfig) *Redactor {
+	r, err := NewRedactor(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// DefaultRedactor 返回使用内置规则的脱敏引擎
+func DefaultRedactor() *Redactor {
+	return defaultRedactor
+}
+
+// NewRedactor 编译脱敏规则
+func NewRedactor(cfg RedactorConfig) (*Redactor, error) {
+	r := &Redactor{hashKey: []byte(cfg.HashKey), placeholder: cfg.Placeholder}
+	if r.placeholder == "" {
+		r.placeholder = DefaultPlaceholder
+	}
+	for i, rule := range cfg.Rules {
+		c := &compiledRule{RedactRule: rule}
+		if c.Strategy == "" {
+			c.Strategy = MaskFull
+		}
+		switch c.Strategy {
+		case MaskFull, MaskHash, MaskPartial:
+		default:
+			return nil, fmt.Errorf("redact rule %d: unknown strategy %q", i, c.Strategy)
+		}
+		if rule.KeepPrefix < 0 || rule.KeepSuffix < 0 {
+			return nil, fmt.Errorf("redact rule %d: keep_prefix and keep_suffix must not be negative", i)
+		}
+		if (rule.KeepPrefix != 0 || rule.KeepSuffix != 0) && c.Strategy != MaskPartial {
+			return nil, fmt.Errorf("redact rule %d: keep_prefix and keep_suffix require strategy %q", i, MaskPartial)
+		}
+
+		set := 0
+		if rule.Key != "" {
+			set++
+			c.key = strings.ToLower(rule.Key)
+			if _, err := path.Match(c.key, ""); err != nil {
+				return nil, fmt.Errorf("redact rule %d: invalid key pattern %q: %w", i, rule.Key, err)
+			}
+			c.raw = rawKeyPatterns(c.key)
+			r.keyRules = append(r.keyRules, c)
+		}
+		if rule.Path != "" {
+			set++
+			c.segments = parseRedactPath(rule.Path)
+			for _, seg := range c.segments {
+				if _, err := path.Match(seg, ""); err != nil {
+					return nil, fmt.Errorf("redact rule %d: invalid path %q: %w", i, rule.Path, err)
+				}
+			}
+			r.pathRules = append(r.pathRules, c)
+		}
+		if rule.ValuePattern != "" {
+			set++
+			re, err := regexp.Compile(rule.ValuePattern)
+			if err != nil {
+				return nil, fmt.Errorf("redact rule %d: invalid value pattern: %w", i, err)
+			}
+			c.value = re
+			r.valueRules = append(r.valueRules, c)
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("redact rule %d: exactly one of key, path or value_pattern is required", i)
+		}
+	}
+	return r, nil
+}
+
+// parseRedactPath 将 "$.a.b[*].c" 形式的路径拆分为小写的路径段
+func parseRedactPath(p string) []string {
+	p = strings.TrimPrefix(strings.TrimPrefix(p, "$"), ".")
+	p = strings.ReplaceAll(p, "[", ".")
+	p = strings.ReplaceAll(p, "]", "")
+	segments := []string{}
+	for _, seg := range strings.Split(p, ".") {
+		if seg != "" {
+			segments = append(segments, strings.ToLower(seg))
+		}
+	}
+	return segments
+}
+
+// rawKeyPatterns 为无法解析的文本 Body 生成 "key":"value" 和 key=value 两种匹配模式
+func rawKeyPatterns(glob string) []*regexp.Regexp {
+	key := regexp.QuoteMeta(glob)
+	key = strings.ReplaceAll(key, `\*`, `[^"=&\s]*`)
+	key = strings.ReplaceAll(key, `\?`, `[^"=&\s]`)
+	return []*regexp.Regexp{
+		regexp.MustCompile(`(?i)("` + key + `"\s*:\s*")((?:[^"\\]|\\.)*)`),
+		regexp.MustCompile(`(?i)((?:^|[?&;\s])` + key + `=)([^&;\s]*)`),
+	}
+}
+
+// RedactEntry 对日志条目的 URL、Header、查询参数和 Body 原地脱敏
+func (r *Redactor) RedactEntry(entry *domain.LogEntry) {
+	entry.Request.URL = r.RedactURL(entry.Request.URL)
+	entry.Request.Headers = r.RedactValues(entry.Request.Headers)
+	entry.Request.QueryParams = r.RedactValues(entry.Request.QueryParams)
+	entry.Request.Body = r.RedactValue(entry.Request.Body)
+	entry.Response.Headers = r.RedactValues(entry.Response.Headers)
+	entry.Response.Body = r.RedactValue(entry.Response.Body)
+}
+
+// RedactValues 对 Header 或查询参数脱敏，键名大小写不敏感
+func (r *Redactor) RedactValues(values map[string]string) map[string]string {
+	for k, v := range values {
+		if rule := r.matchKey(k); rule != nil {
+			values[k] = r.maskString(rule, v)
+			continue
+		}
+		values[k] = r.redactString(v)
+	}
+	return values
+}
+
+// RedactURL 对 URL 中的查询参数脱敏，保留参数原有顺序
+func (r *Redactor) RedactURL(rawURL string) string {
+	base, query, ok := strings.Cut(rawURL, "?")
+	if !ok || query == "" {
+		return rawURL
+	}
+	parts := strings.Split(query, "&")
+	for i, part := range parts {
+		k, v, _ := strings.Cut(part, "=")
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			key = k
+		}
+		if rule := r.matchKey(key); rule != nil {
+			value, err := url.QueryUnescape(v)
+			if err != nil {
+				value = v
+			}
+			parts[i] = k + "=" + url.QueryEscape(r.maskString(rule, value))
+		}
+	}
+	return base + "?" + strings.Join(parts, "&")
+}
+
+// RedactValue 对已解码的 Body 递归脱敏
+func (r *Redactor) RedactValue(v interface{}) interface{} {
+	return r.walk(v, nil)
+}
+
+func (r *Redactor) walk(v interface{}, p []string) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			childPath := append(p[:len(p):len(p)], strings.ToLower(k))
+			if rule := r.matchKey(k); rule != nil {
+				val[k] = r.mask(rule, child)
+			} else if rule := r.matchPath(childPath); rule != nil {
+				val[k] = r.mask(rule, child)
+			} else {
+				val[k] = r.walk(child, childPath)
+			}
+		}
+		return val
+	case []interface{}:
+		for i, child := range val {
+			childPath := append(p[:len(p):len(p)], strconv.Itoa(i))
+			if rule := r.matchPath(childPath); rule != nil {
+				val[i] = r.mask(rule, child)
+			} else {
+				val[i] = r.walk(child, childPath)
+			}
+		}
+		return val
+	case string:
+		return r.redactString(val)
+	case json.Number:
+		if s := r.redactString(val.String()); s != val.String() {
+			return s
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// redactString 处理普通字符串：先按键名规则清理内嵌的键值对，再应用值正则
+func (r *Redactor) redactString(s string) string {
+	for _, rule := range r.keyRules {
+		for _, re := range rule.raw {
+			s = re.ReplaceAllStringFunc(s, func(m string) string {
+				sub := re.FindStringSubmatch(m)
+				return sub[1] + r.maskString(rule, sub[2])
+			})
+		}
+	}
+	for _, rule := range r.valueRules {
+		s = rule.value.ReplaceAllStringFunc(s, func(m string) string {
+			return r.maskString(rule, m)
+		})
+	}
+	return s
+}
+
+func (r *Redactor) matchKey(key string) *compiledRule {
+	key = strings.ToLower(key)
+	for _, rule := range r.keyRules {
+		if ok, _ := path.Match(rule.key, key); ok {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (r *Redactor) matchPath(p []string) *compiledRule {
+	for _, rule := range r.pathRules {
+		if len(rule.segments) != len(p) {
+			continue
+		}
+		matched := true
+		for i, seg := range rule.segments {
+			if ok, _ := path.Match(seg, p[i]); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rule
+		}
+	}
+	return nil
+}
+
+// mask 对任意类型的值应用脱敏策略
+func (r *Redactor) mask(rule *compiledRule, v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return r.maskString(rule, val)
+	case json.Number:
+		return r.maskString(rule, val.String())
+	default:
+		if rule.Strategy == MaskHash {
+			b, _ := json.Marshal(val)
+			return r.maskString(rule, string(b))
+		}
+		return r.placeholder
+	}
+}
+
+func (r *Redactor) maskString(rule *compiledRule, s string) string {
+	switch rule.Strategy {
+	case MaskHash:
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(s))
+		return "hash:" + hex.EncodeToString(mac.Sum(nil))[:16]
+	case MaskPartial:
+		runes := []rune(s)
+		if len(runes) <= rule.KeepPrefix+rule.KeepSuffix {
+			return r.placeholder
+		}
+		return string(runes[:rule.KeepPrefix]) + r.placeholder + string(runes[len(runes)-rule.KeepSuffix:])
+	default:
+		return r.placeholder
+	}
+}
+
+// decodeBody 将捕获到的 Body 解码为可记录的值：
+// 表单解析为对象，合法 JSON 保持结构，其他内容保留为字符串
+func decodeBody(body []byte, contentType string) interface{} {
+	if len(body) == 0 {
+		return nil
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	if strings.EqualFold(strings.TrimSpace(mediaType), "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			form := make(map[string]interface{}, len(values))
+			for k, v := range values {
+				if len(v) == 1 {
+					form[k] = v[0]
+					continue
+				}
+				list := make([]interface{}, len(v))
+				for i := range v {
+					list[i] = v[i]
+				}
+				form[k] = list
+			}
+			return form
+		}
+	}
+
+	if json.Valid(body) {
+		var data interface{}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&data); err == nil {
+			return data
+		}
+	}
+	return string(body) // 如果不是 JSON，则返回原始字符串
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+)
+
+func TestRedactNestedJSON(t *testing.T) {
+	r := DefaultRedactor()
+	body := decodeBody([]byte(`{"user":{"Password":"x","name":"bob"},"items":[{"access_token":"t1"}]}`), "application/json")
+	got := r.RedactValue(body).(map[string]interface{})
+
+	user := got["user"].(map[string]interface{})
+	if user["Password"] != DefaultPlaceholder || user["name"] != "bob" {
+		t.Errorf("嵌套字段脱敏错误: %v", user)
+	}
+	item := got["items"].([]interface{})[0].(map[string]interface{})
+	if item["access_token"] != DefaultPlaceholder {
+		t.Errorf("数组中的对象应被脱敏: %v", item)
+	}
+}
+
+func TestRedactRulesAndStrategies(t *testing.T) {
+	r, err := NewRedactor(RedactorConfig{
+		HashKey: "k",
+		Rules: []RedactRule{
+			{Path: "$.cards[*].number", Strategy: MaskPartial, KeepSuffix: 4},
+			{Key: "email", Strategy: MaskHash},
+			{ValuePattern: `\d{3}-\d{4}`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("编译规则失败: %v", err)
+	}
+
+	body := decodeBody([]byte(`{"cards":[{"number":"4111111111111111"}],"email":"a@b.c","note":"call 555-1234"}`), "application/json")
+	got := r.RedactValue(body).(map[string]interface{})
+
+	card := got["cards"].([]interface{})[0].(map[string]interface{})
+	if card["number"] != "***1111" {
+		t.Errorf("期望部分保留，得到 %v", card["number"])
+	}
+	if h, _ := got["email"].(string); !strings.HasPrefix(h, "hash:") {
+		t.Errorf("期望哈希值，得到 %v", got["email"])
+	}
+	if got["note"] != "call ***" {
+		t.Errorf("期望按正则替换，得到 %v", got["note"])
+	}
+}
+
+func TestRedactEntry(t *testing.T) {
+	entry := domain.LogEntry{
+		Request: domain.RequestInfo{
+			URL:         "/login?user=bob&token=abc",
+			Headers:     map[string]string{"Authorization": "Bearer x", "Cookie": "sid=1", "Accept": "*/*"},
+			QueryParams: map[string]string{"Token": "abc"},
+			Body:        decodeBody([]byte("username=bob&password=secret"), "application/x-www-form-urlencoded"),
+		},
+		Response: domain.ResponseInfo{
+			Headers: map[string]string{"Set-Cookie": "sid=2"},
+			Body:    decodeBody([]byte(`{"password":"p","data":"trunc`), "application/json"),
+		},
+	}
+	DefaultRedactor().RedactEntry(&entry)
+
+	if entry.Request.URL != "/login?user=bob&token=%2A%2A%2A" {
+		t.Errorf("URL 查询参数未脱敏: %s", entry.Request.URL)
+	}
+	if entry.Request.Headers["Authorization"] != DefaultPlaceholder || entry.Request.Headers["Cookie"] != DefaultPlaceholder ||
+		entry.Request.Headers["Accept"] != "*/*" {
+		t.Errorf("请求头脱敏错误: %v", entry.Request.Headers)
+	}
+	if entry.Request.QueryParams["Token"] != DefaultPlaceholder || entry.Response.Headers["Set-Cookie"] != DefaultPlaceholder {
+		t.Error("查询参数或响应头未脱敏")
+	}
+	if form := entry.Request.Body.(map[string]interface{}); form["password"] != DefaultPlaceholder {
+		t.Errorf("表单 Body 未脱敏: %v", form)
+	}
+	if body := entry.Response.Body.(string); body != `{"password":"***","data":"trunc` {
+		t.Errorf("截断的 JSON 应按文本脱敏: %s", body)
+	}
+}
+
+func TestNewRedactorRejectsInvalidRules(t *testing.T) {
+	if _, err := NewRedactor(RedactorConfig{Rules: []RedactRule{{}}}); err == nil {
+		t.Error("空规则应返回错误")
+	}
+	if _, err := NewRedactor(RedactorConfig{Rules: []RedactRule{{ValuePattern: "("}}}); err == nil {
+		t.Error("非法正则应返回错误")
+	}
+	if _, err := NewRedactor(RedactorConfig{Rules: []RedactRule{{Key: "card", Strategy: MaskPartial, KeepPrefix: -1}}}); err == nil {
+		t.Error("负数的 KeepPrefix 应返回错误")
+	}
+	if _, err := NewRedactor(RedactorConfig{Rules: []RedactRule{{Key: "card", KeepSuffix: 4}}}); err == nil {
+		t.Error("非 partial 策略设置 KeepSuffix 应返回错误")
+	}
+}
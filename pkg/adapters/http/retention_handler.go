@@ -0,0 +1,50 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetentionHandler 查看保留策略、预览和手动触发过期日志清理
+type RetentionHandler struct {
+	job *storage.RetentionJob
+}
+
+func NewRetentionHandler(job *storage.RetentionJob) *RetentionHandler {
+	return &RetentionHandler{job: job}
+}
+
+// GetPolicy 返回保留规则和最近一次清理的结果
+func (h *RetentionHandler) GetPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"rules":    h.job.Policy(),
+		"last_run": h.job.LastReport(),
+	})
+}
+
+// Preview 按当前策略统计将被删除的日志数量，不删除数据
+func (h *RetentionHandler) Preview(c *gin.Context) {
+	report, err := h.job.Preview(c.Request.Context())
+	if err != nil {
+		body := errorBody(CodeInternal, err.Error())
+		body["report"] = report
+		c.JSON(http.StatusInternalServerError, body)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// Purge 立即执行一次清理，返回各规则删除的数量
+func (h *RetentionHandler) Purge(c *gin.Context) {
+	report, err := h.job.Purge(c.Request.Context())
+	if err != nil {
+		body := errorBody(CodeInternal, err.Error())
+		body["report"] = report
+		c.JSON(http.StatusInternalServerError, body)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/storage"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRetentionHandler(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	repo.Save(context.Background(), domain.LogEntry{ID: "old", Timestamp: time.Now().AddDate(0, 0, -30)})
+	repo.Save(context.Background(), domain.LogEntry{ID: "new", Timestamp: time.Now()})
+	job, err := storage.NewRetentionJob(repo, storage.RetentionConfig{Policy: domain.RetentionPolicy{{KeepDays: 7}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewRetentionHandler(job)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	role := "viewer"
+	admin := r.Group("/api/retention", func(c *gin.Context) { c.Set("role", role) }, RequireRole("admin"))
+	admin.GET("", h.GetPolicy)
+	admin.POST("/preview", h.Preview)
+	admin.POST("/purge", h.Purge)
+
+	if w := serve(r, http.MethodPost, "/api/retention/purge", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("非管理员应返回 403，得到 %d", w.Code)
+	}
+	role = "admin"
+
+	var report storage.RetentionReport
+	w := serve(r, http.MethodPost, "/api/retention/preview", "")
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &report) != nil || !report.DryRun || report.Deleted != 1 {
+		t.Fatalf("预览: %d %s", w.Code, w.Body.String())
+	}
+	if e, _ := repo.FindByID(context.Background(), "old"); e == nil {
+		t.Fatal("预览不应删除数据")
+	}
+
+	w = serve(r, http.MethodPost, "/api/retention/purge", "")
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &report) != nil || report.DryRun || report.Deleted != 1 {
+		t.Fatalf("清理: %d %s", w.Code, w.Body.String())
+	}
+	if e, _ := repo.FindByID(context.Background(), "old"); e != nil {
+		t.Error("过期日志应被删除")
+	}
+
+	var policy struct {
+		Rules   domain.RetentionPolicy   `json:"rules"`
+		LastRun *storage.RetentionReport `json:"last_run"`
+	}
+	w = serve(r, http.MethodGet, "/api/retention", "")
+	if json.Unmarshal(w.Body.Bytes(), &policy) != nil || len(policy.Rules) != 1 || policy.LastRun == nil || policy.LastRun.Deleted != 1 {
+		t.Errorf("策略: %s", w.Body.String())
+	}
+}
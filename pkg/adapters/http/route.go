@@ -0,0 +1,42 @@
+package http
+
+import (
+	"strings"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/utils"
+)
+
+// NormalizePath 把路径中的数字段和 UUID 段替换为 :id，作为没有路由模板时的路由，
+// 例如 /users/123/orders/9b2e4c1a-5f3d-4e7b-8a6c-0d1e2f3a4b5c 变为 /users/:id/orders/:id
+func NormalizePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if isNumeric(seg) || utils.IsValidUUID(seg) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// patternRoute 从 http.ServeMux 的匹配模式中取出路径部分，如 "GET example.com/users/{id}" 返回 /users/{id}
+func patternRoute(pattern string) string {
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		pattern = strings.TrimLeft(pattern[i:], " \t")
+	}
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
+}
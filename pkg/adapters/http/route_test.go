@@ -0,0 +1,86 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNormalizePath(t *testing.T) {
+	cases := map[string]string{
+		"":            "",
+		"/":           "/",
+		"/users/123":  "/users/:id",
+		"/users/123/": "/users/:id/",
+		"/v1/users":   "/v1/users",
+		"/users/12a":  "/users/12a",
+		"/orders/9b2e4c1a-5f3d-4e7b-8a6c-0d1e2f3a4b5c/items/7": "/orders/:id/items/:id",
+		"/orders/9B2E4C1A-5F3D-4E7B-8A6C-0D1E2F3A4B5C":         "/orders/:id",
+	}
+	for path, want := range cases {
+		if got := NormalizePath(path); got != want {
+			t.Errorf("NormalizePath(%q) = %q，期望 %q", path, got, want)
+		}
+	}
+
+	for pattern, want := range map[string]string{
+		"/items/{id}":                 "/items/{id}",
+		"GET /items/{id}":             "/items/{id}",
+		"POST  example.com/items/{$}": "/items/{$}",
+		"example.com/":                "/",
+	} {
+		if got := patternRoute(pattern); got != want {
+			t.Errorf("patternRoute(%q) = %q，期望 %q", pattern, got, want)
+		}
+	}
+}
+
+func TestRouteCapture(t *testing.T) {
+	repo := &captureRepo{}
+	l := logger.NewAsyncLogger(repo, 10)
+	m := NewLogMiddleware(l)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(m.Handler())
+	r.GET("/users/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	std := m.HTTPMiddleware()(mux)
+
+	for _, req := range []struct {
+		handler http.Handler
+		target  string
+	}{
+		{r, "/users/42"},
+		{r, "/missing/7"},
+		{std, "/items/9b2e4c1a-5f3d-4e7b-8a6c-0d1e2f3a4b5c"},
+		{std, "/other/7"},
+	} {
+		req.handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, req.target, nil))
+	}
+	l.Close()
+
+	routes := map[string][2]string{}
+	for _, e := range repo.entries {
+		routes[e.Request.URL] = [2]string{e.Route, e.Handler}
+	}
+	if got := routes["/users/42"]; got[0] != "/users/:id" || !strings.Contains(got[1], "TestRouteCapture") {
+		t.Errorf("gin 应记录路由模板和处理函数名，得到 %q", got)
+	}
+	if got := routes["/missing/7"]; got != [2]string{"/missing/:id", ""} {
+		t.Errorf("未匹配的 gin 路由应使用规范化后的路径，得到 %q", got)
+	}
+	if got := routes["/items/9b2e4c1a-5f3d-4e7b-8a6c-0d1e2f3a4b5c"]; got != [2]string{"/items/{id}", ""} {
+		t.Errorf("net/http 应记录 ServeMux 的模式，得到 %q", got)
+	}
+	if got := routes["/other/7"]; got != [2]string{"/other/:id", ""} {
+		t.Errorf("ServeMux 未匹配时应使用规范化后的路径，得到 %q", got)
+	}
+}
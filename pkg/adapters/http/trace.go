@@ -0,0 +1,72 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/utils"
+)
+
+// 链路追踪相关的 HTTP 头
+const (
+	HeaderTraceID     = "X-Trace-Id"
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+// TraceMode 控制中间件读取和输出哪些链路头
+type TraceMode int
+
+const (
+	TraceModeBoth   TraceMode = iota // 同时支持 traceparent 和 X-Trace-Id (默认)
+	TraceModeW3C                     // 仅使用 traceparent/tracestate
+	TraceModeLegacy                  // 仅使用 X-Trace-Id (兼容模式)
+)
+
+// resolveTrace 根据请求头确定 Track ID 以及本次请求的 span
+// traceparent 优先；只有 X-Trace-Id 时，若其为 UUID 则直接换算为 trace-id
+func resolveTrace(h http.Header, mode TraceMode) (string, utils.TraceContext) {
+	var parent utils.TraceContext
+	var trackID string
+
+	if mode != TraceModeLegacy {
+		if tc, err := utils.ParseTraceparent(h.Get(HeaderTraceparent)); err == nil {
+			parent = tc
+			parent.State = h.Get(HeaderTracestate)
+		}
+	}
+	if mode != TraceModeW3C {
+		trackID = h.Get(HeaderTraceID)
+	}
+
+	switch {
+	case parent.TraceID != "" && trackID == "":
+		trackID = utils.TrackIDFromTraceID(parent.TraceID)
+	case parent.TraceID == "" && trackID != "":
+		if id, ok := utils.TraceIDFromTrackID(trackID); ok {
+			parent.TraceID = id
+		} else {
+			parent.TraceID = utils.GenerateTraceID()
+		}
+		parent.Flags = utils.FlagSampled
+	case parent.TraceID == "":
+		trackID = utils.GenerateTrackID()
+		parent.TraceID, _ = utils.TraceIDFromTrackID(trackID)
+		parent.Flags = utils.FlagSampled
+	}
+
+	span := parent.NewChild()
+	return trackID, span
+}
+
+// writeTraceHeaders 输出本次请求的链路头，供调用方继续传播
+func writeTraceHeaders(h http.Header, trackID string, span utils.TraceContext, mode TraceMode) {
+	if mode != TraceModeW3C {
+		h.Set(HeaderTraceID, trackID)
+	}
+	if mode != TraceModeLegacy {
+		h.Set(HeaderTraceparent, span.Traceparent())
+		if span.State != "" {
+			h.Set(HeaderTracestate, span.State)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/logger"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/utils"
+)
+
+// Transport 包装 http.RoundTripper，记录服务发出的请求 (Kind 为 client)，
+// 并把 context 中的链路信息传播给下游服务
+type Transport struct {
+	base   http.RoundTripper
+	logger *logger.AsyncLogger
+	opts   options
+}
+
+// NewTransport 创建出站请求记录器，base 为 nil 时使用 http.DefaultTransport
+func NewTransport(l *logger.AsyncLogger, base http.RoundTripper, opts ...Option) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{base: base, logger: l, opts: newOptions(opts)}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+
+	// 在当前请求的 span 下创建子 span；没有上游时作为新链路的根
+	trackID, parent, ok := utils.TraceFromContext(req.Context())
+	var span utils.TraceContext
+	if ok {
+		span = parent.NewChild()
+	} else {
+		trackID = utils.GenerateTrackID()
+		span.TraceID, _ = utils.TraceIDFromTrackID(trackID)
+		span.SpanID = utils.GenerateSpanID()
+		span.Flags = utils.FlagSampled
+	}
+
+	// RoundTripper 不能修改调用方的请求，链路头和 Body 包装都作用在副本上
+	out := req.Clone(req.Context())
+	writeTraceHeaders(out.Header, trackID, span, t.opts.traceMode)
+	reqBody := t.opts.wrapRequestBody(out)
+
+	entry := t.opts.newEntry(domain.KindClient, trackID, span, start, out, reqBody)
+
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		entry.Level = "error"
+		entry.Message = err.Error()
+		t.opts.finish(&entry)
+		t.logger.Log(entry)
+		return nil, err
+	}
+
+	// 响应体由调用方读取，读完或关闭时再写入日志
+	body := &responseBodyCapture{src: resp.Body, contentLength: resp.ContentLength}
+	body.buf.limit = t.opts.responseLimit(resp.Header)
+	body.done = func() {
+		// 请求体在 RoundTrip 返回后才会被读完，这里再更新一次大小
+		if reqBody != nil {
+			entry.Request.Size = reqBody.Size()
+			entry.Request.BodyTruncated = reqBody.Truncated()
+		}
+		t.opts.setResponse(&entry, resp.StatusCode, resp.Header, &body.buf)
+		if !body.eof && body.contentLength > entry.Response.Size {
+			entry.Response.Size = body.contentLength
+			entry.Response.BodyTruncated = body.buf.limit > 0
+		}
+		t.opts.finish(&entry)
+		t.logger.Log(entry)
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// responseBodyCapture 在调用方读取响应体时按上限捕获，读到 EOF 或关闭时触发 done
+type responseBodyCapture struct {
+	src           io.ReadCloser
+	buf           limitedBuffer
+	contentLength int64
+	eof           bool
+	once          sync.Once
+	done          func()
+}
+
+func (b *responseBodyCapture) Read(p []byte) (int, error) {
+	n, err := b.src.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.eof = true
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *responseBodyCapture) Close() error {
+	err := b.src.Close()
+	b.once.Do(b.done)
+	return err
+}
@@ -2,26 +2,98 @@ package logger
 
 import (
 	"context"
+	"errors"
+	"io/fs"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
 	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
 )
 
+// 批量写入的默认参数
+const (
+	DefaultBatchSize     = 100
+	DefaultFlushInterval = time.Second
+)
+
 // AsyncLogger 异步日志记录器
 type AsyncLogger struct {
-	logChan chan domain.LogEntry
-	repo    ports.LogRepository
-	wg      sync.WaitGroup
+	logChan       chan domain.LogEntry
+	repo          ports.LogWriter
+	wg            sync.WaitGroup
+	batchSize     int
+	flushInterval time.Duration
+	policy        OverflowPolicy
+	blockTimeout  time.Duration
+	evictMu       sync.Mutex // KeepErrors 策略下串行化所有发送方，见 tryKeepErrors
+	counters      counters
+
+	// 磁盘暂存，未配置时为 nil
+	spool      *Spool
+	degraded   atomic.Bool // 存储写入失败后置位，期间新批次直接写入暂存区
+	stopReplay chan struct{}
+	replayWg   sync.WaitGroup
+
+	// 关闭状态：mu 保证 Log 不会向已关闭的通道发送
+	mu           sync.RWMutex
+	closed       bool
+	done         chan struct{}   // 开始关闭时关闭，用于中断 Block 策略的等待
+	ctx          context.Context // 写入存储使用的 context，关闭超时后取消
+	cancel       context.CancelFunc
+	shutdownOnce sync.Once
+	unflushed    int
+	shutdownErr  error
+}
+
+// Option 用于定制 AsyncLogger
+type Option func(*AsyncLogger)
+
+// WithBatchSize 设置每批写入的最大条数，达到后立即写入
+func WithBatchSize(n int) Option {
+	return func(l *AsyncLogger) {
+		if n > 0 {
+			l.batchSize = n
+		}
+	}
+}
+
+// WithFlushInterval 设置批次未满时的最长等待时间
+func WithFlushInterval(d time.Duration) Option {
+	return func(l *AsyncLogger) {
+		if d > 0 {
+			l.flushInterval = d
+		}
+	}
+}
+
+// WithSpool 启用磁盘暂存：写入失败或缓冲区溢出的日志追加到暂存区，存储恢复后以指数退避重放
+func WithSpool(s *Spool) Option {
+	return func(l *AsyncLogger) {
+		l.spool = s
+	}
 }
 
-func NewAsyncLogger(repo ports.LogRepository, bufferSize int) *AsyncLogger {
+func NewAsyncLogger(repo ports.LogWriter, bufferSize int, opts ...Option) *AsyncLogger {
 	l := &AsyncLogger{
-		logChan: make(chan domain.LogEntry, bufferSize),
-		repo:    repo,
+		logChan:       make(chan domain.LogEntry, bufferSize),
+		repo:          repo,
+		batchSize:     DefaultBatchSize,
+		flushInterval: DefaultFlushInterval,
+		blockTimeout:  DefaultBlockTimeout,
+		done:          make(chan struct{}),
+	}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(l)
 	}
 	l.startWorker()
+	if l.spool != nil {
+		l.stopReplay = make(chan struct{})
+		l.startReplay()
+	}
 	return l
 }
 
@@ -29,26 +101,219 @@ func (l *AsyncLogger) startWorker() {
 	l.wg.Add(1)
 	go func() {
 		defer l.wg.Done()
-		for entry := range l.logChan {
-			// 这里可以添加重试逻辑
-			if err := l.repo.Save(context.Background(), entry); err != nil {
-				log.Printf("Failed to save log entry: %v", err)
+		ticker := time.NewTicker(l.flushInterval)
+		defer ticker.Stop()
+
+		batch := make([]domain.LogEntry, 0, l.batchSize)
+		for {
+			select {
+			case entry, ok := <-l.logChan:
+				if !ok {
+					l.flush(batch)
+					return
+				}
+				batch = append(batch, entry)
+				if len(batch) >= l.batchSize {
+					l.flush(batch)
+					batch = batch[:0]
+				}
+			case <-ticker.C:
+				if len(batch) > 0 {
+					l.flush(batch)
+					batch = batch[:0]
+				}
 			}
 		}
 	}()
 }
 
-// Log 将日志条目发送到通道
-func (l *AsyncLogger) Log(entry domain.LogEntry) {
+// flush 写入一批日志，失败时转存到暂存区
+func (l *AsyncLogger) flush(batch []domain.LogEntry) {
+	if len(batch) == 0 {
+		return
+	}
+	if l.ctx.Err() != nil || (l.spool != nil && l.degraded.Load()) {
+		// 关闭已超时或存储处于降级状态，不再尝试写入存储
+		if l.spool == nil || !l.spoolEntries(batch) {
+			l.counters.dropped.Add(uint64(len(batch)))
+		}
+		return
+	}
+	failed, err := l.save(batch)
+	if err == nil {
+		return
+	}
+	if l.spool == nil {
+		log.Printf("Failed to save %d log entries: %v", len(failed), err)
+		l.counters.dropped.Add(uint64(len(failed)))
+		return
+	}
+	log.Printf("Failed to save %d log entries, spooling to disk: %v", len(failed), err)
+	l.degraded.Store(true)
+	if !l.spoolEntries(failed) {
+		l.counters.dropped.Add(uint64(len(failed)))
+	}
+}
+
+// save 写入一批日志，存储支持 ports.BatchSaver 时一次写入
+// 返回未写入的条目；SaveBatch 返回 *ports.PartialSaveError 时只有其中的 Failed 未写入
+func (l *AsyncLogger) save(batch []domain.LogEntry) ([]domain.LogEntry, error) {
+	ctx := l.ctx
+	if saver, ok := l.repo.(ports.BatchSaver); ok {
+		err := saver.SaveBatch(ctx, batch)
+		if err == nil {
+			l.counters.saved.Add(uint64(len(batch)))
+			return nil, nil
+		}
+		failed := batch
+		var partial *ports.PartialSaveError
+		if errors.As(err, &partial) {
+			failed = partial.Failed
+		}
+		l.counters.saved.Add(uint64(len(batch) - len(failed)))
+		l.counters.failed.Add(uint64(len(failed)))
+		return failed, err
+	}
+	for i, entry := range batch {
+		if err := l.repo.Save(ctx, entry); err != nil {
+			l.counters.failed.Add(uint64(len(batch) - i))
+			return batch[i:], err
+		}
+		l.counters.saved.Add(1)
+	}
+	return nil, nil
+}
+
+// spoolEntries 写入暂存区，返回是否成功
+func (l *AsyncLogger) spoolEntries(entries []domain.LogEntry) bool {
+	if err := l.spool.Append(entries...); err != nil {
+		log.Printf("Failed to spool %d log entries, dropping: %v", len(entries), err)
+		return false
+	}
+	l.counters.spooled.Add(uint64(len(entries)))
+	return true
+}
+
+// startReplay 启动重放协程：按顺序把暂存分段写回存储，失败时指数退避
+func (l *AsyncLogger) startReplay() {
+	l.replayWg.Add(1)
+	go func() {
+		defer l.replayWg.Done()
+		backoff := l.spool.cfg.MinBackoff
+		wait := backoff
+		for {
+			select {
+			case <-l.stopReplay:
+				return
+			case <-time.After(wait):
+			}
+
+			seg, entries, ok, err := l.spool.oldest()
+			if errors.Is(err, fs.ErrNotExist) {
+				_ = l.spool.remove(seg)
+				wait = 0
+				continue
+			}
+			if err != nil {
+				log.Printf("Failed to read spool: %v", err)
+				wait = backoff
+				continue
+			}
+			if !ok {
+				// 暂存区已清空，恢复直接写入
+				l.degraded.Store(false)
+				backoff = l.spool.cfg.MinBackoff
+				wait = backoff
+				continue
+			}
+			if len(entries) > 0 {
+				failed, err := l.save(entries)
+				if err != nil && len(failed) < len(entries) && l.spool.Append(failed...) == nil {
+					// 部分条目已写入，只把未写入的条目重新暂存，避免重放时重复写入
+					err = nil
+				}
+				if err != nil {
+					wait = backoff
+					backoff *= 2
+					if backoff > l.spool.cfg.MaxBackoff {
+						backoff = l.spool.cfg.MaxBackoff
+					}
+					continue
+				}
+			}
+			if err := l.spool.remove(seg); err != nil {
+				log.Printf("Failed to remove replayed spool segment: %v", err)
+			}
+			backoff = l.spool.cfg.MinBackoff
+			wait = 0
+		}
+	}()
+}
+
+// Log 将日志条目发送到通道，缓冲区已满时按溢出策略处理
+// 条目最终被丢弃时返回 ErrBufferFull，开始关闭后返回 ErrClosed
+func (l *AsyncLogger) Log(entry domain.LogEntry) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		l.counters.rejected.Add(1)
+		return ErrClosed
+	}
+	if l.policy == KeepErrors {
+		return l.logKeepErrors(entry)
+	}
 	select {
 	case l.logChan <- entry:
+		l.counters.enqueued.Add(1)
+		return nil
 	default:
-		log.Printf("Log buffer full, dropping log: %s", entry.TrackID)
+		return l.enqueueOnOverflow(entry)
 	}
 }
 
-// Close 关闭日志记录器并等待所有日志处理完成
+// Shutdown 停止接收新日志，并在 ctx 结束前写完缓冲区中的日志
+// ctx 结束后取消正在进行的写入，剩余条目转存到暂存区或丢弃，并返回 ctx.Err()
+// 返回值 unflushed 为关闭期间未能写入存储的条目数 (含转存到暂存区的条目)
+// 重复调用返回第一次的结果
+func (l *AsyncLogger) Shutdown(ctx context.Context) (int, error) {
+	l.shutdownOnce.Do(func() {
+		before := l.counters.dropped.Load() + l.counters.spooled.Load()
+
+		// 先关闭 done 让阻塞在 Block 策略上的 Log 返回，再等待所有 Log 释放读锁
+		close(l.done)
+		l.mu.Lock()
+		l.closed = true
+		close(l.logChan)
+		l.mu.Unlock()
+
+		finished := make(chan struct{})
+		go func() {
+			l.wg.Wait()
+			close(finished)
+		}()
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			l.shutdownErr = ctx.Err()
+			l.cancel()
+			<-finished
+		}
+
+		// 未重放完的暂存数据保留在磁盘上，下次启动时继续重放
+		if l.spool != nil {
+			close(l.stopReplay)
+			l.replayWg.Wait()
+			if err := l.spool.Close(); err != nil {
+				log.Printf("Failed to close spool: %v", err)
+			}
+		}
+		l.cancel()
+		l.unflushed = int(l.counters.dropped.Load() + l.counters.spooled.Load() - before)
+	})
+	return l.unflushed, l.shutdownErr
+}
+
+// Close 关闭日志记录器并等待所有日志处理完成，等同于不设期限的 Shutdown
 func (l *AsyncLogger) Close() {
-	close(l.logChan)
-	l.wg.Wait()
+	_, _ = l.Shutdown(context.Background())
 }
@@ -0,0 +1,279 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+)
+
+// batchRepo 记录每次 SaveBatch 的批次大小
+type batchRepo struct {
+	mu      sync.Mutex
+	batches []int
+	saved   int
+}
+
+func (r *batchRepo) Save(ctx context.Context, entry domain.LogEntry) error {
+	return r.SaveBatch(ctx, []domain.LogEntry{entry})
+}
+
+func (r *batchRepo) SaveBatch(ctx context.Context, entries []domain.LogEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, len(entries))
+	r.saved += len(entries)
+	return nil
+}
+
+func (r *batchRepo) FindByID(ctx context.Context, id string) (*domain.LogEntry, error) {
+	return nil, nil
+}
+
+func (r *batchRepo) FindByTrace(ctx context.Context, traceID string) ([]domain.LogEntry, error) {
+	return nil, nil
+}
+
+func (r *batchRepo) Search(ctx context.Context, query ports.LogSearchQuery) ([]domain.LogEntry, int64, error) {
+	return nil, 0, nil
+}
+
+func (r *batchRepo) snapshot() ([]int, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.batches...), r.saved
+}
+
+func TestAsyncLoggerFlushesBySize(t *testing.T) {
+	repo := &batchRepo{}
+	l := NewAsyncLogger(repo, 100, WithBatchSize(10), WithFlushInterval(time.Hour))
+	for i := 0; i < 25; i++ {
+		l.Log(domain.LogEntry{TrackID: "t"})
+	}
+	l.Close()
+
+	batches, saved := repo.snapshot()
+	if saved != 25 {
+		t.Fatalf("期望写入 25 条，得到 %d", saved)
+	}
+	if len(batches) != 3 || batches[0] != 10 || batches[1] != 10 || batches[2] != 5 {
+		t.Errorf("期望批次为 [10 10 5]，得到 %v", batches)
+	}
+}
+
+func TestAsyncLoggerFlushesByInterval(t *testing.T) {
+	repo := &batchRepo{}
+	l := NewAsyncLogger(repo, 100, WithBatchSize(50), WithFlushInterval(10*time.Millisecond))
+	defer l.Close()
+
+	l.Log(domain.LogEntry{TrackID: "t"})
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, saved := repo.snapshot(); saved == 1 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("批次未满时应在刷新间隔后写入")
+}
+
+// blockingRepo 在 release 关闭前阻塞写入，用于稳定地填满缓冲区
+type blockingRepo struct {
+	batchRepo
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+	names   []string
+}
+
+func (r *blockingRepo) SaveBatch(ctx context.Context, entries []domain.LogEntry) error {
+	r.once.Do(func() { close(r.started) })
+	<-r.release
+	for _, e := range entries {
+		r.names = append(r.names, e.TrackID)
+	}
+	return r.batchRepo.SaveBatch(ctx, entries)
+}
+
+func TestAsyncLoggerKeepErrorsPolicy(t *testing.T) {
+	repo := &blockingRepo{started: make(chan struct{}), release: make(chan struct{})}
+	l := NewAsyncLogger(repo, 2, WithBatchSize(1), WithOverflowPolicy(KeepErrors))
+
+	entry := func(name string, status int) domain.LogEntry {
+		return domain.LogEntry{TrackID: name, Response: domain.ResponseInfo{StatusCode: status}}
+	}
+	l.Log(entry("first", 200))
+	<-repo.started
+	l.Log(entry("a", 200))
+	l.Log(entry("b", 200))
+
+	if err := l.Log(entry("c", 200)); err != ErrBufferFull {
+		t.Errorf("成功请求应被丢弃，得到 %v", err)
+	}
+	if err := l.Log(entry("boom", 500)); err != nil {
+		t.Errorf("错误请求应挤掉最旧的条目，得到 %v", err)
+	}
+
+	close(repo.release)
+	l.Close()
+
+	stats := l.Stats()
+	if stats.Enqueued != 4 || stats.Dropped != 2 || stats.Saved != 3 {
+		t.Errorf("计数错误: %+v", stats)
+	}
+	if len(repo.names) != 3 || repo.names[1] != "b" || repo.names[2] != "boom" {
+		t.Errorf("期望写入 [first b boom]，得到 %v", repo.names)
+	}
+}
+
+func TestAsyncLoggerKeepErrorsEvictsNonErrorsFirst(t *testing.T) {
+	cases := []struct {
+		name     string
+		buffered []int // 缓冲区中条目的状态码，依次命名为 e0、e1 ...
+		wantErr  error
+		want     []string
+	}{
+		{"挤掉最旧的非错误条目", []int{500, 200, 502}, nil, []string{"first", "e0", "e2", "boom"}},
+		{"全部为错误请求时不挤出，等待超时后丢弃新条目", []int{500, 503}, ErrBufferFull, []string{"first", "e0", "e1"}},
+	}
+	for _, tc := range cases {
+		repo := &blockingRepo{started: make(chan struct{}), release: make(chan struct{})}
+		l := NewAsyncLogger(repo, len(tc.buffered), WithBatchSize(1), WithOverflowPolicy(KeepErrors), WithBlockTimeout(10*time.Millisecond))
+		entry := func(name string, status int) domain.LogEntry {
+			return domain.LogEntry{TrackID: name, Response: domain.ResponseInfo{StatusCode: status}}
+		}
+		l.Log(entry("first", 200))
+		<-repo.started
+		for i, status := range tc.buffered {
+			l.Log(entry(fmt.Sprintf("e%d", i), status))
+		}
+		if err := l.Log(entry("boom", 500)); err != tc.wantErr {
+			t.Errorf("%s: 期望 %v，得到 %v", tc.name, tc.wantErr, err)
+		}
+
+		close(repo.release)
+		l.Close()
+		if fmt.Sprint(repo.names) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: 期望写入 %v，得到 %v", tc.name, tc.want, repo.names)
+		}
+		if stats := l.Stats(); stats.Dropped != 1 {
+			t.Errorf("%s: 应只丢弃 1 条: %+v", tc.name, stats)
+		}
+	}
+}
+
+// 并发写入时已入队的错误请求都不会被挤掉，使用 -race 运行可检查数据竞争
+func TestAsyncLoggerKeepErrorsConcurrent(t *testing.T) {
+	repo := &blockingRepo{started: make(chan struct{}), release: make(chan struct{})}
+	l := NewAsyncLogger(repo, 8, WithBatchSize(1), WithOverflowPolicy(KeepErrors), WithBlockTimeout(time.Millisecond))
+	l.Log(domain.LogEntry{TrackID: "first"})
+	<-repo.started
+
+	var (
+		mu       sync.Mutex
+		accepted []string
+		wg       sync.WaitGroup
+	)
+	for p := 0; p < 8; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				e := domain.LogEntry{TrackID: fmt.Sprintf("p%d-%d", p, i), Response: domain.ResponseInfo{StatusCode: 200}}
+				if i%3 == 0 {
+					e.Response.StatusCode = 500
+				}
+				if err := l.Log(e); err == nil && isErrorEntry(e) {
+					mu.Lock()
+					accepted = append(accepted, e.TrackID)
+					mu.Unlock()
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+	close(repo.release)
+	l.Close()
+
+	saved := map[string]bool{}
+	for _, name := range repo.names {
+		saved[name] = true
+	}
+	if len(accepted) == 0 {
+		t.Fatal("应有错误请求入队")
+	}
+	for _, name := range accepted {
+		if !saved[name] {
+			t.Errorf("已入队的错误请求 %s 被挤掉", name)
+		}
+	}
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	for _, p := range []OverflowPolicy{DropNewest, DropOldest, Block, KeepErrors} {
+		got, err := ParseOverflowPolicy(p.String())
+		if err != nil || got != p {
+			t.Errorf("期望解析 %s，得到 %v %v", p, got, err)
+		}
+	}
+	if _, err := ParseOverflowPolicy("bogus"); err == nil {
+		t.Error("未知策略应返回错误")
+	}
+}
+
+// ctxRepo 在 ctx 取消前一直阻塞写入，模拟关闭时存储不可用
+type ctxRepo struct {
+	batchRepo
+}
+
+func (r *ctxRepo) SaveBatch(ctx context.Context, entries []domain.LogEntry) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestAsyncLoggerShutdownDeadline(t *testing.T) {
+	l := NewAsyncLogger(&ctxRepo{}, 10, WithBatchSize(2), WithFlushInterval(time.Hour))
+	for i := 0; i < 5; i++ {
+		if err := l.Log(domain.LogEntry{TrackID: "t"}); err != nil {
+			t.Fatalf("入队失败: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	unflushed, err := l.Shutdown(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("期望 DeadlineExceeded，得到 %v", err)
+	}
+	if unflushed != 5 {
+		t.Errorf("期望 5 条未写入，得到 %d", unflushed)
+	}
+
+	// 关闭后 Log 不应 panic，而是返回 ErrClosed
+	if err := l.Log(domain.LogEntry{TrackID: "late"}); err != ErrClosed {
+		t.Errorf("关闭后应返回 ErrClosed，得到 %v", err)
+	}
+	l.Close()
+	if stats := l.Stats(); stats.Rejected != 1 || stats.Dropped != 5 {
+		t.Errorf("计数错误: %+v", stats)
+	}
+}
+
+func TestAsyncLoggerShutdownDrains(t *testing.T) {
+	repo := &batchRepo{}
+	l := NewAsyncLogger(repo, 100, WithBatchSize(10), WithFlushInterval(time.Hour))
+	for i := 0; i < 15; i++ {
+		l.Log(domain.LogEntry{TrackID: "t"})
+	}
+	unflushed, err := l.Shutdown(context.Background())
+	if err != nil || unflushed != 0 {
+		t.Errorf("期望全部写入，得到 %d %v", unflushed, err)
+	}
+	if _, saved := repo.snapshot(); saved != 15 {
+		t.Errorf("期望写入 15 条，得到 %d", saved)
+	}
+}
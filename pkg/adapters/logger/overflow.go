@@ -0,0 +1,239 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+)
+
+// ErrBufferFull 缓冲区已满且按溢出策略丢弃了该条目
+var ErrBufferFull = errors.New("log buffer full")
+
+// ErrClosed 日志记录器已开始关闭，不再接收新的条目
+var ErrClosed = errors.New("logger is closed")
+
+// DefaultBlockTimeout Block 策略的默认等待时间
+const DefaultBlockTimeout = 100 * time.Millisecond
+
+// OverflowPolicy 缓冲区已满时的处理策略
+type OverflowPolicy int
+
+const (
+	DropNewest OverflowPolicy = iota // 丢弃新条目 (默认)
+	DropOldest                       // 挤掉缓冲区中最旧的条目
+	Block                            // 阻塞等待空位，超时后丢弃
+	KeepErrors                       // 丢弃成功请求，错误请求挤掉最旧的非错误条目
+)
+
+var overflowPolicyNames = map[OverflowPolicy]string{
+	DropNewest: "drop_newest",
+	DropOldest: "drop_oldest",
+	Block:      "block",
+	KeepErrors: "keep_errors",
+}
+
+func (p OverflowPolicy) String() string {
+	if name, ok := overflowPolicyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+}
+
+// ParseOverflowPolicy 解析 drop_newest、drop_oldest、block、keep_errors
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	for p, name := range overflowPolicyNames {
+		if strings.EqualFold(s, name) {
+			return p, nil
+		}
+	}
+	return DropNewest, fmt.Errorf("unknown overflow policy %q", s)
+}
+
+// WithOverflowPolicy 设置缓冲区已满时的处理策略
+func WithOverflowPolicy(p OverflowPolicy) Option {
+	return func(l *AsyncLogger) {
+		l.policy = p
+	}
+}
+
+// WithBlockTimeout 设置 Block 策略下 Log 的最长等待时间
+func WithBlockTimeout(d time.Duration) Option {
+	return func(l *AsyncLogger) {
+		if d > 0 {
+			l.blockTimeout = d
+		}
+	}
+}
+
+// Stats AsyncLogger 的运行计数
+type Stats struct {
+	Enqueued   uint64 `json:"enqueued"`    // 进入缓冲区的条目
+	Saved      uint64 `json:"saved"`       // 成功写入存储的条目 (含暂存重放)
+	Failed     uint64 `json:"failed"`      // 写入存储失败的条目，暂存重放的重试会重复计数
+	Dropped    uint64 `json:"dropped"`     // 被丢弃的条目
+	Spooled    uint64 `json:"spooled"`     // 写入磁盘暂存区的条目
+	Rejected   uint64 `json:"rejected"`    // 关闭后被拒绝的条目
+	Buffered   int    `json:"buffered"`    // 当前缓冲区中的条目
+	SpoolBytes int64  `json:"spool_bytes"` // 暂存区当前大小
+}
+
+type counters struct {
+	enqueued atomic.Uint64
+	saved    atomic.Uint64
+	failed   atomic.Uint64
+	dropped  atomic.Uint64
+	spooled  atomic.Uint64
+	rejected atomic.Uint64
+}
+
+// Stats 返回当前的运行计数
+func (l *AsyncLogger) Stats() Stats {
+	s := Stats{
+		Enqueued: l.counters.enqueued.Load(),
+		Saved:    l.counters.saved.Load(),
+		Failed:   l.counters.failed.Load(),
+		Dropped:  l.counters.dropped.Load(),
+		Spooled:  l.counters.spooled.Load(),
+		Rejected: l.counters.rejected.Load(),
+		Buffered: len(l.logChan),
+	}
+	if l.spool != nil {
+		s.SpoolBytes = l.spool.Size()
+	}
+	return s
+}
+
+// isErrorEntry 判断条目是否属于 KeepErrors 策略需要保留的错误请求
+func isErrorEntry(entry domain.LogEntry) bool {
+	return entry.Level == "error" || entry.Response.StatusCode >= 500
+}
+
+// enqueueOnOverflow 按溢出策略处理无法直接入队的条目，KeepErrors 策略由 logKeepErrors 处理
+func (l *AsyncLogger) enqueueOnOverflow(entry domain.LogEntry) error {
+	switch l.policy {
+	case Block:
+		timer := time.NewTimer(l.blockTimeout)
+		defer timer.Stop()
+		select {
+		case l.logChan <- entry:
+			l.counters.enqueued.Add(1)
+			return nil
+		case <-timer.C:
+		case <-l.done:
+		}
+	case DropOldest:
+		if l.evictAndEnqueue(entry) {
+			return nil
+		}
+	}
+	return l.discard(entry)
+}
+
+// evictAndEnqueue 挤掉缓冲区中最旧的条目后入队，与 worker 竞争失败时有限次重试
+func (l *AsyncLogger) evictAndEnqueue(entry domain.LogEntry) bool {
+	for i := 0; i < 3; i++ {
+		select {
+		case old := <-l.logChan:
+			_ = l.discard(old)
+		default:
+		}
+		select {
+		case l.logChan <- entry:
+			l.counters.enqueued.Add(1)
+			return true
+		default:
+		}
+	}
+	return false
+}
+
+// logKeepErrors 按 KeepErrors 策略入队，缓冲区中的错误请求不会被挤掉：
+// 缓冲区已满时丢弃成功请求；错误请求挤掉最旧的非错误条目，缓冲区全部为错误请求时
+// 在 blockTimeout 内等待 worker 取走条目后重试，超时后丢弃
+func (l *AsyncLogger) logKeepErrors(entry domain.LogEntry) error {
+	if l.tryKeepErrors(entry) {
+		return nil
+	}
+	if !isErrorEntry(entry) {
+		return l.discard(entry)
+	}
+
+	// 等待期间不直接向通道发送，否则会占用挤出时腾出的位置
+	timer := time.NewTimer(l.blockTimeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if l.tryKeepErrors(entry) {
+				return nil
+			}
+		case <-timer.C:
+			return l.discard(entry)
+		case <-l.done:
+			return l.discard(entry)
+		}
+	}
+}
+
+// tryKeepErrors 持有 evictMu 尝试入队，错误请求在缓冲区已满时挤掉最旧的非错误条目
+// KeepErrors 策略下所有发送方都持有 evictMu，挤出时取出的条目一定能放回缓冲区 (worker 只会取走条目)
+func (l *AsyncLogger) tryKeepErrors(entry domain.LogEntry) bool {
+	l.evictMu.Lock()
+	defer l.evictMu.Unlock()
+	select {
+	case l.logChan <- entry:
+		l.counters.enqueued.Add(1)
+		return true
+	default:
+	}
+	return isErrorEntry(entry) && l.evictNonErrorAndEnqueue(entry)
+}
+
+// evictNonErrorAndEnqueue 挤掉缓冲区中最旧的非错误条目后入队，没有非错误条目时不挤出并返回 false
+// 通道不支持删除中间的元素，因此先取出缓冲区中的条目，去掉被挤掉的一条后按原顺序放回。调用方需持有 evictMu
+func (l *AsyncLogger) evictNonErrorAndEnqueue(entry domain.LogEntry) bool {
+	pending := make([]domain.LogEntry, 0, cap(l.logChan))
+drain:
+	for len(pending) < cap(l.logChan) {
+		select {
+		case e := <-l.logChan:
+			pending = append(pending, e)
+		default:
+			break drain
+		}
+	}
+	free := len(pending) < cap(l.logChan) // worker 已取走条目，不必挤出
+	for _, e := range pending {
+		if !free && !isErrorEntry(e) {
+			_ = l.discard(e)
+			free = true
+			continue
+		}
+		// 取出的条目数不超过缓冲区容量，放回不会阻塞
+		l.logChan <- e
+	}
+	if !free {
+		return false
+	}
+	l.logChan <- entry
+	l.counters.enqueued.Add(1)
+	return true
+}
+
+// discard 处理无法进入缓冲区的条目：优先写入暂存区，否则丢弃并计数
+func (l *AsyncLogger) discard(entry domain.LogEntry) error {
+	if l.spool != nil && l.spoolEntries([]domain.LogEntry{entry}) {
+		return nil
+	}
+	if n := l.counters.dropped.Add(1); n == 1 || n%1000 == 0 {
+		log.Printf("Log buffer full, %d entries dropped so far (last: %s)", n, entry.TrackID)
+	}
+	return ErrBufferFull
+}
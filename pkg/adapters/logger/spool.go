@@ -0,0 +1,278 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/utils"
+)
+
+// ErrSpoolFull 暂存目录已达到大小上限
+var ErrSpoolFull = errors.New("spool is full")
+
+// 磁盘暂存的默认参数
+const (
+	DefaultSpoolMaxBytes     int64 = 256 << 20
+	DefaultSpoolSegmentBytes int64 = 8 << 20
+	DefaultSpoolMinBackoff         = time.Second
+	DefaultSpoolMaxBackoff         = time.Minute
+)
+
+const spoolSegmentExt = ".jsonl"
+
+// SpoolConfig 磁盘暂存配置
+type SpoolConfig struct {
+	Dir          string        // 暂存目录
+	MaxBytes     int64         // 所有分段的总大小上限，超出后拒绝新的条目
+	SegmentBytes int64         // 单个分段文件的大小上限
+	MinBackoff   time.Duration // 重放失败后的初始等待时间
+	MaxBackoff   time.Duration // 重放失败后的最长等待时间
+}
+
+type spoolSegment struct {
+	path string
+	size int64
+}
+
+// Spool 是 AsyncLogger 的预写暂存区：存储不可用或缓冲区溢出时，
+// 日志以 JSON Lines 追加到分段文件中，存储恢复后按顺序重放
+type Spool struct {
+	cfg SpoolConfig
+
+	mu         sync.Mutex
+	active     *os.File
+	activeSize int64
+	nextSeq    uint64
+	sealed     []spoolSegment // 按写入顺序排列，最旧的在前
+	totalSize  int64
+}
+
+// OpenSpool 打开暂存目录，上次运行遗留的分段会被当作待重放数据
+func OpenSpool(cfg SpoolConfig) (*Spool, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("spool dir is required")
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultSpoolMaxBytes
+	}
+	if cfg.SegmentBytes <= 0 {
+		cfg.SegmentBytes = DefaultSpoolSegmentBytes
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultSpoolMinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = DefaultSpoolMaxBackoff
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &Spool{cfg: cfg}
+	files, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	sizes := map[uint64]int64{}
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, spoolSegmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			return nil, err
+		}
+		seqs = append(seqs, seq)
+		sizes[seq] = info.Size()
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	for _, seq := range seqs {
+		s.sealed = append(s.sealed, spoolSegment{path: s.segmentPath(seq), size: sizes[seq]})
+		s.totalSize += sizes[seq]
+		s.nextSeq = seq + 1
+	}
+	return s, nil
+}
+
+func (s *Spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.cfg.Dir, fmt.Sprintf("%020d%s", seq, spoolSegmentExt))
+}
+
+// Append 将日志追加到当前分段，缺少 ID 的条目会补齐 ID 以保证重放幂等
+// 返回 nil 时数据已 fsync 到磁盘，进程崩溃或断电后不会丢失；代价是每次调用一次 fsync，
+// AsyncLogger 按批次调用，磁盘较慢时可增大 WithBatchSize
+func (s *Spool) Append(entries ...domain.LogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	var buf []byte
+	for _, entry := range entries {
+		if entry.ID == "" {
+			entry.ID = utils.GenerateEntryID()
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.totalSize+int64(len(buf)) > s.cfg.MaxBytes {
+		return ErrSpoolFull
+	}
+	if s.active == nil {
+		f, err := os.OpenFile(s.segmentPath(s.nextSeq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		s.active = f
+		s.activeSize = 0
+		s.nextSeq++
+		// 新建的分段文件需要同步目录项，否则断电后文件本身可能不存在
+		if err := syncDir(s.cfg.Dir); err != nil {
+			return err
+		}
+	}
+	// 单次 Write 写入整批数据；进程崩溃最多留下一行不完整的记录，读取时会跳过
+	n, err := s.active.Write(buf)
+	s.activeSize += int64(n)
+	s.totalSize += int64(n)
+	if err != nil {
+		return err
+	}
+	if err := s.active.Sync(); err != nil {
+		return err
+	}
+	if s.activeSize >= s.cfg.SegmentBytes {
+		return s.sealActive()
+	}
+	return nil
+}
+
+// syncDir 把目录项的变更 (新建文件) 同步到磁盘
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// sealActive 关闭当前分段，使其可以被重放；调用方需持有锁
+func (s *Spool) sealActive() error {
+	if s.active == nil {
+		return nil
+	}
+	f := s.active
+	s.active = nil
+	if s.activeSize == 0 {
+		f.Close()
+		return os.Remove(f.Name())
+	}
+	// 每次 Append 都已 fsync，封存时只需关闭文件
+	s.sealed = append(s.sealed, spoolSegment{path: f.Name(), size: s.activeSize})
+	return f.Close()
+}
+
+// oldest 读取最旧的分段；没有已封存的分段时会封存当前分段
+func (s *Spool) oldest() (spoolSegment, []domain.LogEntry, bool, error) {
+	s.mu.Lock()
+	if len(s.sealed) == 0 {
+		if err := s.sealActive(); err != nil {
+			s.mu.Unlock()
+			return spoolSegment{}, nil, false, err
+		}
+	}
+	if len(s.sealed) == 0 {
+		s.mu.Unlock()
+		return spoolSegment{}, nil, false, nil
+	}
+	seg := s.sealed[0]
+	s.mu.Unlock()
+
+	entries, err := readSpoolSegment(seg.path)
+	return seg, entries, true, err
+}
+
+// readSpoolSegment 逐行解析分段文件，跳过崩溃时写了一半的记录
+// 读取出错时返回错误而不是已读到的部分，避免调用方把分段当作已重放而删除
+func readSpoolSegment(path string) ([]domain.LogEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []domain.LogEntry
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var entry domain.LogEntry
+			if jsonErr := json.Unmarshal(line, &entry); jsonErr != nil {
+				log.Printf("Skipping corrupt spool record in %s: %v", path, jsonErr)
+			} else {
+				entries = append(entries, entry)
+			}
+		} else if len(line) > 0 {
+			log.Printf("Skipping incomplete spool record at end of %s", path)
+		}
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+}
+
+// remove 删除已成功重放的分段
+func (s *Spool) remove(seg spoolSegment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.sealed {
+		if s.sealed[i].path == seg.path {
+			s.sealed = append(s.sealed[:i], s.sealed[i+1:]...)
+			s.totalSize -= seg.size
+			break
+		}
+	}
+	return os.Remove(seg.path)
+}
+
+// Size 返回暂存区当前占用的字节数
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.totalSize
+}
+
+// Close 封存当前分段并落盘，未重放的数据会在下次 OpenSpool 时恢复
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sealActive()
+}
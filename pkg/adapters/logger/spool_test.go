@@ -0,0 +1,172 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+)
+
+func TestSpoolRecoversAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenSpool(SpoolConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("打开暂存区失败: %v", err)
+	}
+	if err := s.Append(domain.LogEntry{TrackID: "a"}, domain.LogEntry{TrackID: "b"}); err != nil {
+		t.Fatalf("追加失败: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("关闭失败: %v", err)
+	}
+
+	// 模拟崩溃时写了一半的记录
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+spoolSegmentExt))
+	f, _ := os.OpenFile(files[0], os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(`{"track_id":"c"`)
+	f.Close()
+
+	s, err = OpenSpool(SpoolConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("重新打开暂存区失败: %v", err)
+	}
+	seg, entries, ok, err := s.oldest()
+	if err != nil || !ok {
+		t.Fatalf("期望读取到遗留分段: %v", err)
+	}
+	if len(entries) != 2 || entries[0].TrackID != "a" || entries[0].ID == "" {
+		t.Errorf("期望恢复 2 条完整记录且补齐 ID，得到 %+v", entries)
+	}
+	if err := s.remove(seg); err != nil || s.Size() != 0 {
+		t.Errorf("删除分段后大小应为 0，得到 %d %v", s.Size(), err)
+	}
+}
+
+func TestSpoolKeepsUnreadableSegment(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenSpool(SpoolConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("打开暂存区失败: %v", err)
+	}
+	defer s.Close()
+	if err := s.Append(domain.LogEntry{TrackID: "a"}); err != nil {
+		t.Fatalf("追加失败: %v", err)
+	}
+
+	// 把分段替换为目录，打开成功但读取失败
+	seg, _, ok, err := s.oldest()
+	if err != nil || !ok {
+		t.Fatalf("期望读取到分段: %v", err)
+	}
+	if err := os.Remove(seg.path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(seg.path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, entries, _, err := s.oldest(); err == nil {
+		t.Fatalf("读取失败时应返回错误，得到 %d 条", len(entries))
+	}
+	if _, err := os.Stat(seg.path); err != nil || s.Size() == 0 {
+		t.Errorf("读取失败的分段应保留: %v", err)
+	}
+}
+
+func TestSpoolSizeCap(t *testing.T) {
+	s, err := OpenSpool(SpoolConfig{Dir: t.TempDir(), MaxBytes: 64})
+	if err != nil {
+		t.Fatalf("打开暂存区失败: %v", err)
+	}
+	defer s.Close()
+	err = s.Append(domain.LogEntry{TrackID: "this entry is definitely larger than the cap"})
+	if !errors.Is(err, ErrSpoolFull) {
+		t.Errorf("期望 ErrSpoolFull，得到 %v", err)
+	}
+}
+
+// flakyRepo 在 down 为 true 时写入失败
+type flakyRepo struct {
+	batchRepo
+	down atomic.Bool
+}
+
+func (r *flakyRepo) SaveBatch(ctx context.Context, entries []domain.LogEntry) error {
+	if r.down.Load() {
+		return errors.New("storage unavailable")
+	}
+	return r.batchRepo.SaveBatch(ctx, entries)
+}
+
+func TestAsyncLoggerReplaysSpool(t *testing.T) {
+	spool, err := OpenSpool(SpoolConfig{Dir: t.TempDir(), MinBackoff: 5 * time.Millisecond, MaxBackoff: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("打开暂存区失败: %v", err)
+	}
+	repo := &flakyRepo{}
+	repo.down.Store(true)
+	l := NewAsyncLogger(repo, 10, WithBatchSize(1), WithSpool(spool))
+	defer l.Close()
+
+	for i := 0; i < 3; i++ {
+		l.Log(domain.LogEntry{TrackID: "t"})
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, saved := repo.snapshot(); saved != 0 {
+		t.Fatalf("存储不可用时不应写入成功，得到 %d", saved)
+	}
+
+	repo.down.Store(false)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, saved := repo.snapshot(); saved == 3 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	_, saved := repo.snapshot()
+	t.Fatalf("存储恢复后应重放全部 3 条，得到 %d", saved)
+}
+
+// partialRepo 每批只写入第一条，其余条目以 PartialSaveError 返回
+type partialRepo struct {
+	batchRepo
+}
+
+func (r *partialRepo) SaveBatch(ctx context.Context, entries []domain.LogEntry) error {
+	r.batchRepo.SaveBatch(ctx, entries[:1])
+	if len(entries) == 1 {
+		return nil
+	}
+	return &ports.PartialSaveError{Failed: entries[1:], Err: errors.New("storage unavailable")}
+}
+
+func TestAsyncLoggerSpoolsOnlyFailedEntries(t *testing.T) {
+	spool, err := OpenSpool(SpoolConfig{Dir: t.TempDir(), MinBackoff: 5 * time.Millisecond, MaxBackoff: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("打开暂存区失败: %v", err)
+	}
+	repo := &partialRepo{}
+	l := NewAsyncLogger(repo, 10, WithBatchSize(3), WithSpool(spool))
+	defer l.Close()
+
+	for i := 0; i < 3; i++ {
+		l.Log(domain.LogEntry{TrackID: "t"})
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) && l.Stats().Saved < 3 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, saved := repo.snapshot(); saved != 3 {
+		t.Fatalf("已写入的条目不应重复写入，期望 3 条，得到 %d", saved)
+	}
+	if s := l.Stats(); s.Spooled != 2 || s.SpoolBytes != 0 {
+		t.Errorf("只有未写入的条目应进入暂存区: %+v", s)
+	}
+}
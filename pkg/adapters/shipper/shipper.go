@@ -0,0 +1,340 @@
+// Package shipper 把日志批量上报到远程 TraceBuddy 服务的采集接口 (POST /api/v1/ingest)，
+// 配合 logger.AsyncLogger 使用时业务服务无需持有数据库凭据
+package shipper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+	"github.com/MCCodingMan/TraceBuddy/pkg/utils"
+)
+
+// 上报的默认参数
+const (
+	DefaultTimeout    = 10 * time.Second
+	DefaultMaxRetries = 5
+	DefaultMinBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// maxErrorBody 错误响应体最多读取的字节数
+const maxErrorBody = 4 << 10
+
+// Shipper 实现 ports.LogWriter 和 ports.BatchSaver：
+// 每批日志序列化为 JSON 数组并 gzip 压缩后上报，可重试的失败按带抖动的指数退避重试
+type Shipper struct {
+	endpoint   string
+	apiKey     string
+	client     *http.Client
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	rejected   atomic.Uint64
+}
+
+// Option 用于定制 Shipper
+type Option func(*Shipper)
+
+// WithHTTPClient 设置上报使用的 HTTP 客户端，默认超时 10 秒
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Shipper) {
+		if c != nil {
+			s.client = c
+		}
+	}
+}
+
+// WithMaxRetries 设置单批日志的最大重试次数，0 表示不重试
+func WithMaxRetries(n int) Option {
+	return func(s *Shipper) {
+		if n >= 0 {
+			s.maxRetries = n
+		}
+	}
+}
+
+// WithBackoff 设置重试的初始等待时间和最长等待时间
+func WithBackoff(min, max time.Duration) Option {
+	return func(s *Shipper) {
+		if min > 0 {
+			s.minBackoff = min
+		}
+		if max >= s.minBackoff {
+			s.maxBackoff = max
+		}
+	}
+}
+
+// New 创建上报客户端，endpoint 为完整的采集接口地址，例如 http://tracebuddy:8080/api/v1/ingest
+func New(endpoint, apiKey string, opts ...Option) *Shipper {
+	s := &Shipper{
+		endpoint:   endpoint,
+		apiKey:     apiKey,
+		client:     &http.Client{Timeout: DefaultTimeout},
+		maxRetries: DefaultMaxRetries,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// StatusError 采集接口返回了非 2xx 状态码
+type StatusError struct {
+	StatusCode int
+	Body       string
+	retryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("ingest returned %d: %s", e.StatusCode, e.Body)
+}
+
+// temporary 判断该状态码是否值得重试
+func (e *StatusError) temporary() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// ingestResult 采集接口的响应，与 http.IngestResult 对应
+type ingestResult struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+	Errors   []struct {
+		Index     int    `json:"index"`
+		Error     string `json:"error"`
+		Retryable bool   `json:"retryable"`
+	} `json:"errors"`
+}
+
+// rejectedError 采集接口接受了请求，但部分条目因缓冲区已满等原因被拒绝，按可重试的失败处理
+type rejectedError struct {
+	count int
+}
+
+func (e *rejectedError) Error() string {
+	return fmt.Sprintf("ingest rejected %d retryable log entries", e.count)
+}
+
+// Rejected 返回被采集接口拒绝且无法重试的条目数，例如未通过校验的条目
+func (s *Shipper) Rejected() uint64 {
+	return s.rejected.Load()
+}
+
+// Save 上报单条日志
+func (s *Shipper) Save(ctx context.Context, entry domain.LogEntry) error {
+	return s.SaveBatch(ctx, []domain.LogEntry{entry})
+}
+
+// SaveBatch 上报一批日志；请求体超出服务端上限 (413) 时拆成两半分别上报
+// 部分条目已上报时返回 *ports.PartialSaveError，其中只包含未上报的条目
+func (s *Shipper) SaveBatch(ctx context.Context, entries []domain.LogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	// 缺少 ID 和时间戳时在客户端补齐，重试或暂存重放时服务端按 ID 去重
+	batch := make([]domain.LogEntry, len(entries))
+	copy(batch, entries)
+	now := time.Now()
+	for i := range batch {
+		if batch[i].ID == "" {
+			batch[i].ID = utils.GenerateEntryID()
+		}
+		if batch[i].Timestamp.IsZero() {
+			batch[i].Timestamp = now
+		}
+	}
+	return s.ship(ctx, batch)
+}
+
+func (s *Shipper) ship(ctx context.Context, batch []domain.LogEntry) error {
+	err := s.postWithRetry(ctx, batch)
+	if se, ok := err.(*StatusError); ok && se.StatusCode == http.StatusRequestEntityTooLarge && len(batch) > 1 {
+		mid := len(batch) / 2
+		if err := s.ship(ctx, batch[:mid]); err != nil {
+			// 前一半全部失败时后一半还未上报，整批视为失败
+			if partial, ok := err.(*ports.PartialSaveError); ok {
+				return &ports.PartialSaveError{Failed: append(partial.Failed, batch[mid:]...), Err: partial.Err}
+			}
+			return err
+		}
+		if err := s.ship(ctx, batch[mid:]); err != nil {
+			if _, ok := err.(*ports.PartialSaveError); ok {
+				return err
+			}
+			return &ports.PartialSaveError{Failed: batch[mid:], Err: err}
+		}
+		return nil
+	}
+	return err
+}
+
+// encodeBatch 把日志序列化为 gzip 压缩的 JSON 数组
+func encodeBatch(batch []domain.LogEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(batch); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// postWithRetry 上报一批日志，网络错误和可重试的状态码按退避重试，优先使用 Retry-After (不超过 maxBackoff)
+// 响应中可重试的被拒条目单独重发，重试用尽时以 *ports.PartialSaveError 返回这些条目
+func (s *Shipper) postWithRetry(ctx context.Context, batch []domain.LogEntry) error {
+	body, err := encodeBatch(batch)
+	if err != nil {
+		return err
+	}
+	partial := false
+	backoff := s.minBackoff
+	for attempt := 0; ; attempt++ {
+		result, err := s.post(ctx, body)
+		if err == nil {
+			retry := s.retryable(batch, result)
+			if len(retry) == 0 {
+				return nil
+			}
+			// 其余条目已被接受或无法重试，之后只重发可重试的条目
+			if len(retry) < len(batch) {
+				partial = true
+				batch = retry
+				if body, err = encodeBatch(batch); err != nil {
+					return &ports.PartialSaveError{Failed: batch, Err: err}
+				}
+			}
+			err = &rejectedError{count: len(retry)}
+		}
+		se, isStatus := err.(*StatusError)
+		if partial {
+			err = &ports.PartialSaveError{Failed: batch, Err: err}
+		}
+		if (isStatus && !se.temporary()) || attempt >= s.maxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		// Retry-After 不超过最长等待时间，避免服务端返回过大的值时长时间阻塞写入
+		wait := jitter(backoff)
+		if isStatus && se.retryAfter > 0 {
+			wait = min(se.retryAfter, s.maxBackoff)
+		}
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// post 发送一次请求，返回 2xx 响应中的逐条结果，响应体无法解析时视为全部接受
+func (s *Shipper) post(ctx context.Context, body []byte) (ingestResult, error) {
+	var result ingestResult
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("X-API-Key", s.apiKey)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return result, &StatusError{
+			StatusCode: resp.StatusCode,
+			Body:       string(bytes.TrimSpace(msg)),
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return ingestResult{}, nil
+	}
+	return result, nil
+}
+
+// retryable 返回响应中可重试的被拒条目；无法重试的条目 (如未通过校验) 只计数并记录日志
+func (s *Shipper) retryable(batch []domain.LogEntry, result ingestResult) []domain.LogEntry {
+	var retry []domain.LogEntry
+	rejected := 0
+	for _, e := range result.Errors {
+		if e.Index < 0 || e.Index >= len(batch) {
+			continue
+		}
+		if e.Retryable {
+			retry = append(retry, batch[e.Index])
+			continue
+		}
+		if rejected == 0 {
+			log.Printf("Ingest rejected log entry #%d: %s", e.Index, e.Error)
+		}
+		rejected++
+	}
+	if rejected > 0 {
+		s.rejected.Add(uint64(rejected))
+		if rejected > 1 {
+			log.Printf("Ingest rejected %d log entries in total", rejected)
+		}
+	}
+	return retry
+}
+
+// jitter 在 [d/2, d) 内随机取值，避免多个客户端同时重试
+func jitter(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + rand.Int63n(half))
+}
+
+// parseRetryAfter 解析秒数或 HTTP 日期形式的 Retry-After，无效时返回 0
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,213 @@
+package shipper
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+)
+
+// ingestServer 依次返回 statuses 中的状态码，之后返回 202；记录每次请求中的条目 ID 和成功上报的条目数
+type ingestServer struct {
+	mu       sync.Mutex
+	statuses []int
+	batches  []int
+	ids      []string
+}
+
+func (s *ingestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Header.Get("X-API-Key") != "key" || r.Header.Get("Content-Encoding") != "gzip" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	zr, err := gzip.NewReader(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var entries []domain.LogEntry
+	if err := json.NewDecoder(zr).Decode(&entries); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, e := range entries {
+		s.ids = append(s.ids, e.ID)
+	}
+	if len(s.statuses) > 0 {
+		status := s.statuses[0]
+		s.statuses = s.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+	s.batches = append(s.batches, len(entries))
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(`{"accepted":1,"rejected":0}`))
+}
+
+func newTestShipper(url string) *Shipper {
+	return New(url, "key", WithBackoff(time.Millisecond, 5*time.Millisecond), WithMaxRetries(2))
+}
+
+func TestShipperRetriesTemporaryErrors(t *testing.T) {
+	srv := &ingestServer{statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	err := newTestShipper(ts.URL).SaveBatch(context.Background(), []domain.LogEntry{{Message: "a"}, {Message: "b"}})
+	if err != nil {
+		t.Fatalf("重试后应成功，得到 %v", err)
+	}
+	if len(srv.batches) != 1 || srv.batches[0] != 2 {
+		t.Errorf("期望上报一批 2 条，得到 %v", srv.batches)
+	}
+	if len(srv.ids) != 6 || srv.ids[0] == "" || srv.ids[0] == srv.ids[1] {
+		t.Fatalf("客户端应补齐唯一 ID，得到 %v", srv.ids)
+	}
+	for i := 2; i < len(srv.ids); i++ {
+		if srv.ids[i] != srv.ids[i%2] {
+			t.Errorf("重试时 ID 应保持不变，得到 %v", srv.ids)
+			break
+		}
+	}
+}
+
+func TestShipperDoesNotRetryClientErrors(t *testing.T) {
+	srv := &ingestServer{statuses: []int{http.StatusBadRequest}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	err := newTestShipper(ts.URL).Save(context.Background(), domain.LogEntry{Message: "a"})
+	se, ok := err.(*StatusError)
+	if !ok || se.StatusCode != http.StatusBadRequest {
+		t.Fatalf("期望 400 错误，得到 %v", err)
+	}
+	if len(srv.batches) != 0 {
+		t.Errorf("400 不应重试，得到 %v", srv.batches)
+	}
+}
+
+func TestShipperSplitsOversizedBatch(t *testing.T) {
+	srv := &ingestServer{statuses: []int{http.StatusRequestEntityTooLarge}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	entries := []domain.LogEntry{{Message: "a"}, {Message: "b"}, {Message: "c"}}
+	if err := newTestShipper(ts.URL).SaveBatch(context.Background(), entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.batches) != 2 || srv.batches[0] != 1 || srv.batches[1] != 2 {
+		t.Errorf("期望拆分为 [1 2]，得到 %v", srv.batches)
+	}
+}
+
+func TestShipperResendsRetryableRejections(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		messages [][]string
+		full     bool // 为 true 时缓冲区始终已满
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zr, _ := gzip.NewReader(r.Body)
+		var entries []domain.LogEntry
+		json.NewDecoder(zr).Decode(&entries)
+		mu.Lock()
+		defer mu.Unlock()
+		var batch []string
+		for _, e := range entries {
+			batch = append(batch, e.Message)
+		}
+		messages = append(messages, batch)
+		w.WriteHeader(http.StatusAccepted)
+		if len(messages) == 1 {
+			w.Write([]byte(`{"accepted":1,"rejected":2,"errors":[{"index":1,"error":"invalid level"},{"index":2,"error":"log buffer is full","retryable":true}]}`))
+			return
+		}
+		if full {
+			w.Write([]byte(`{"accepted":0,"rejected":1,"errors":[{"index":0,"error":"log buffer is full","retryable":true}]}`))
+			return
+		}
+		w.Write([]byte(`{"accepted":1,"rejected":0}`))
+	}))
+	defer ts.Close()
+
+	entries := []domain.LogEntry{{Message: "a"}, {Message: "b"}, {Message: "c"}}
+	s := newTestShipper(ts.URL)
+	if err := s.SaveBatch(context.Background(), entries); err != nil {
+		t.Fatalf("重发后应成功，得到 %v", err)
+	}
+	if len(messages) != 2 || len(messages[1]) != 1 || messages[1][0] != "c" {
+		t.Errorf("应只重发可重试的条目，得到 %v", messages)
+	}
+	if s.Rejected() != 1 {
+		t.Errorf("无法重试的条目应计数，得到 %d", s.Rejected())
+	}
+
+	messages, full = nil, true
+	err := s.SaveBatch(context.Background(), entries)
+	partial, ok := err.(*ports.PartialSaveError)
+	if !ok || len(partial.Failed) != 1 || partial.Failed[0].Message != "c" {
+		t.Fatalf("重试用尽时应返回未上报的条目，得到 %v", err)
+	}
+	if len(messages) != 3 {
+		t.Errorf("期望首次上报加 2 次重试，得到 %v", messages)
+	}
+}
+
+func TestShipperCapsRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 || r.URL.Path == "/busy" {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	start := time.Now()
+	if err := newTestShipper(ts.URL).Save(context.Background(), domain.LogEntry{Message: "a"}); err != nil {
+		t.Fatalf("重试后应成功，得到 %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Retry-After 应不超过最长等待时间，耗时 %v", elapsed)
+	}
+
+	// 最长等待时间较大时，等待期间取消 ctx 应立即返回
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s := New(ts.URL+"/busy", "key", WithBackoff(time.Millisecond, time.Hour))
+	start = time.Now()
+	if err := s.Save(ctx, domain.LogEntry{Message: "a"}); err == nil {
+		t.Fatal("服务端持续不可用时应返回错误")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("取消 ctx 后应停止等待，耗时 %v", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"-1":                            0,
+		"bogus":                         0,
+		"Mon, 01 Jan 2024 00:00:10 GMT": 10 * time.Second,
+	}
+	for v, want := range cases {
+		if got := parseRetryAfter(v, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v，期望 %v", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package storage
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+)
+
+// 文件存储的默认参数
+const (
+	DefaultFileMaxBytes       int64 = 64 << 20
+	DefaultFileRotateInterval       = 24 * time.Hour
+)
+
+const (
+	fileSegmentExt  = ".jsonl"
+	fileGzipExt     = ".jsonl.gz"
+	fileTempGzipExt = ".jsonl.gz.tmp"
+)
+
+// FileConfig 文件存储配置
+type FileConfig struct {
+	Dir            string        // 日志目录
+	MaxBytes       int64         // 当前文件达到该大小后轮转
+	RotateInterval time.Duration // 当前文件打开超过该时长后轮转
+	MaxFiles       int           // 最多保留的已轮转文件数，0 表示不限制
+	MaxAge         time.Duration // 已轮转文件的最长保留时间，0 表示不限制
+}
+
+// FileRepository 把日志以 JSON Lines 追加到目录中的分段文件，适用于没有数据库的边缘部署
+// 当前文件按大小或时间轮转，轮转后的文件以 gzip 压缩，并按数量或时间清理
+// 查询会扫描全部文件，同一 ID 以最后写入的为准，数据量较大时应使用数据库存储
+type FileRepository struct {
+	cfg FileConfig
+
+	// writeMu 串行化写入和轮转，filesMu 保护文件集合，读取期间文件不会被压缩替换或删除
+	writeMu    sync.Mutex
+	filesMu    sync.RWMutex
+	active     *os.File
+	activeSize int64
+	openedAt   time.Time
+	nextSeq    uint64
+
+	now func() time.Time
+}
+
+// NewFileRepository 打开日志目录，上次运行未压缩的文件会被压缩，之后写入新的文件
+func NewFileRepository(cfg FileConfig) (*FileRepository, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("file storage dir is required")
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultFileMaxBytes
+	}
+	if cfg.RotateInterval <= 0 {
+		cfg.RotateInterval = DefaultFileRotateInterval
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	r := &FileRepository{cfg: cfg, now: time.Now}
+	segments, err := r.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, seg := range segments {
+		if seg.seq >= r.nextSeq {
+			r.nextSeq = seg.seq + 1
+		}
+		if !seg.compressed {
+			if err := r.compress(seg); err != nil {
+				return nil, err
+			}
+		}
+	}
+	r.applyRetention()
+	return r, nil
+}
+
+// fileSegment 目录中的一个分段文件
+type fileSegment struct {
+	seq        uint64
+	path       string
+	compressed bool
+	modTime    time.Time
+}
+
+// segments 按写入顺序列出分段文件，同时清理压缩到一半的临时文件
+func (r *FileRepository) segments() ([]fileSegment, error) {
+	files, err := os.ReadDir(r.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []fileSegment
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(name, fileTempGzipExt) {
+			_ = os.Remove(filepath.Join(r.cfg.Dir, name))
+			continue
+		}
+		var seg fileSegment
+		switch {
+		case strings.HasSuffix(name, fileGzipExt):
+			seg.compressed = true
+			name = strings.TrimSuffix(name, fileGzipExt)
+		case strings.HasSuffix(name, fileSegmentExt):
+			name = strings.TrimSuffix(name, fileSegmentExt)
+		default:
+			continue
+		}
+		seq, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		seg.seq = seq
+		seg.path = filepath.Join(r.cfg.Dir, f.Name())
+		seg.modTime = info.ModTime()
+		segments = append(segments, seg)
+	}
+	// 压缩完成到删除原文件之间同一分段会出现两份，读取时按 ID 去重
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].seq != segments[j].seq {
+			return segments[i].seq < segments[j].seq
+		}
+		return segments[i].compressed && !segments[j].compressed
+	})
+	return segments, nil
+}
+
+func (r *FileRepository) segmentPath(seq uint64) string {
+	return filepath.Join(r.cfg.Dir, fmt.Sprintf("%020d%s", seq, fileSegmentExt))
+}
+
+func (r *FileRepository) Save(ctx context.Context, entry domain.LogEntry) error {
+	return r.SaveBatch(ctx, []domain.LogEntry{entry})
+}
+
+// SaveBatch 以一次写入追加一批日志，进程崩溃最多留下一行不完整的记录，读取时会跳过
+func (r *FileRepository) SaveBatch(ctx context.Context, entries []domain.LogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	var buf []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(normalizeEntry(entry))
+		if err != nil {
+			return err
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if r.active != nil && r.now().Sub(r.openedAt) >= r.cfg.RotateInterval {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	if r.active == nil {
+		if err := r.openActive(); err != nil {
+			return err
+		}
+	}
+	n, err := r.active.Write(buf)
+	r.activeSize += int64(n)
+	if err != nil {
+		return err
+	}
+	if r.activeSize >= r.cfg.MaxBytes {
+		return r.rotate()
+	}
+	return nil
+}
+
+// openActive 创建新的当前文件；调用方需持有 writeMu
+func (r *FileRepository) openActive() error {
+	r.filesMu.Lock()
+	defer r.filesMu.Unlock()
+	f, err := os.OpenFile(r.segmentPath(r.nextSeq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	r.active = f
+	r.activeSize = 0
+	r.openedAt = r.now()
+	r.nextSeq++
+	return nil
+}
+
+// rotate 关闭当前文件，压缩后按保留策略清理旧文件；调用方需持有 writeMu
+func (r *FileRepository) rotate() error {
+	if r.active == nil {
+		return nil
+	}
+	f := r.active
+	r.active = nil
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := r.compress(fileSegment{path: f.Name()}); err != nil {
+		// 压缩失败时保留未压缩的文件，不影响读取，下次启动时重试
+		log.Printf("Failed to compress log segment %s: %v", f.Name(), err)
+	}
+	r.applyRetention()
+	return nil
+}
+
+// compress 把分段压缩为 .jsonl.gz，完成后再替换原文件
+func (r *FileRepository) compress(seg fileSegment) error {
+	src, err := os.Open(seg.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	base := strings.TrimSuffix(seg.path, fileSegmentExt)
+	tmp := base + fileTempGzipExt
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	r.filesMu.Lock()
+	defer r.filesMu.Unlock()
+	if err := os.Rename(tmp, base+fileGzipExt); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(seg.path)
+}
+
+// applyRetention 按数量和时间删除已压缩的旧文件
+func (r *FileRepository) applyRetention() {
+	if r.cfg.MaxFiles <= 0 && r.cfg.MaxAge <= 0 {
+		return
+	}
+	r.filesMu.Lock()
+	defer r.filesMu.Unlock()
+
+	segments, err := r.segments()
+	if err != nil {
+		log.Printf("Failed to list log segments: %v", err)
+		return
+	}
+	var rotated []fileSegment
+	for _, seg := range segments {
+		if seg.compressed {
+			rotated = append(rotated, seg)
+		}
+	}
+	cutoff := r.now().Add(-r.cfg.MaxAge)
+	for i, seg := range rotated {
+		tooMany := r.cfg.MaxFiles > 0 && len(rotated)-i > r.cfg.MaxFiles
+		tooOld := r.cfg.MaxAge > 0 && seg.modTime.Before(cutoff)
+		if tooMany || tooOld {
+			if err := os.Remove(seg.path); err != nil {
+				log.Printf("Failed to remove expired log segment %s: %v", seg.path, err)
+			}
+		}
+	}
+}
+
+// scan 读取所有分段中满足条件的条目，同一 ID 以最后写入的为准
+func (r *FileRepository) scan(match func(domain.LogEntry) bool) ([]domain.LogEntry, error) {
+	r.filesMu.RLock()
+	defer r.filesMu.RUnlock()
+
+	segments, err := r.segments()
+	if err != nil {
+		return nil, err
+	}
+	latest := map[string]domain.LogEntry{}
+	var order []string
+	for _, seg := range segments {
+		err := readFileSegment(seg, func(entry domain.LogEntry) {
+			if _, ok := latest[entry.ID]; !ok {
+				order = append(order, entry.ID)
+			}
+			latest[entry.ID] = entry
+		})
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	entries := []domain.LogEntry{}
+	for _, id := range order {
+		// 后写入的版本不满足条件时，旧版本也不应出现在结果中
+		if entry := latest[id]; match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// readFileSegment 逐行解析分段文件，跳过写了一半或损坏的记录
+func readFileSegment(seg fileSegment, fn func(domain.LogEntry)) error {
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var src io.Reader = f
+	if seg.compressed {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", seg.path, err)
+		}
+		defer zr.Close()
+		src = zr
+	}
+
+	reader := bufio.NewReader(src)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var entry domain.LogEntry
+			if jsonErr := json.Unmarshal(line, &entry); jsonErr != nil {
+				log.Printf("Skipping corrupt log record in %s: %v", seg.path, jsonErr)
+			} else {
+				fn(entry)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", seg.path, err)
+		}
+	}
+}
+
+func (r *FileRepository) FindByID(ctx context.Context, id string) (*domain.LogEntry, error) {
+	entries, err := r.scan(func(e domain.LogEntry) bool { return e.ID == id || e.TrackID == id })
+	if err != nil {
+		return nil, err
+	}
+	return findEntryByID(entries, id), nil
+}
+
+func (r *FileRepository) FindByTrace(ctx context.Context, traceID string) ([]domain.LogEntry, error) {
+	entries, err := r.scan(traceMatcher(traceID))
+	if err != nil {
+		return nil, err
+	}
+	return traceEntries(entries), nil
+}
+
+func (r *FileRepository) Search(ctx context.Context, query ports.LogSearchQuery) ([]domain.LogEntry, int64, error) {
+	match, err := searchMatcher(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries, err := r.scan(match)
+	if err != nil {
+		return nil, 0, err
+	}
+	return searchEntries(entries, query)
+}
+
+func (r *FileRepository) Stats(ctx context.Context, query ports.StatsQuery) ([]ports.StatsGroup, error) {
+	if err := query.Normalize(time.Now()); err != nil {
+		return nil, err
+	}
+	match, err := searchMatcher(query.LogSearchQuery)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := r.scan(match)
+	if err != nil {
+		return nil, err
+	}
+	return statsEntries(entries, query)
+}
+
+// Close 关闭当前文件，当前文件会在下次打开时压缩
+func (r *FileRepository) Close() error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	if r.active == nil {
+		return nil
+	}
+	f := r.active
+	r.active = nil
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/storage/storagetest"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+)
+
+func newTestFileRepository(t *testing.T, cfg FileConfig) *FileRepository {
+	t.Helper()
+	if cfg.Dir == "" {
+		cfg.Dir = t.TempDir()
+	}
+	r, err := NewFileRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestFileRepositoryConformance(t *testing.T) {
+	storagetest.RunLogRepositoryTests(t, func(t *testing.T) ports.LogRepository {
+		// 较小的轮转阈值让用例跨越多个压缩分段
+		return newTestFileRepository(t, FileConfig{MaxBytes: 2 << 10})
+	})
+}
+
+func countSegments(t *testing.T, dir string) (plain, compressed int) {
+	t.Helper()
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		switch {
+		case strings.HasSuffix(f.Name(), fileGzipExt):
+			compressed++
+		case strings.HasSuffix(f.Name(), fileSegmentExt):
+			plain++
+		default:
+			t.Errorf("意外的文件 %s", f.Name())
+		}
+	}
+	return plain, compressed
+}
+
+func TestFileRepositoryRotation(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	r := newTestFileRepository(t, FileConfig{Dir: dir, MaxBytes: 1, MaxFiles: 3})
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		entry := domain.LogEntry{ID: fmt.Sprintf("log-%d", i), TrackID: "trace", Timestamp: base.Add(time.Duration(i) * time.Second)}
+		if err := r.Save(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 每次写入都会轮转，只保留最近 3 个压缩分段
+	if plain, compressed := countSegments(t, dir); plain != 0 || compressed != 3 {
+		t.Fatalf("分段数: plain=%d compressed=%d", plain, compressed)
+	}
+	entries, err := r.FindByTrace(ctx, "trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].ID != "log-2" {
+		t.Fatalf("清理后应剩 log-2..log-4，得到 %+v", entries)
+	}
+}
+
+func TestFileRepositoryReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	r, err := NewFileRepository(FileConfig{Dir: dir, MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(ctx, domain.LogEntry{ID: "kept", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟写了一半的记录
+	if _, err := r.active.WriteString(`{"id":"torn"`); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expired := filepath.Join(dir, fmt.Sprintf("%020d%s", 0, fileGzipExt))
+	r = newTestFileRepository(t, FileConfig{Dir: dir, MaxAge: time.Hour})
+	// 重新打开时上次的文件被压缩，过期的分段在下一次轮转时清理
+	if plain, compressed := countSegments(t, dir); plain != 0 || compressed != 1 {
+		t.Fatalf("分段数: plain=%d compressed=%d", plain, compressed)
+	}
+	if e, err := r.FindByID(ctx, "kept"); err != nil || e == nil {
+		t.Fatalf("FindByID: %+v %v", e, err)
+	}
+	if e, _ := r.FindByID(ctx, "torn"); e != nil {
+		t.Errorf("不完整的记录应被跳过")
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(expired, old, old); err != nil {
+		t.Fatal(err)
+	}
+	r.writeMu.Lock()
+	r.applyRetention()
+	r.writeMu.Unlock()
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Errorf("过期分段应被删除: %v", err)
+	}
+}
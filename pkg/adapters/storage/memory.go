@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+	ql "github.com/MCCodingMan/TraceBuddy/pkg/core/query"
+)
+
+// MemoryRepository 基于内存的日志存储，并发安全，用于测试和不需要持久化的场景
+// 查询语义与 SQL 实现一致，条目在写入和读取时都会复制，调用方修改返回值不影响已存储的数据
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	entries map[string]domain.LogEntry
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{entries: make(map[string]domain.LogEntry)}
+}
+
+func (r *MemoryRepository) Save(ctx context.Context, entry domain.LogEntry) error {
+	return r.SaveBatch(ctx, []domain.LogEntry{entry})
+}
+
+// SaveBatch 按 id 幂等写入一批日志
+func (r *MemoryRepository) SaveBatch(ctx context.Context, entries []domain.LogEntry) error {
+	copies := make([]domain.LogEntry, len(entries))
+	for i, entry := range entries {
+		copies[i] = cloneEntry(normalizeEntry(entry))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, entry := range copies {
+		r.entries[entry.ID] = entry
+	}
+	return nil
+}
+
+func (r *MemoryRepository) FindByID(ctx context.Context, id string) (*domain.LogEntry, error) {
+	r.mu.RLock()
+	if entry, ok := r.entries[id]; ok {
+		r.mu.RUnlock()
+		entry = cloneEntry(entry)
+		return &entry, nil
+	}
+	r.mu.RUnlock()
+	return findEntryByID(r.filter(func(e domain.LogEntry) bool { return e.TrackID == id }), id), nil
+}
+
+func (r *MemoryRepository) FindByTrace(ctx context.Context, traceID string) ([]domain.LogEntry, error) {
+	return traceEntries(r.filter(traceMatcher(traceID))), nil
+}
+
+func (r *MemoryRepository) Search(ctx context.Context, query ports.LogSearchQuery) ([]domain.LogEntry, int64, error) {
+	match, err := searchMatcher(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	return searchEntries(r.filter(match), query)
+}
+
+func (r *MemoryRepository) Stats(ctx context.Context, query ports.StatsQuery) ([]ports.StatsGroup, error) {
+	if err := query.Normalize(time.Now()); err != nil {
+		return nil, err
+	}
+	match, err := searchMatcher(query.LogSearchQuery)
+	if err != nil {
+		return nil, err
+	}
+	return statsEntries(r.filter(match), query)
+}
+
+func (r *MemoryRepository) CountExpired(ctx context.Context, policy domain.RetentionPolicy, rule int, now time.Time) (int64, error) {
+	return int64(len(r.filter(expiredMatcher(policy, rule, now)))), nil
+}
+
+func (r *MemoryRepository) DeleteExpired(ctx context.Context, policy domain.RetentionPolicy, rule int, now time.Time, limit int) (int64, error) {
+	match := expiredMatcher(policy, rule, now)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted int64
+	for id, entry := range r.entries {
+		if deleted >= int64(limit) {
+			break
+		}
+		if match(entry) {
+			delete(r.entries, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// expiredMatcher 匹配第 rule 条规则负责清理的过期条目，与 SQL 实现的 retentionWhere 一致
+func expiredMatcher(policy domain.RetentionPolicy, rule int, now time.Time) func(domain.LogEntry) bool {
+	return func(e domain.LogEntry) bool {
+		return policy.Match(e) == rule && policy.Expired(e, now)
+	}
+}
+
+// filter 返回所有满足条件的条目副本
+func (r *MemoryRepository) filter(match func(domain.LogEntry) bool) []domain.LogEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := []domain.LogEntry{}
+	for _, entry := range r.entries {
+		if match(entry) {
+			entries = append(entries, cloneEntry(entry))
+		}
+	}
+	return entries
+}
+
+// findEntryByID 在条目中按 ID 查找，找不到时按 Track ID 返回该链路最新的一条
+func findEntryByID(entries []domain.LogEntry, id string) *domain.LogEntry {
+	var found *domain.LogEntry
+	for i := range entries {
+		e := &entries[i]
+		if e.ID == id {
+			return e
+		}
+		if e.TrackID == id && (found == nil || e.Timestamp.After(found.Timestamp)) {
+			found = e
+		}
+	}
+	return found
+}
+
+// traceMatcher 匹配 Track ID 或 W3C trace-id 属于同一链路的条目
+func traceMatcher(traceID string) func(domain.LogEntry) bool {
+	trackID, w3cTraceID := traceKeys(traceID)
+	return func(e domain.LogEntry) bool {
+		return e.TrackID == trackID || e.TraceID == w3cTraceID
+	}
+}
+
+// traceEntries 将同一链路的条目按时间升序排列
+func traceEntries(entries []domain.LogEntry) []domain.LogEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Timestamp.Equal(entries[j].Timestamp) {
+			return entries[i].Timestamp.Before(entries[j].Timestamp)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
+
+// searchEntries 对已过滤的条目按 (timestamp, id) 倒序排序并分页，返回当前页和总数
+func searchEntries(entries []domain.LogEntry, query ports.LogSearchQuery) ([]domain.LogEntry, int64, error) {
+	terms, err := query.TextTerms()
+	if err != nil {
+		return nil, 0, err
+	}
+	// 按相关度排序时先比较全文匹配的得分，得分相同再按时间倒序
+	var scores map[string]int
+	switch query.SortOrder() {
+	case ports.SortTime:
+	case ports.SortRelevance:
+		if query.Cursor != "" {
+			return nil, 0, ports.ErrCursorNeedsTimeSort
+		}
+		scores = make(map[string]int, len(entries))
+		for _, e := range entries {
+			scores[e.ID], _ = ql.MatchText(terms, textFields(e))
+		}
+	default:
+		return nil, 0, fmt.Errorf("unknown sort %q", query.Sort)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if scores[entries[i].ID] != scores[entries[j].ID] {
+			return scores[entries[i].ID] > scores[entries[j].ID]
+		}
+		if !entries[i].Timestamp.Equal(entries[j].Timestamp) {
+			return entries[i].Timestamp.After(entries[j].Timestamp)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+
+	total := int64(len(entries))
+	switch query.Count {
+	case "", ports.CountExact, ports.CountEstimate:
+	case ports.CountNone:
+		total = -1
+	default:
+		return nil, 0, fmt.Errorf("unknown count mode %q", query.Count)
+	}
+
+	page := query.Page
+	size := query.Size
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = 10
+	}
+	start := (page - 1) * size
+	if query.Cursor != "" {
+		cursor, err := ports.DecodeCursor(query.Cursor)
+		if err != nil {
+			return nil, 0, err
+		}
+		start = sort.Search(len(entries), func(i int) bool {
+			e := entries[i]
+			return e.Timestamp.Before(cursor.Timestamp) || (e.Timestamp.Equal(cursor.Timestamp) && e.ID < cursor.ID)
+		})
+	}
+	if start > len(entries) {
+		start = len(entries)
+	}
+	end := start + size
+	if end > len(entries) {
+		end = len(entries)
+	}
+	result := entries[start:end]
+	if len(terms) > 0 {
+		for i := range result {
+			result[i].Highlight = ql.Highlight(terms, textFields(result[i]))
+		}
+	}
+	return result, total, nil
+}
+
+// textFields 返回条目中参与全文搜索的文本
+func textFields(e domain.LogEntry) []string {
+	return ql.TextFields(e.Message, e.Request.URL, e.Request.Body, e.Response.Body)
+}
+
+// searchMatcher 把搜索条件转换为与 SQL 实现一致的过滤函数
+// 内存存储和文件存储共用
+func searchMatcher(query ports.LogSearchQuery) (func(domain.LogEntry) bool, error) {
+	expr, err := query.Expr()
+	if err != nil {
+		return nil, err
+	}
+	terms, err := query.TextTerms()
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := query.TimeRange(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	path := strings.ToLower(query.Path)
+	keyword := strings.ToLower(query.Keyword)
+
+	return func(e domain.LogEntry) bool {
+		if !start.IsZero() && e.Timestamp.Before(start) {
+			return false
+		}
+		if !end.IsZero() && e.Timestamp.After(end) {
+			return false
+		}
+		if query.Method != "" && e.Request.Method != query.Method {
+			return false
+		}
+		if query.Status != 0 && e.Response.StatusCode != query.Status {
+			return false
+		}
+		if path != "" && !strings.Contains(strings.ToLower(e.Request.URL), path) {
+			return false
+		}
+		if query.Level != "" && e.Level != query.Level {
+			return false
+		}
+		if keyword != "" &&
+			!strings.Contains(strings.ToLower(e.Message), keyword) &&
+			!strings.Contains(strings.ToLower(e.Request.URL), keyword) &&
+			!strings.Contains(strings.ToLower(e.TrackID), keyword) {
+			return false
+		}
+		if _, ok := ql.MatchText(terms, textFields(e)); !ok {
+			return false
+		}
+		return ql.Match(expr, e)
+	}, nil
+}
+
+// cloneEntry 深拷贝条目中的 Header、查询参数和 Body
+// Body 经过一次 JSON 往返，与数据库读回的结果类型一致 (数字为 float64)
+func cloneEntry(e domain.LogEntry) domain.LogEntry {
+	e.Request.Headers = cloneStringMap(e.Request.Headers)
+	e.Request.QueryParams = cloneStringMap(e.Request.QueryParams)
+	e.Response.Headers = cloneStringMap(e.Response.Headers)
+	e.Request.Body = cloneJSON(e.Request.Body)
+	e.Response.Body = cloneJSON(e.Response.Body)
+	return e
+}
+
+func cloneStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func cloneJSON(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out interface{}
+	_ = json.Unmarshal(data, &out)
+	return out
+}
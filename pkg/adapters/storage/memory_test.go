@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/storage/storagetest"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+)
+
+func TestMemoryRepositoryConformance(t *testing.T) {
+	storagetest.RunLogRepositoryTests(t, func(t *testing.T) ports.LogRepository {
+		return NewMemoryRepository()
+	})
+}
+
+func TestMemoryRepositoryConcurrentAccess(t *testing.T) {
+	repo := NewMemoryRepository()
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				repo.Save(ctx, domain.LogEntry{TrackID: "t", Timestamp: time.Now(), Request: domain.RequestInfo{Headers: map[string]string{"n": "v"}}})
+				repo.Search(ctx, ports.LogSearchQuery{Keyword: "t"})
+				repo.FindByTrace(ctx, "t")
+			}
+		}(i)
+	}
+	wg.Wait()
+	if _, total, _ := repo.Search(ctx, ports.LogSearchQuery{}); total != 400 {
+		t.Errorf("期望 400 条，得到 %d", total)
+	}
+}
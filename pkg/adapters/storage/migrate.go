@@ -0,0 +1,326 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationFS 按方言分目录保存迁移脚本，文件名为 <版本>_<名称>.up.sql / .down.sql
+//
+//go:embed migrations
+var migrationFS embed.FS
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrationLockKey 是 Postgres 迁移使用的 advisory lock 键 ("tracebud")
+const migrationLockKey int64 = 0x7472616365627564
+
+// Migration 一个版本化迁移
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus 迁移的执行状态；Pending 表示尚未执行，Unknown 表示数据库中存在当前程序不认识的版本
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt time.Time
+	Pending   bool
+	Unknown   bool
+}
+
+// Migrator 执行嵌入程序中的迁移，执行期间持有数据库级别的锁，多个实例同时启动时只有一个会执行迁移
+type Migrator struct {
+	db         *sql.DB
+	dialect    sqlDialect
+	migrations []Migration
+	owned      bool // db 由 OpenMigrator 打开，Close 时一并关闭
+}
+
+// NewMigrator 使用已有连接创建迁移器，driver 为 pgx/postgres 或 sqlite
+func NewMigrator(db *sql.DB, driver string) (*Migrator, error) {
+	dialect, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+	return newMigrator(db, dialect)
+}
+
+// OpenMigrator 按驱动打开数据库并创建迁移器，不会自动执行迁移
+func OpenMigrator(driver, dsn string) (*Migrator, error) {
+	dialect, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+	var db *sql.DB
+	if dialect.name == sqliteDialect.name {
+		db, err = openSQLite(dsn)
+	} else {
+		db, err = openPostgres(dsn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m, err := newMigrator(db, dialect)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	m.owned = true
+	return m, nil
+}
+
+func dialectFor(driver string) (sqlDialect, error) {
+	switch driver {
+	case "pgx", "postgres":
+		return postgresDialect, nil
+	case "sqlite":
+		return sqliteDialect, nil
+	}
+	return sqlDialect{}, fmt.Errorf("unsupported database driver %q (want pgx or sqlite)", driver)
+}
+
+func newMigrator(db *sql.DB, dialect sqlDialect) (*Migrator, error) {
+	migrations, err := loadMigrations(dialect.name)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// loadMigrations 读取方言目录下的迁移并按版本排序，每个版本必须同时提供 up 和 down
+func loadMigrations(dir string) ([]Migration, error) {
+	dir = path.Join("migrations", dir)
+	files, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, f := range files {
+		match := migrationFileRe.FindStringSubmatch(f.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", f.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		data, err := migrationFS.ReadFile(path.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down scripts", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Close 关闭由 OpenMigrator 打开的数据库连接
+func (m *Migrator) Close() error {
+	if !m.owned {
+		return nil
+	}
+	return m.db.Close()
+}
+
+// Up 按版本顺序执行所有未执行的迁移，返回本次执行的迁移
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			err := m.apply(ctx, conn, mig.Up,
+				`INSERT INTO schema_migrations (version, name, applied_at) VALUES (`+
+					m.dialect.placeholder(1)+`, `+m.dialect.placeholder(2)+`, `+m.dialect.placeholder(3)+`)`,
+				mig.Version, mig.Name, m.dialect.timeArg(time.Now()))
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down 按版本倒序回滚最近执行的 steps 个迁移，返回本次回滚的迁移
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			err := m.apply(ctx, conn, mig.Down,
+				`DELETE FROM schema_migrations WHERE version = `+m.dialect.placeholder(1), mig.Version)
+			if err != nil {
+				return fmt.Errorf("rollback %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status 返回所有已知迁移和数据库中已记录版本的状态，按版本排序
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			s := MigrationStatus{Version: mig.Version, Name: mig.Name, Pending: true}
+			if a, ok := applied[mig.Version]; ok {
+				s.AppliedAt = a.AppliedAt
+				s.Pending = false
+				delete(applied, mig.Version)
+			}
+			statuses = append(statuses, s)
+		}
+		// 由更新版本的程序执行的迁移
+		for _, a := range applied {
+			a.Unknown = true
+			statuses = append(statuses, a)
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, err
+}
+
+// locked 在独占的连接上加锁后执行 fn，并确保 schema_migrations 表存在
+// Postgres 使用会话级 advisory lock，每个迁移各自在事务中执行；
+// SQLite 使用 BEGIN IMMEDIATE 取得写锁，整批迁移在同一个事务中执行
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.dialect.name == sqliteDialect.name {
+		if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				conn.ExecContext(context.Background(), `ROLLBACK`)
+				return
+			}
+			_, err = conn.ExecContext(ctx, `COMMIT`)
+		}()
+		if _, err := conn.ExecContext(ctx, `
+            CREATE TABLE IF NOT EXISTS schema_migrations (
+                version INTEGER PRIMARY KEY,
+                name TEXT NOT NULL,
+                applied_at INTEGER NOT NULL
+            )`); err != nil {
+			return err
+		}
+		return fn(conn)
+	}
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, unlockErr := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey); unlockErr != nil {
+			// 解锁失败时丢弃该连接，会话结束后锁随之释放
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+	}()
+	if _, err := conn.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version BIGINT PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at TIMESTAMPTZ NOT NULL
+        )`); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// apply 执行迁移脚本并更新 schema_migrations；Postgres 下两者在同一事务中
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, script, record string, args ...interface{}) error {
+	if m.dialect.name == sqliteDialect.name {
+		if _, err := conn.ExecContext(ctx, script); err != nil {
+			return err
+		}
+		_, err := conn.ExecContext(ctx, record, args...)
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// applied 读取已执行的迁移版本
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]MigrationStatus, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int64]MigrationStatus{}
+	for rows.Next() {
+		var s MigrationStatus
+		if err := rows.Scan(&s.Version, &s.Name, timeColumn{&s.AppliedAt}); err != nil {
+			return nil, err
+		}
+		applied[s.Version] = s
+	}
+	return applied, rows.Err()
+}
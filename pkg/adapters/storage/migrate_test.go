@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	for _, dialect := range []sqlDialect{postgresDialect, sqliteDialect} {
+		migrations, err := loadMigrations(dialect.name)
+		if err != nil {
+			t.Fatalf("%s: %v", dialect.name, err)
+		}
+		if len(migrations) == 0 || migrations[0].Version != 1 {
+			t.Fatalf("%s: 迁移应从版本 1 开始: %+v", dialect.name, migrations)
+		}
+		for i := 1; i < len(migrations); i++ {
+			if migrations[i].Version <= migrations[i-1].Version {
+				t.Errorf("%s: 迁移未按版本排序", dialect.name)
+			}
+		}
+	}
+}
+
+func TestMigratorUpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	m, err := OpenMigrator("sqlite", filepath.Join(t.TempDir(), "tracebuddy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	applied, err := m.Up(ctx)
+	if err != nil || len(applied) != len(m.migrations) {
+		t.Fatalf("Up: %d %v", len(applied), err)
+	}
+	if applied, err := m.Up(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("重复执行 Up 不应再执行迁移: %d %v", len(applied), err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.Pending || s.AppliedAt.IsZero() {
+			t.Errorf("迁移 %d 应已执行: %+v", s.Version, s)
+		}
+	}
+
+	last := m.migrations[len(m.migrations)-1]
+	rolled, err := m.Down(ctx, 1)
+	if err != nil || len(rolled) != 1 || rolled[0].Version != last.Version {
+		t.Fatalf("Down: %+v %v", rolled, err)
+	}
+	statuses, err = m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := statuses[len(statuses)-1]; !s.Pending {
+		t.Errorf("回滚后迁移 %d 应为未执行: %+v", s.Version, s)
+	}
+
+	// 数据库中存在当前程序不认识的版本
+	if _, err := m.db.ExecContext(ctx, `INSERT INTO schema_migrations VALUES (9999, 'future', 0)`); err != nil {
+		t.Fatal(err)
+	}
+	statuses, err = m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := statuses[len(statuses)-1]; s.Version != 9999 || !s.Unknown {
+		t.Errorf("未知版本应标记为 Unknown: %+v", s)
+	}
+}
+
+func TestMigratorConcurrentUp(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "tracebuddy.db")
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		total int
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, err := OpenMigrator("sqlite", dsn)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer m.Close()
+			applied, err := m.Up(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			total += len(applied)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	want, _ := loadMigrations(sqliteDialect.name)
+	if total != len(want) {
+		t.Errorf("并发启动时每个迁移只应执行一次，共执行 %d 次", total)
+	}
+}
+
+func TestOpenMigratorUnsupportedDriver(t *testing.T) {
+	if _, err := OpenMigrator("mysql", ""); err == nil {
+		t.Error("不支持的驱动应返回错误")
+	}
+}
@@ -0,0 +1,56 @@
+-- 基线结构。使用 IF NOT EXISTS，由旧版本 initSchema 创建的数据库也可以直接纳入迁移管理
+CREATE TABLE IF NOT EXISTS logs (
+    id TEXT PRIMARY KEY,
+    track_id TEXT NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    duration_ms BIGINT,
+    method TEXT,
+    url TEXT,
+    status_code INT,
+    client_ip TEXT,
+    service TEXT,
+    environment TEXT,
+    level TEXT,
+    message TEXT,
+    request_headers JSONB,
+    request_query_params JSONB,
+    request_body JSONB,
+    response_headers JSONB,
+    response_body JSONB,
+    response_size BIGINT
+);
+-- 旧版本以 track_id 为主键，多服务共享 Track ID 时会互相覆盖，迁移为每条日志独立的 id
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS id TEXT;
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.key_column_usage
+        WHERE table_name = 'logs' AND constraint_name = 'logs_pkey' AND column_name = 'track_id'
+    ) THEN
+        UPDATE logs SET id = track_id WHERE id IS NULL;
+        ALTER TABLE logs DROP CONSTRAINT logs_pkey;
+        ALTER TABLE logs ADD PRIMARY KEY (id);
+    END IF;
+END $$;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS request_size BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS request_body_truncated BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS response_body_truncated BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS trace_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS span_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS parent_span_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'server';
+CREATE INDEX IF NOT EXISTS idx_logs_track_id ON logs (track_id);
+CREATE INDEX IF NOT EXISTS idx_logs_timestamp ON logs (timestamp);
+CREATE INDEX IF NOT EXISTS idx_logs_method ON logs (method);
+CREATE INDEX IF NOT EXISTS idx_logs_status ON logs (status_code);
+CREATE INDEX IF NOT EXISTS idx_logs_url ON logs (url);
+CREATE INDEX IF NOT EXISTS idx_logs_level ON logs (level);
+CREATE INDEX IF NOT EXISTS idx_logs_trace_id ON logs (trace_id);
+
+CREATE TABLE IF NOT EXISTS users (
+    username TEXT PRIMARY KEY,
+    password_hash TEXT NOT NULL,
+    role TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
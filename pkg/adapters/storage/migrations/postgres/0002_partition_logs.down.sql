@@ -0,0 +1,18 @@
+-- 把各分区的数据并回默认分区，再恢复为普通表；PartitionManager 已分离的分区不受影响
+ALTER TABLE logs DETACH PARTITION logs_default;
+INSERT INTO logs_default SELECT * FROM logs;
+DROP TABLE logs;
+ALTER TABLE logs_default RENAME TO logs;
+
+-- 同一 id 在不同时间写入过多次时只保留最新的一条，才能恢复以 id 为主键
+DELETE FROM logs a USING logs b
+WHERE a.id = b.id AND a.timestamp < b.timestamp;
+ALTER TABLE logs DROP CONSTRAINT logs_default_pkey;
+ALTER TABLE logs ADD PRIMARY KEY (id);
+ALTER INDEX logs_default_track_id_idx RENAME TO idx_logs_track_id;
+ALTER INDEX logs_default_timestamp_idx RENAME TO idx_logs_timestamp;
+ALTER INDEX logs_default_method_idx RENAME TO idx_logs_method;
+ALTER INDEX logs_default_status_code_idx RENAME TO idx_logs_status;
+ALTER INDEX logs_default_url_idx RENAME TO idx_logs_url;
+ALTER INDEX logs_default_level_idx RENAME TO idx_logs_level;
+ALTER INDEX logs_default_trace_id_idx RENAME TO idx_logs_trace_id;
@@ -0,0 +1,25 @@
+-- logs 改为按 timestamp 范围分区的表。原表保留已有数据，作为默认分区挂到新表下，
+-- 按天或按周的分区由 PartitionManager 创建；分区表的主键必须包含分区键，主键改为 (id, timestamp)
+ALTER TABLE logs RENAME TO logs_default;
+ALTER TABLE logs_default DROP CONSTRAINT logs_pkey;
+ALTER TABLE logs_default ADD PRIMARY KEY (id, timestamp);
+ALTER INDEX idx_logs_track_id RENAME TO logs_default_track_id_idx;
+ALTER INDEX idx_logs_timestamp RENAME TO logs_default_timestamp_idx;
+ALTER INDEX idx_logs_method RENAME TO logs_default_method_idx;
+ALTER INDEX idx_logs_status RENAME TO logs_default_status_code_idx;
+ALTER INDEX idx_logs_url RENAME TO logs_default_url_idx;
+ALTER INDEX idx_logs_level RENAME TO logs_default_level_idx;
+ALTER INDEX idx_logs_trace_id RENAME TO logs_default_trace_id_idx;
+
+CREATE TABLE logs (LIKE logs_default INCLUDING DEFAULTS) PARTITION BY RANGE (timestamp);
+ALTER TABLE logs ADD PRIMARY KEY (id, timestamp);
+ALTER TABLE logs ATTACH PARTITION logs_default DEFAULT;
+
+-- 分区表上的索引会挂接默认分区上定义相同的已有索引，不会重建
+CREATE INDEX idx_logs_track_id ON logs (track_id);
+CREATE INDEX idx_logs_timestamp ON logs (timestamp);
+CREATE INDEX idx_logs_method ON logs (method);
+CREATE INDEX idx_logs_status ON logs (status_code);
+CREATE INDEX idx_logs_url ON logs (url);
+CREATE INDEX idx_logs_level ON logs (level);
+CREATE INDEX idx_logs_trace_id ON logs (trace_id);
@@ -0,0 +1,3 @@
+-- 搜索按 (timestamp, id) 倒序排序并以此做键集分页，复合索引覆盖原来的 timestamp 索引
+CREATE INDEX IF NOT EXISTS idx_logs_timestamp_id ON logs (timestamp, id);
+DROP INDEX IF EXISTS idx_logs_timestamp;
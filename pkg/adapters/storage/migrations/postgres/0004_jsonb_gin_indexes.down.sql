@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_logs_response_headers_gin;
+DROP INDEX IF EXISTS idx_logs_request_headers_gin;
+DROP INDEX IF EXISTS idx_logs_response_body_gin;
+DROP INDEX IF EXISTS idx_logs_request_body_gin;
@@ -0,0 +1,6 @@
+-- Body 和 Header 的等值条件编译为 @> 包含运算，jsonb_path_ops 索引只支持 @>，体积比默认的 jsonb_ops 小
+-- 在分区表上创建时会同时为所有分区创建索引，之后挂载的分区自动补建
+CREATE INDEX IF NOT EXISTS idx_logs_request_body_gin ON logs USING GIN (request_body jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_logs_response_body_gin ON logs USING GIN (response_body jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_logs_request_headers_gin ON logs USING GIN (request_headers jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_logs_response_headers_gin ON logs USING GIN (response_headers jsonb_path_ops);
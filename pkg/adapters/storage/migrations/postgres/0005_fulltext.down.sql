@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_logs_search_vector;
+ALTER TABLE logs DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,9 @@
+-- 全文搜索：消息、URL 以及请求和响应 Body 中的字符串和数字合并为生成列，权重依次为 A、B、C
+-- 使用 simple 配置，不做词干提取，适合日志中的标识符和多语言文本；新分区通过 INCLUDING GENERATED 复制该列
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(message, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(url, '')), 'B') ||
+    setweight(jsonb_to_tsvector('simple', coalesce(request_body, 'null'), '["string", "numeric"]'), 'C') ||
+    setweight(jsonb_to_tsvector('simple', coalesce(response_body, 'null'), '["string", "numeric"]'), 'C')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_logs_search_vector ON logs USING GIN (search_vector);
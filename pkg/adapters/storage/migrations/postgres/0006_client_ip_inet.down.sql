@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_logs_client_inet;
+DROP FUNCTION IF EXISTS try_inet(TEXT);
@@ -0,0 +1,12 @@
+-- client_ip 是文本列，采集端上报的值不一定是合法的地址；try_inet 对无法解析的值返回 NULL 而不是报错，
+-- 网段筛选编译为 try_inet(client_ip) <<= cidr，可以使用下面的 GiST 表达式索引
+CREATE OR REPLACE FUNCTION try_inet(value TEXT) RETURNS inet
+LANGUAGE plpgsql IMMUTABLE PARALLEL SAFE AS $$
+BEGIN
+    RETURN value::inet;
+EXCEPTION WHEN invalid_text_representation THEN
+    RETURN NULL;
+END;
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_logs_client_inet ON logs USING GIST ((try_inet(client_ip)) inet_ops);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_logs_route;
+ALTER TABLE logs DROP COLUMN IF EXISTS handler;
+ALTER TABLE logs DROP COLUMN IF EXISTS route;
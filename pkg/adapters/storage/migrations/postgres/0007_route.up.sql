@@ -0,0 +1,5 @@
+-- 路由模板 (如 /users/:id) 和处理函数名，按路由搜索和统计时不再受路径参数影响
+-- 旧数据的 route 为空，统计时按不含查询参数的路径分组
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS route TEXT NOT NULL DEFAULT '';
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS handler TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_logs_route ON logs (route, timestamp);
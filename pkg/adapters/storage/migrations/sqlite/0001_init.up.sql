@@ -0,0 +1,42 @@
+-- 基线结构。时间以 Unix 纳秒整数保存，JSON 字段以文本保存
+CREATE TABLE IF NOT EXISTS logs (
+    id TEXT PRIMARY KEY,
+    track_id TEXT NOT NULL,
+    timestamp INTEGER NOT NULL,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    method TEXT NOT NULL DEFAULT '',
+    url TEXT NOT NULL DEFAULT '',
+    status_code INTEGER NOT NULL DEFAULT 0,
+    client_ip TEXT NOT NULL DEFAULT '',
+    service TEXT NOT NULL DEFAULT '',
+    environment TEXT NOT NULL DEFAULT '',
+    level TEXT NOT NULL DEFAULT '',
+    message TEXT NOT NULL DEFAULT '',
+    request_headers TEXT,
+    request_query_params TEXT,
+    request_body TEXT,
+    response_headers TEXT,
+    response_body TEXT,
+    response_size INTEGER NOT NULL DEFAULT 0,
+    request_size INTEGER NOT NULL DEFAULT 0,
+    request_body_truncated INTEGER NOT NULL DEFAULT 0,
+    response_body_truncated INTEGER NOT NULL DEFAULT 0,
+    trace_id TEXT NOT NULL DEFAULT '',
+    span_id TEXT NOT NULL DEFAULT '',
+    parent_span_id TEXT NOT NULL DEFAULT '',
+    kind TEXT NOT NULL DEFAULT 'server'
+);
+CREATE INDEX IF NOT EXISTS idx_logs_track_id ON logs (track_id);
+CREATE INDEX IF NOT EXISTS idx_logs_timestamp ON logs (timestamp);
+CREATE INDEX IF NOT EXISTS idx_logs_method ON logs (method);
+CREATE INDEX IF NOT EXISTS idx_logs_status ON logs (status_code);
+CREATE INDEX IF NOT EXISTS idx_logs_level ON logs (level);
+CREATE INDEX IF NOT EXISTS idx_logs_trace_id ON logs (trace_id);
+
+CREATE TABLE IF NOT EXISTS users (
+    username TEXT PRIMARY KEY,
+    password_hash TEXT NOT NULL,
+    role TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
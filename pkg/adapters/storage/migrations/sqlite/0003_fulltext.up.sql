@@ -0,0 +1,28 @@
+-- 全文搜索：FTS5 表按 logs 的 rowid 保存消息、URL 以及请求和响应 Body 中的字符串和数字，由触发器同步
+-- 不去除变音符号，与 Postgres 的 simple 配置保持一致。logs 没有 INTEGER PRIMARY KEY，VACUUM 后 rowid 可能变化，
+-- 此时需要清空 logs_fts 并重新执行本文件末尾的 INSERT
+CREATE VIRTUAL TABLE IF NOT EXISTS logs_fts USING fts5(message, url, body, tokenize = 'unicode61 remove_diacritics 0');
+
+CREATE TRIGGER IF NOT EXISTS logs_fts_insert AFTER INSERT ON logs BEGIN
+    INSERT INTO logs_fts (rowid, message, url, body) VALUES (new.rowid, new.message, new.url,
+        coalesce((SELECT group_concat(value, ' ') FROM json_tree(CAST(new.request_body AS TEXT)) WHERE type IN ('text', 'integer', 'real')), '') || ' ' ||
+        coalesce((SELECT group_concat(value, ' ') FROM json_tree(CAST(new.response_body AS TEXT)) WHERE type IN ('text', 'integer', 'real')), ''));
+END;
+
+-- 幂等写入的 ON CONFLICT DO UPDATE 触发 UPDATE，rowid 不变
+CREATE TRIGGER IF NOT EXISTS logs_fts_update AFTER UPDATE ON logs BEGIN
+    DELETE FROM logs_fts WHERE rowid = old.rowid;
+    INSERT INTO logs_fts (rowid, message, url, body) VALUES (new.rowid, new.message, new.url,
+        coalesce((SELECT group_concat(value, ' ') FROM json_tree(CAST(new.request_body AS TEXT)) WHERE type IN ('text', 'integer', 'real')), '') || ' ' ||
+        coalesce((SELECT group_concat(value, ' ') FROM json_tree(CAST(new.response_body AS TEXT)) WHERE type IN ('text', 'integer', 'real')), ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS logs_fts_delete AFTER DELETE ON logs BEGIN
+    DELETE FROM logs_fts WHERE rowid = old.rowid;
+END;
+
+INSERT INTO logs_fts (rowid, message, url, body)
+SELECT rowid, message, url,
+    coalesce((SELECT group_concat(value, ' ') FROM json_tree(CAST(request_body AS TEXT)) WHERE type IN ('text', 'integer', 'real')), '') || ' ' ||
+    coalesce((SELECT group_concat(value, ' ') FROM json_tree(CAST(response_body AS TEXT)) WHERE type IN ('text', 'integer', 'real')), '')
+FROM logs;
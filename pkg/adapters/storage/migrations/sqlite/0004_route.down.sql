@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_logs_route;
+ALTER TABLE logs DROP COLUMN handler;
+ALTER TABLE logs DROP COLUMN route;
@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// PartitionInterval logs 表的分区粒度
+type PartitionInterval string
+
+const (
+	PartitionDaily  PartitionInterval = "day"
+	PartitionWeekly PartitionInterval = "week"
+)
+
+// ParsePartitionInterval 解析配置中的分区粒度
+func ParsePartitionInterval(s string) (PartitionInterval, error) {
+	switch PartitionInterval(s) {
+	case PartitionDaily, PartitionWeekly:
+		return PartitionInterval(s), nil
+	}
+	return "", fmt.Errorf("unknown partition interval %q (want day or week)", s)
+}
+
+// 分区管理的默认参数
+const (
+	DefaultPartitionPremake       = 3
+	DefaultPartitionCheckInterval = time.Hour
+)
+
+// partitionLockKey 是分区维护使用的 advisory lock 键 ("tbpartit")，多个实例中同一时间只有一个在维护分区
+const partitionLockKey int64 = 0x7462706172746974
+
+// PartitionConfig 分区管理配置，分区边界按 UTC 划分，按周分区从周一开始
+type PartitionConfig struct {
+	Interval      PartitionInterval
+	Premake       int           // 预先创建的未来分区数，不含当前分区
+	Retention     time.Duration // 分区的时间范围整体早于该时长后过期，0 表示不清理
+	Detach        bool          // 过期分区只从 logs 分离，保留为独立的表用于归档，否则直接删除
+	CheckInterval time.Duration // 后台维护的间隔
+}
+
+// PartitionReport 一次维护创建和清理的分区
+type PartitionReport struct {
+	Created []string
+	Removed []string
+}
+
+// PartitionManager 维护 logs 表的时间分区：预先创建未来的分区，分离或删除过期的分区
+// 没有对应分区的数据写入默认分区 logs_default，创建分区时会把默认分区中落在该范围的数据迁入
+type PartitionManager struct {
+	db  *sql.DB
+	cfg PartitionConfig
+	now func() time.Time
+}
+
+// NewPartitionManager 为 Postgres 仓库创建分区管理器
+func NewPartitionManager(repo *PostgresRepository, cfg PartitionConfig) (*PartitionManager, error) {
+	if _, err := ParsePartitionInterval(string(cfg.Interval)); err != nil {
+		return nil, err
+	}
+	if cfg.Premake <= 0 {
+		cfg.Premake = DefaultPartitionPremake
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = DefaultPartitionCheckInterval
+	}
+	return &PartitionManager{db: repo.db, cfg: cfg, now: time.Now}, nil
+}
+
+// Run 立即维护一次，之后按 CheckInterval 定期维护，直到 ctx 结束
+func (m *PartitionManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		report, err := m.Maintain(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Partition maintenance failed: %v", err)
+		}
+		for _, name := range report.Created {
+			log.Printf("Created log partition %s", name)
+		}
+		for _, name := range report.Removed {
+			log.Printf("Removed expired log partition %s", name)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Maintain 创建当前及未来的分区并清理过期分区；其他实例正在维护时直接返回
+func (m *PartitionManager) Maintain(ctx context.Context) (PartitionReport, error) {
+	var report PartitionReport
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, partitionLockKey).Scan(&locked); err != nil {
+		return report, err
+	}
+	if !locked {
+		return report, nil
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, partitionLockKey)
+
+	existing, err := listPartitions(ctx, conn)
+	if err != nil {
+		return report, err
+	}
+
+	now := m.now().UTC()
+	start := m.cfg.Interval.periodStart(now)
+	for i := 0; i <= m.cfg.Premake; i++ {
+		name := m.cfg.Interval.partitionName(start)
+		end := m.cfg.Interval.next(start)
+		// 切换粒度后新旧分区范围可能重叠，重叠的范围沿用已有分区
+		if !overlaps(existing, start, end) {
+			if err := createPartition(ctx, conn, name, start, end); err != nil {
+				return report, fmt.Errorf("create partition %s: %w", name, err)
+			}
+			report.Created = append(report.Created, name)
+		}
+		start = end
+	}
+
+	if m.cfg.Retention <= 0 {
+		return report, nil
+	}
+	cutoff := now.Add(-m.cfg.Retention)
+	var expired []string
+	for name, r := range existing {
+		if !r.end.After(cutoff) {
+			expired = append(expired, name)
+		}
+	}
+	sort.Strings(expired)
+	for _, name := range expired {
+		stmt := `DROP TABLE ` + name
+		if m.cfg.Detach {
+			stmt = `ALTER TABLE logs DETACH PARTITION ` + name
+		}
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return report, fmt.Errorf("remove partition %s: %w", name, err)
+		}
+		report.Removed = append(report.Removed, name)
+	}
+	return report, nil
+}
+
+// partitionRange 分区的时间范围 [start, end)
+type partitionRange struct {
+	start, end time.Time
+}
+
+func overlaps(partitions map[string]partitionRange, start, end time.Time) bool {
+	for _, r := range partitions {
+		if r.start.Before(end) && start.Before(r.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// listPartitions 返回 logs 当前挂接的时间分区及其范围，不含默认分区
+func listPartitions(ctx context.Context, conn *sql.Conn) (map[string]partitionRange, error) {
+	rows, err := conn.QueryContext(ctx, `
+        SELECT c.relname FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        WHERE i.inhparent = 'logs'::regclass`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	partitions := map[string]partitionRange{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if interval, start, ok := parsePartitionName(name); ok {
+			partitions[name] = partitionRange{start, interval.next(start)}
+		}
+	}
+	return partitions, rows.Err()
+}
+
+// createPartition 在一个事务中创建分区表、迁入默认分区中落在该范围的数据并挂接到 logs
+// 挂接时会校验默认分区中不再有该范围的数据，期间并发写入的数据会导致失败，下次维护时重试
+func createPartition(ctx context.Context, conn *sql.Conn, name string, start, end time.Time) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	bounds := fmt.Sprintf(`FOR VALUES FROM ('%s') TO ('%s')`, pgTimestamp(start), pgTimestamp(end))
+	stmts := []string{
+		`CREATE TABLE ` + name + ` (LIKE logs INCLUDING DEFAULTS INCLUDING GENERATED)`,
+		`WITH moved AS (
+            DELETE FROM logs_default WHERE timestamp >= $1 AND timestamp < $2 RETURNING ` + logColumns + `
+        ) INSERT INTO ` + name + ` (` + logColumns + `) SELECT ` + logColumns + ` FROM moved`,
+		`ALTER TABLE logs ATTACH PARTITION ` + name + ` ` + bounds,
+	}
+	for i, stmt := range stmts {
+		var args []interface{}
+		if i == 1 {
+			args = []interface{}{start, end}
+		}
+		if _, err := tx.ExecContext(ctx, stmt, args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func pgTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05+00")
+}
+
+// periodStart 返回 t 所在分区的起始时间
+func (p PartitionInterval) periodStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if p == PartitionWeekly {
+		// 以周一为一周的开始，与 ISO 周一致
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	}
+	return day
+}
+
+// next 返回下一个分区的起始时间
+func (p PartitionInterval) next(start time.Time) time.Time {
+	if p == PartitionWeekly {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// partitionName 按天分区命名为 logs_p20240131，按周分区按 ISO 周命名为 logs_p2024w05
+// 名称中包含粒度，切换粒度后仍能正确计算旧分区的范围
+func (p PartitionInterval) partitionName(start time.Time) string {
+	if p == PartitionWeekly {
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("logs_p%04dw%02d", year, week)
+	}
+	return "logs_p" + start.Format("20060102")
+}
+
+var (
+	dailyPartitionRe  = regexp.MustCompile(`^logs_p(\d{8})$`)
+	weeklyPartitionRe = regexp.MustCompile(`^logs_p(\d{4})w(\d{2})$`)
+)
+
+// parsePartitionName 从分区名解析粒度和起始时间，不是由 PartitionManager 创建的表返回 false
+func parsePartitionName(name string) (PartitionInterval, time.Time, bool) {
+	if m := dailyPartitionRe.FindStringSubmatch(name); m != nil {
+		start, err := time.Parse("20060102", m[1])
+		return PartitionDaily, start, err == nil
+	}
+	if m := weeklyPartitionRe.FindStringSubmatch(name); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		week, _ := strconv.Atoi(m[2])
+		// 1 月 4 日总在第 1 周内；不存在的周 (如没有第 53 周的年份) 反算后名称对不上
+		jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+		start := PartitionWeekly.periodStart(jan4).AddDate(0, 0, (week-1)*7)
+		return PartitionWeekly, start, week > 0 && PartitionWeekly.partitionName(start) == name
+	}
+	return "", time.Time{}, false
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPartitionPeriods(t *testing.T) {
+	// 2024-01-03 是周三，所在 ISO 周从 2024-01-01 (周一) 开始
+	ts := time.Date(2024, 1, 3, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		interval PartitionInterval
+		start    time.Time
+		next     time.Time
+		name     string
+	}{
+		{PartitionDaily, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), "logs_p20240103"},
+		{PartitionWeekly, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), "logs_p2024w01"},
+	}
+	for _, tt := range tests {
+		start := tt.interval.periodStart(ts)
+		if !start.Equal(tt.start) || !tt.interval.next(start).Equal(tt.next) {
+			t.Errorf("%s: 范围 [%v, %v)", tt.interval, start, tt.interval.next(start))
+		}
+		name := tt.interval.partitionName(start)
+		if name != tt.name {
+			t.Errorf("%s: 分区名 %s，期望 %s", tt.interval, name, tt.name)
+		}
+		interval, parsed, ok := parsePartitionName(name)
+		if !ok || interval != tt.interval || !parsed.Equal(start) {
+			t.Errorf("%s: 解析 %s 得到 %s %v %v", tt.interval, name, interval, parsed, ok)
+		}
+	}
+}
+
+func TestParsePartitionName(t *testing.T) {
+	// ISO 周所属的年份可能与日期的年份不同
+	if _, start, ok := parsePartitionName("logs_p2021w01"); !ok || !start.Equal(time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("logs_p2021w01: %v %v", start, ok)
+	}
+	if _, start, ok := parsePartitionName("logs_p2020w53"); !ok || !start.Equal(time.Date(2020, 12, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("logs_p2020w53: %v %v", start, ok)
+	}
+	for _, name := range []string{"logs_default", "logs_p2021w53", "logs_p2024w00", "logs_p20241301", "logs_p2024"} {
+		if _, _, ok := parsePartitionName(name); ok {
+			t.Errorf("%s 不应被识别为分区", name)
+		}
+	}
+}
+
+func TestPartitionOverlaps(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	existing := map[string]partitionRange{"logs_p20240102": {day(2), day(3)}}
+	if !overlaps(existing, day(1), day(8)) {
+		t.Error("按周分区与已有的按天分区重叠")
+	}
+	if overlaps(existing, day(3), day(4)) {
+		t.Error("相邻分区不重叠")
+	}
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/storage/storagetest"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+)
+
+// newTestPostgres 连接 TRACEBUDDY_TEST_POSTGRES_DSN 指定的数据库，未设置时跳过测试
+func newTestPostgres(t *testing.T) *PostgresRepository {
+	t.Helper()
+	dsn := os.Getenv("TRACEBUDDY_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TRACEBUDDY_TEST_POSTGRES_DSN not set")
+	}
+	repo, err := NewPostgresRepository(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+// Postgres 一致性测试，测试会清空 logs 表
+func TestPostgresRepositoryConformance(t *testing.T) {
+	repo := newTestPostgres(t)
+
+	storagetest.RunLogRepositoryTests(t, func(t *testing.T) ports.LogRepository {
+		if _, err := repo.db.ExecContext(context.Background(), `TRUNCATE logs`); err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
+
+// explainIndexed 在禁用顺序扫描的事务中返回查询计划，测试表数据量很小，禁用后才能观察到索引是否可用
+func explainIndexed(t *testing.T, repo *PostgresRepository, query string, args ...interface{}) string {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SET LOCAL enable_seqscan = off`); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := tx.QueryContext(ctx, `EXPLAIN `+query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var line string
+		rows.Scan(&line)
+		plan = append(plan, line)
+	}
+	return strings.Join(plan, "\n")
+}
+
+// Body 的等值条件编译为 @> 后应能使用 GIN 索引
+func TestPostgresJSONContainmentUsesIndex(t *testing.T) {
+	repo := newTestPostgres(t)
+
+	expr, err := ports.LogSearchQuery{JSON: map[string]interface{}{"response_body.error.code": "INSUFFICIENT_FUNDS"}}.Expr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var args []interface{}
+	where := repo.compileQuery(expr, func(v interface{}) string {
+		args = append(args, v)
+		return repo.dialect.placeholder(len(args))
+	})
+	if plan := explainIndexed(t, repo, `SELECT id FROM logs WHERE `+where, args...); !strings.Contains(plan, "Bitmap Index Scan") || !strings.Contains(plan, "response_body") {
+		t.Errorf("查询未使用 GIN 索引:\n%s", plan)
+	}
+}
+
+// 状态码范围直接比较列，可以使用 status_code 上的索引
+func TestPostgresStatusRangeUsesIndex(t *testing.T) {
+	repo := newTestPostgres(t)
+
+	expr, err := ports.LogSearchQuery{LogFilter: ports.LogFilter{StatusClasses: []string{"5xx"}}}.Expr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var args []interface{}
+	where := repo.compileQuery(expr, func(v interface{}) string {
+		args = append(args, v)
+		return repo.dialect.placeholder(len(args))
+	})
+	if plan := explainIndexed(t, repo, `SELECT id FROM logs WHERE `+where, args...); !strings.Contains(plan, "Index Cond: ((status_code >=") {
+		t.Errorf("查询未使用状态码索引:\n%s", plan)
+	}
+}
+
+// 路由列表直接比较列，可以使用 idx_logs_route
+func TestPostgresRouteFilterUsesIndex(t *testing.T) {
+	repo := newTestPostgres(t)
+
+	expr, err := ports.LogSearchQuery{LogFilter: ports.LogFilter{Routes: []string{"/users/:id", "/orders/:id"}}}.Expr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var args []interface{}
+	where := repo.compileQuery(expr, func(v interface{}) string {
+		args = append(args, v)
+		return repo.dialect.placeholder(len(args))
+	})
+	if plan := explainIndexed(t, repo, `SELECT id FROM logs WHERE `+where, args...); !strings.Contains(plan, "Index Cond: (route = ANY") {
+		t.Errorf("查询未使用路由索引:\n%s", plan)
+	}
+}
+
+// 网段筛选使用 try_inet(client_ip) 上的 GiST 索引，非法地址不会导致查询报错
+func TestPostgresClientCIDR(t *testing.T) {
+	repo := newTestPostgres(t)
+	ctx := context.Background()
+	if _, err := repo.db.ExecContext(ctx, `TRUNCATE logs`); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	for i, ip := range []string{"10.1.2.3", "not an ip", "2001:db8::1"} {
+		if err := repo.Save(ctx, domain.LogEntry{ID: fmt.Sprintf("cidr-%d", i), TrackID: "cidr", Timestamp: now, ClientIP: ip}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, _, err := repo.Search(ctx, ports.LogSearchQuery{LogFilter: ports.LogFilter{ClientIPs: []string{"10.0.0.0/8", "2001:db8::/32"}}})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("网段筛选: %d %v", len(entries), err)
+	}
+
+	expr, err := ports.LogSearchQuery{LogFilter: ports.LogFilter{ClientIPs: []string{"10.0.0.0/8"}}}.Expr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var args []interface{}
+	where := repo.compileQuery(expr, func(v interface{}) string {
+		args = append(args, v)
+		return repo.dialect.placeholder(len(args))
+	})
+	if plan := explainIndexed(t, repo, `SELECT id FROM logs WHERE `+where, args...); !strings.Contains(plan, "Index Cond: (try_inet(client_ip)") {
+		t.Errorf("查询未使用 GiST 索引:\n%s", plan)
+	}
+}
+
+// 全文搜索使用 search_vector 上的 GIN 索引
+func TestPostgresFullTextUsesIndex(t *testing.T) {
+	repo := newTestPostgres(t)
+
+	terms, err := ports.LogSearchQuery{Text: `"card declined" issu*`}.TextTerms()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tsquery := repo.textQuery(terms)
+	if tsquery != "'card' <-> 'declined' & 'issu':*" {
+		t.Errorf("tsquery: %s", tsquery)
+	}
+	if plan := explainIndexed(t, repo, `SELECT id FROM logs WHERE `+repo.textMatch("$1"), tsquery); !strings.Contains(plan, "search_vector") || !strings.Contains(plan, "Index") {
+		t.Errorf("查询未使用全文索引:\n%s", plan)
+	}
+}
+
+func TestPostgresPartitionManager(t *testing.T) {
+	repo := newTestPostgres(t)
+	ctx := context.Background()
+	if _, err := repo.db.ExecContext(ctx, `TRUNCATE logs`); err != nil {
+		t.Fatal(err)
+	}
+
+	// 先写入默认分区，创建当天分区时应迁入
+	now := time.Now().UTC()
+	if err := repo.Save(ctx, domain.LogEntry{ID: "partitioned", TrackID: "p", Timestamp: now}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewPartitionManager(repo, PartitionConfig{Interval: PartitionDaily, Premake: 1, Retention: 48 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := PartitionDaily.partitionName(PartitionDaily.periodStart(now))
+	t.Cleanup(func() {
+		for i := 0; i < 2; i++ {
+			name := PartitionDaily.partitionName(PartitionDaily.periodStart(now.AddDate(0, 0, i)))
+			repo.db.ExecContext(context.Background(), `DROP TABLE IF EXISTS `+name)
+		}
+	})
+
+	report, err := m.Maintain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Created) != 2 || report.Created[0] != today {
+		t.Fatalf("应创建当天和次日的分区，得到 %+v", report)
+	}
+	var table string
+	if err := repo.db.QueryRowContext(ctx, `SELECT tableoid::regclass::text FROM logs WHERE id = 'partitioned'`).Scan(&table); err != nil {
+		t.Fatal(err)
+	}
+	if table != today {
+		t.Errorf("默认分区中的数据应迁入 %s，实际在 %s", today, table)
+	}
+
+	// 带时间范围的查询只扫描对应分区
+	dayStart := PartitionDaily.periodStart(now)
+	rows, err := repo.db.QueryContext(ctx, `EXPLAIN SELECT id FROM logs WHERE timestamp >= $1 AND timestamp <= $2`,
+		dayStart, dayStart.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plan []string
+	for rows.Next() {
+		var line string
+		rows.Scan(&line)
+		plan = append(plan, line)
+	}
+	rows.Close()
+	if joined := strings.Join(plan, "\n"); strings.Contains(joined, "logs_default") {
+		t.Errorf("查询未裁剪分区:\n%s", joined)
+	}
+
+	// 过期的分区被删除
+	m.now = func() time.Time { return now.AddDate(0, 0, 5) }
+	report, err = m.Maintain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Removed) == 0 || report.Removed[0] != today {
+		t.Errorf("%s 应已过期，得到 %+v", today, report)
+	}
+	// 清理本次新建的未来分区
+	for _, name := range report.Created {
+		repo.db.ExecContext(ctx, `DROP TABLE IF EXISTS `+name)
+	}
+}
@@ -29,12 +29,12 @@ func (r *RedisRepository) Save(ctx context.Context, entry domain.LogEntry, ttl t
 	if err != nil {
 		return err
 	}
-	return r.client.Set(ctx, "log:"+entry.TrackID, data, ttl).Err()
+	return r.client.Set(ctx, "log:"+entry.ID, data, ttl).Err()
 }
 
 // Get 从 Redis 缓存中获取日志条目
-func (r *RedisRepository) Get(ctx context.Context, trackID string) (*domain.LogEntry, error) {
-	val, err := r.client.Get(ctx, "log:"+trackID).Result()
+func (r *RedisRepository) Get(ctx context.Context, id string) (*domain.LogEntry, error) {
+	val, err := r.client.Get(ctx, "log:"+id).Result()
 	if err == redis.Nil {
 		return nil, nil
 	}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+)
+
+// 清理任务的默认参数
+const (
+	DefaultRetentionBatchSize = 1000
+	DefaultRetentionInterval  = time.Hour
+)
+
+// RetentionConfig 清理任务配置
+type RetentionConfig struct {
+	Policy    domain.RetentionPolicy
+	BatchSize int           // 每批删除的最大条数
+	Interval  time.Duration // 定期清理的间隔
+}
+
+// RetentionRuleReport 单条规则的清理结果，预览时 Deleted 为将要删除的数量
+type RetentionRuleReport struct {
+	Rule    string     `json:"rule"`
+	Cutoff  *time.Time `json:"cutoff,omitempty"` // 早于该时间的日志被删除，永久保留时为空
+	Deleted int64      `json:"deleted"`
+}
+
+// RetentionReport 一次清理或预览的结果
+type RetentionReport struct {
+	DryRun     bool                  `json:"dry_run"`
+	StartedAt  time.Time             `json:"started_at"`
+	FinishedAt time.Time             `json:"finished_at"`
+	Deleted    int64                 `json:"deleted"`
+	Rules      []RetentionRuleReport `json:"rules"`
+	Error      string                `json:"error,omitempty"`
+}
+
+// RetentionJob 按保留策略分批删除过期日志，同一时间只执行一次清理
+type RetentionJob struct {
+	purger ports.LogPurger
+	cfg    RetentionConfig
+	now    func() time.Time
+
+	runMu sync.Mutex // 串行化清理和预览
+	mu    sync.Mutex
+	last  *RetentionReport
+}
+
+// NewRetentionJob 创建清理任务，策略无效时返回错误
+func NewRetentionJob(purger ports.LogPurger, cfg RetentionConfig) (*RetentionJob, error) {
+	if err := cfg.Policy.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultRetentionBatchSize
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultRetentionInterval
+	}
+	return &RetentionJob{purger: purger, cfg: cfg, now: time.Now}, nil
+}
+
+// LoadRetentionPolicy 从 JSON 文件读取保留规则，文件内容为规则数组
+func LoadRetentionPolicy(path string) (domain.RetentionPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policy domain.RetentionPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+	return policy, policy.Validate()
+}
+
+// Policy 返回当前的保留策略
+func (j *RetentionJob) Policy() domain.RetentionPolicy {
+	return j.cfg.Policy
+}
+
+// LastReport 返回最近一次实际清理的结果，尚未执行过时返回 nil
+func (j *RetentionJob) LastReport() *RetentionReport {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.last
+}
+
+// Run 按 Interval 定期清理，直到 ctx 结束
+func (j *RetentionJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		report, err := j.Purge(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Retention purge failed: %v", err)
+		}
+		for _, r := range report.Rules {
+			if r.Deleted > 0 {
+				log.Printf("Retention rule %s removed %d log entries older than %s", r.Rule, r.Deleted, r.Cutoff.Format(time.RFC3339))
+			}
+		}
+	}
+}
+
+// Purge 立即执行一次清理，中途出错时返回已删除部分的结果
+func (j *RetentionJob) Purge(ctx context.Context) (RetentionReport, error) {
+	return j.run(ctx, false)
+}
+
+// Preview 统计按当前策略将被删除的日志数量，不删除数据
+func (j *RetentionJob) Preview(ctx context.Context) (RetentionReport, error) {
+	return j.run(ctx, true)
+}
+
+func (j *RetentionJob) run(ctx context.Context, dryRun bool) (RetentionReport, error) {
+	j.runMu.Lock()
+	defer j.runMu.Unlock()
+
+	now := j.now()
+	report := RetentionReport{DryRun: dryRun, StartedAt: now}
+	var err error
+	for i, rule := range j.cfg.Policy {
+		r := RetentionRuleReport{Rule: rule.Name}
+		if cutoff := rule.Cutoff(now); !cutoff.IsZero() {
+			r.Cutoff = &cutoff
+		}
+		if dryRun {
+			r.Deleted, err = j.purger.CountExpired(ctx, j.cfg.Policy, i, now)
+		} else {
+			r.Deleted, err = j.deleteRule(ctx, i, now)
+		}
+		report.Deleted += r.Deleted
+		report.Rules = append(report.Rules, r)
+		if err != nil {
+			report.Error = err.Error()
+			break
+		}
+	}
+	report.FinishedAt = j.now()
+
+	if !dryRun {
+		j.mu.Lock()
+		j.last = &report
+		j.mu.Unlock()
+	}
+	return report, err
+}
+
+// deleteRule 分批删除一条规则负责的过期日志，直到某一批不足 BatchSize
+func (j *RetentionJob) deleteRule(ctx context.Context, rule int, now time.Time) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		n, err := j.purger.DeleteExpired(ctx, j.cfg.Policy, rule, now, j.cfg.BatchSize)
+		total += n
+		if err != nil || n < int64(j.cfg.BatchSize) {
+			return total, err
+		}
+	}
+}
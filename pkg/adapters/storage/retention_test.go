@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+)
+
+func TestRetentionJob(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	repo := NewMemoryRepository()
+	for i := 0; i < 5; i++ {
+		level := "info"
+		if i%2 == 0 {
+			level = "error"
+		}
+		repo.Save(ctx, domain.LogEntry{ID: fmt.Sprintf("old-%d", i), Level: level, Timestamp: now.AddDate(0, 0, -10)})
+	}
+	repo.Save(ctx, domain.LogEntry{ID: "new", Timestamp: now})
+
+	job, err := NewRetentionJob(repo, RetentionConfig{
+		Policy:    domain.RetentionPolicy{{Level: "error", KeepDays: 30}, {KeepDays: 7}},
+		BatchSize: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.now = func() time.Time { return now }
+
+	preview, err := job.Preview(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !preview.DryRun || preview.Deleted != 2 || len(repo.entries) != 6 {
+		t.Fatalf("预览应统计 2 条且不删除: %+v, 剩余 %d", preview, len(repo.entries))
+	}
+	if job.LastReport() != nil {
+		t.Error("预览不应记录为最近一次清理")
+	}
+
+	report, err := job.Purge(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Deleted != 2 || report.Rules[0].Rule != "rule-1" || report.Rules[0].Deleted != 0 || report.Rules[1].Deleted != 2 {
+		t.Errorf("清理结果: %+v", report)
+	}
+	if len(repo.entries) != 4 {
+		t.Errorf("应剩余 3 条 error 和 1 条新日志，剩余 %d", len(repo.entries))
+	}
+	if last := job.LastReport(); last == nil || last.Deleted != 2 {
+		t.Errorf("LastReport: %+v", last)
+	}
+}
+
+func TestRetentionPolicyValidate(t *testing.T) {
+	invalid := []domain.RetentionPolicy{
+		{{KeepDays: -1}},
+		{{StatusMin: 500, StatusMax: 400, KeepDays: 1}},
+		{{Name: "a", KeepDays: 1}, {Name: "a", KeepDays: 2}},
+	}
+	for _, policy := range invalid {
+		if _, err := NewRetentionJob(NewMemoryRepository(), RetentionConfig{Policy: policy}); err == nil {
+			t.Errorf("%+v 应无效", policy)
+		}
+	}
+}
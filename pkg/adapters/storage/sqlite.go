@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"modernc.org/sqlite"
+)
+
+func init() {
+	// 注册后对之后打开的所有 SQLite 连接生效
+	sqlite.MustRegisterDeterministicScalarFunction("ip_within", 2, ipWithin)
+	sqlite.MustRegisterFunction("percentile_linear", &sqlite.FunctionImpl{
+		NArgs:         2,
+		Deterministic: true,
+		MakeAggregate: func(sqlite.FunctionContext) (sqlite.AggregateFunction, error) { return &percentileAgg{}, nil },
+	})
+}
+
+// percentileAgg 实现聚合函数 percentile_linear(x, p)，忽略 NULL，与 Postgres 的 percentile_cont(p) 结果一致
+type percentileAgg struct {
+	values []float64
+	p      float64
+}
+
+func (a *percentileAgg) Step(_ *sqlite.FunctionContext, args []driver.Value) error {
+	switch v := args[0].(type) {
+	case int64:
+		a.values = append(a.values, float64(v))
+	case float64:
+		a.values = append(a.values, v)
+	}
+	if p, ok := args[1].(float64); ok {
+		a.p = p
+	}
+	return nil
+}
+
+func (a *percentileAgg) WindowInverse(*sqlite.FunctionContext, []driver.Value) error {
+	return errors.New("percentile_linear cannot be used as a window function")
+}
+
+func (a *percentileAgg) WindowValue(*sqlite.FunctionContext) (driver.Value, error) {
+	if len(a.values) == 0 {
+		return nil, nil
+	}
+	sorted := slices.Clone(a.values)
+	slices.Sort(sorted)
+	return percentile(sorted, a.p), nil
+}
+
+func (a *percentileAgg) Final(*sqlite.FunctionContext) {}
+
+// ipWithin 实现 ip_within(ip, cidr)：ip 属于网段时返回 1，ip 为 NULL 或无法解析时返回 0，与 query.Match 一致
+func ipWithin(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	prefix, err := netip.ParsePrefix(sqliteText(args[1]))
+	if err != nil {
+		return nil, err
+	}
+	addr, err := netip.ParseAddr(sqliteText(args[0]))
+	if err != nil || !prefix.Contains(addr) {
+		return int64(0), nil
+	}
+	return int64(1), nil
+}
+
+// sqliteText 读取函数参数中的文本，其他类型返回空字符串
+func sqliteText(v driver.Value) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case []byte:
+		return string(x)
+	}
+	return ""
+}
+
+// SQLiteRepository 基于 SQLite 的日志和用户存储，适用于本地开发和单机部署
+// 时间以 Unix 纳秒整数保存，JSON 字段以文本保存
+type SQLiteRepository struct {
+	sqlStore
+}
+
+// NewSQLiteRepository 打开或创建 SQLite 数据库并执行未执行的迁移，dsn 为文件路径或 file: URI，
+// ":memory:" 表示内存数据库 (仅用于测试)
+func NewSQLiteRepository(dsn string) (*SQLiteRepository, error) {
+	db, err := openSQLite(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateUp(db, sqliteDialect); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteRepository{sqlStore{db: db, dialect: sqliteDialect}}, nil
+}
+
+func openSQLite(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", sqliteDSN(dsn))
+	if err != nil {
+		return nil, err
+	}
+	if isSQLiteMemory(dsn) {
+		// 内存数据库每个连接各自独立，只能使用一个连接
+		db.SetMaxOpenConns(1)
+	}
+	// WAL 模式下读写互不阻塞，设置会持久化在数据库文件中；不能在事务中设置，因此不放在迁移里
+	if _, err := db.Exec(`PRAGMA journal_mode = WAL`); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// sqliteDSN 补充默认的连接参数：写锁冲突时等待而不是立即返回 SQLITE_BUSY
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "busy_timeout") {
+		return dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "_pragma=busy_timeout(5000)"
+}
+
+func isSQLiteMemory(dsn string) bool {
+	return strings.HasPrefix(dsn, ":memory:") || strings.Contains(dsn, "mode=memory")
+}
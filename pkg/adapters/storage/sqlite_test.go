@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/storage/storagetest"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+)
+
+func newTestSQLite(t *testing.T) *SQLiteRepository {
+	t.Helper()
+	r, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "tracebuddy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestSQLiteRepositoryConformance(t *testing.T) {
+	storagetest.RunLogRepositoryTests(t, func(t *testing.T) ports.LogRepository {
+		return newTestSQLite(t)
+	})
+}
+
+func TestSQLiteFindUserByUsername(t *testing.T) {
+	r := newTestSQLite(t)
+	ctx := context.Background()
+	now := time.Now()
+	if _, err := r.db.ExecContext(ctx, `INSERT INTO users VALUES (?1, ?2, ?3, ?4, ?4)`,
+		"admin", "hash", "admin", sqliteDialect.timeArg(now)); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := r.FindUserByUsername(ctx, "admin")
+	if err != nil || u == nil || u.Role != "admin" || !u.CreatedAt.Equal(now) {
+		t.Fatalf("FindUserByUsername: %+v %v", u, err)
+	}
+	if u, err := r.FindUserByUsername(ctx, "nobody"); u != nil || err != nil {
+		t.Errorf("不存在的用户应返回 nil，得到 %+v %v", u, err)
+	}
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"encoding/json"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/query"
+)
+
+// sqlOps 比较运算符对应的 SQL 运算符
+var sqlOps = map[query.CompareOp]string{
+	query.OpEq: "=",
+	query.OpNe: "<>",
+	query.OpGt: ">",
+	query.OpGe: ">=",
+	query.OpLt: "<",
+	query.OpLe: "<=",
+}
+
+// compileQuery 把查询表达式编译为参数化的 WHERE 条件，arg 追加参数并返回占位符，值都作为参数绑定
+// 比较的结果为 NULL 时等同于 FALSE：取反前先用 COALESCE 转换，NOT 的结果与 query.Match 一致
+func (s *sqlStore) compileQuery(expr query.Expr, arg func(interface{}) string) string {
+	switch x := expr.(type) {
+	case *query.Binary:
+		return "(" + s.compileQuery(x.Left, arg) + " " + string(x.Op) + " " + s.compileQuery(x.Right, arg) + ")"
+	case *query.Not:
+		return "NOT COALESCE(" + s.compileQuery(x.X, arg) + ", FALSE)"
+	case *query.Compare:
+		return s.compileCompare(x, arg)
+	}
+	return "TRUE"
+}
+
+func (s *sqlStore) compileCompare(c *query.Compare, arg func(interface{}) string) string {
+	switch c.Op {
+	case query.OpIn:
+		return s.compileIn(c, arg)
+	case query.OpWithin:
+		return s.compileWithin(c, arg)
+	}
+
+	switch c.Field.Type {
+	case query.FieldInt:
+		// 直接比较列以便使用索引，应用写入的数值列不为 NULL
+		return c.Field.Name + " " + sqlOps[c.Op] + " " + arg(c.Value)
+	case query.FieldTime:
+		return "timestamp " + sqlOps[c.Op] + " " + arg(s.dialect.timeArg(c.Value.(time.Time)))
+	case query.FieldString:
+		return s.compileText("COALESCE("+c.Field.Name+", '')", c, arg)
+	case query.FieldHeader:
+		if cond, ok := s.compileContains(c, arg); ok {
+			return cond
+		}
+		var header string
+		if s.dialect.name == "postgres" {
+			header = c.Field.Name + " ->> CAST(" + arg(c.Field.Path[0]) + " AS TEXT)"
+		} else {
+			header = "json_extract(CAST(" + c.Field.Name + " AS TEXT), " + arg(sqliteJSONPath(c.Field.Path)) + ")"
+		}
+		return s.compileText("COALESCE("+header+", '')", c, arg)
+	case query.FieldJSON:
+		if c.Op == query.OpNe {
+			eq := *c
+			eq.Op = query.OpEq
+			return "NOT COALESCE(" + s.compileJSON(&eq, arg) + ", FALSE)"
+		}
+		return s.compileJSON(c, arg)
+	}
+	return "FALSE"
+}
+
+// compileIn 编译多值条件，直接比较列以便使用 route、method 等列上的索引
+func (s *sqlStore) compileIn(c *query.Compare, arg func(interface{}) string) string {
+	var params []string
+	switch values := c.Value.(type) {
+	case []int64:
+		for _, v := range values {
+			params = append(params, arg(v))
+		}
+	case []string:
+		for _, v := range values {
+			params = append(params, arg(v))
+		}
+	default:
+		return "FALSE"
+	}
+	return c.Field.Name + " IN (" + strings.Join(params, ", ") + ")"
+}
+
+// compileWithin 编译网段条件，无法解析的地址不属于任何网段
+// Postgres 中 try_inet 把非法地址转换为 NULL 而不是报错，并且有对应的 GiST 表达式索引；
+// SQLite 使用 sqlite.go 中注册的 ip_within
+func (s *sqlStore) compileWithin(c *query.Compare, arg func(interface{}) string) string {
+	prefix := arg(c.Value.(netip.Prefix).String())
+	if s.dialect.name == "postgres" {
+		return "try_inet(" + c.Field.Name + ") <<= CAST(" + prefix + " AS TEXT)::cidr"
+	}
+	return "ip_within(" + c.Field.Name + ", " + prefix + ")"
+}
+
+func (s *sqlStore) compileText(col string, c *query.Compare, arg func(interface{}) string) string {
+	if c.Op == query.OpLike {
+		return col + " " + s.dialect.ilike + " " + arg(query.LikePattern(c.Value.(string))) + ` ESCAPE '\'`
+	}
+	return col + " " + sqlOps[c.Op] + " " + arg(c.Value)
+}
+
+// compileJSON 编译 Body 中 JSON 路径的比较，路径不存在或类型不符时为 FALSE
+func (s *sqlStore) compileJSON(c *query.Compare, arg func(interface{}) string) string {
+	if cond, ok := s.compileContains(c, arg); ok {
+		return cond
+	}
+	if s.dialect.name == "postgres" {
+		params := make([]string, len(c.Field.Path))
+		for i, seg := range c.Field.Path {
+			params[i] = arg(seg)
+		}
+		path := c.Field.Name + ", " + strings.Join(params, ", ")
+		value := "jsonb_extract_path(" + path + ")"
+		text := "jsonb_extract_path_text(" + path + ")"
+		switch c.Op {
+		case query.OpEq:
+			literal, _ := json.Marshal(c.Value)
+			return "COALESCE(" + value + " = CAST(" + arg(string(literal)) + " AS TEXT)::jsonb, FALSE)"
+		case query.OpLike:
+			return "COALESCE(jsonb_typeof(" + value + ") = 'string' AND " + text + " ILIKE " + arg(query.LikePattern(c.Value.(string))) + ` ESCAPE '\', FALSE)`
+		}
+		// CASE 保证只对数字做类型转换
+		return "COALESCE(CASE WHEN jsonb_typeof(" + value + ") = 'number' THEN CAST(" + text + " AS DOUBLE PRECISION) " +
+			sqlOps[c.Op] + " " + arg(c.Value) + " END, FALSE)"
+	}
+
+	// SQLite 的 JSON 以 BLOB 写入，先转换为文本以免被当作二进制 JSONB 解析
+	path := "CAST(" + c.Field.Name + " AS TEXT), " + arg(sqliteJSONPath(c.Field.Path))
+	value := "json_extract(" + path + ")"
+	typ := "json_type(" + path + ")"
+	var cond string
+	switch v := c.Value.(type) {
+	case nil:
+		cond = typ + " = 'null'"
+	case bool:
+		cond = typ + " = '" + strconv.FormatBool(v) + "'"
+	case string:
+		if c.Op == query.OpLike {
+			cond = typ + " = 'text' AND " + value + " LIKE " + arg(query.LikePattern(v)) + ` ESCAPE '\'`
+		} else {
+			cond = typ + " = 'text' AND " + value + " = " + arg(v)
+		}
+	default:
+		cond = typ + " IN ('integer', 'real') AND " + value + " " + sqlOps[c.Op] + " " + arg(v)
+	}
+	return "COALESCE(" + cond + ", FALSE)"
+}
+
+// compileContains 把 Postgres 中的等值比较改写为 jsonb 包含运算 col @> '{"a":{"b":v}}'，
+// 可以使用 jsonb_path_ops GIN 索引。路径含数组下标或 Header 比较空字符串时无法改写
+func (s *sqlStore) compileContains(c *query.Compare, arg func(interface{}) string) (string, bool) {
+	if s.dialect.name != "postgres" || c.Op != query.OpEq {
+		return "", false
+	}
+	if c.Field.Type == query.FieldHeader && c.Value == "" {
+		return "", false
+	}
+	doc := c.Value
+	for i := len(c.Field.Path) - 1; i >= 0; i-- {
+		if c.Field.Type == query.FieldJSON {
+			if _, err := strconv.Atoi(c.Field.Path[i]); err == nil {
+				return "", false
+			}
+		}
+		doc = map[string]interface{}{c.Field.Path[i]: doc}
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", false
+	}
+	return c.Field.Name + " @> CAST(" + arg(string(data)) + " AS TEXT)::jsonb", true
+}
+
+// sqliteJSONPath 把路径转换为 SQLite 的 JSON 路径，数字段作为数组下标
+func sqliteJSONPath(path []string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, seg := range path {
+		if _, err := strconv.Atoi(seg); err == nil && !strings.HasPrefix(seg, "-") {
+			b.WriteString("[" + seg + "]")
+			continue
+		}
+		b.WriteString(`."` + strings.ReplaceAll(seg, `"`, `\"`) + `"`)
+	}
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/query"
+)
+
+func TestCompileQueryPostgres(t *testing.T) {
+	expr, err := query.Parse("status>=500 AND path:/api/orders/* AND NOT req.body.user_id=42 AND req.body.items.0=1 AND req.header.x-tenant=acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &sqlStore{dialect: postgresDialect}
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return s.dialect.placeholder(len(args))
+	}
+
+	got := s.compileQuery(expr, arg)
+	// 等值比较改写为 @> 以使用 GIN 索引，数组下标无法改写时按路径取值比较
+	want := `((((status_code >= $1 AND COALESCE(url, '') ILIKE $2 ESCAPE '\') AND ` +
+		`NOT COALESCE(request_body @> CAST($3 AS TEXT)::jsonb, FALSE)) AND ` +
+		`COALESCE(jsonb_extract_path(request_body, $4, $5) = CAST($6 AS TEXT)::jsonb, FALSE)) AND ` +
+		`request_headers @> CAST($7 AS TEXT)::jsonb)`
+	if got != want {
+		t.Errorf("SQL:\n%s\n期望:\n%s", got, want)
+	}
+	wantArgs := []interface{}{int64(500), "/api/orders/%", `{"user_id":42}`, "items", "0", "1", `{"X-Tenant":"acme"}`}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("参数 %v，期望 %v", args, wantArgs)
+	}
+}
+
+func TestCompileFiltersPostgres(t *testing.T) {
+	expr, err := ports.LogSearchQuery{
+		LogFilter: ports.LogFilter{StatusClasses: []string{"5xx"}, Methods: []string{"get", "head"}, ClientIPs: []string{"10.1.2.3/8"}},
+		Exclude:   &ports.LogFilter{Services: []string{"healthcheck"}},
+	}.Expr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &sqlStore{dialect: postgresDialect}
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return s.dialect.placeholder(len(args))
+	}
+
+	got := s.compileQuery(expr, arg)
+	want := `((((status_code >= $1 AND status_code <= $2) AND ` +
+		`method IN ($3, $4)) AND ` +
+		`try_inet(client_ip) <<= CAST($5 AS TEXT)::cidr) AND ` +
+		`NOT COALESCE(service IN ($6), FALSE))`
+	if got != want {
+		t.Errorf("SQL:\n%s\n期望:\n%s", got, want)
+	}
+	wantArgs := []interface{}{int64(500), int64(599), "GET", "HEAD", "10.0.0.0/8", "healthcheck"}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("参数 %v，期望 %v", args, wantArgs)
+	}
+}
+
+func TestSQLiteJSONPath(t *testing.T) {
+	if got := sqliteJSONPath([]string{"items", "0", `a"b`}); got != `$."items"[0]."a\"b"` {
+		t.Errorf("得到 %s", got)
+	}
+}
+
+func TestTextQuery(t *testing.T) {
+	terms, err := query.ParseText(`"card declined" issu* 42`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := (&sqlStore{dialect: postgresDialect}).textQuery(terms); got != `'card' <-> 'declined' & 'issu':* & '42'` {
+		t.Errorf("tsquery: %s", got)
+	}
+	if got := (&sqlStore{dialect: sqliteDialect}).textQuery(terms); got != `"card declined" AND "issu" * AND "42"` {
+		t.Errorf("FTS5: %s", got)
+	}
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+)
+
+// Stats 在数据库中分组聚合：先按排序字段取前 query.Size 个分组的总体统计，再统计这些分组在各时间段的值
+func (s *sqlStore) Stats(ctx context.Context, query ports.StatsQuery) ([]ports.StatsGroup, error) {
+	if err := query.Normalize(time.Now()); err != nil {
+		return nil, err
+	}
+	width, err := query.BucketWidth()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return s.dialect.placeholder(len(args))
+	}
+	conditions, _, err := s.searchConditions(query.LogSearchQuery, arg)
+	if err != nil {
+		return nil, err
+	}
+	conditions = append(conditions, "COALESCE(status_code, 0) <> 0")
+	key := s.statsKeyExpr(query.GroupBy)
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+key+" AS stats_key, "+s.statsColumns()+" FROM logs"+where+
+		" GROUP BY stats_key ORDER BY "+query.OrderBy+" DESC, stats_key LIMIT "+arg(query.Size), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	groups := []ports.StatsGroup{}
+	index := map[string]int{}
+	for rows.Next() {
+		g := ports.StatsGroup{Buckets: []ports.StatsBucket{}}
+		if err := rows.Scan(append([]interface{}{&g.Key}, statsDest(&g.StatsSummary)...)...); err != nil {
+			return nil, err
+		}
+		index[g.Key] = len(groups)
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return groups, nil
+	}
+
+	// 第二次查询复用筛选条件的参数，只统计第一次查询返回的分组
+	args = args[:len(args)-1]
+	keys := make([]string, len(groups))
+	for i, g := range groups {
+		keys[i] = arg(g.Key)
+	}
+	bucket := s.statsBucketExpr(arg(int64(width / time.Second)))
+	rows, err = s.db.QueryContext(ctx, "SELECT "+key+" AS stats_key, "+bucket+" AS stats_bucket, "+s.statsColumns()+
+		" FROM logs"+where+" AND "+key+" IN ("+strings.Join(keys, ", ")+")"+
+		" GROUP BY stats_key, stats_bucket ORDER BY stats_bucket", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			k   string
+			sec int64
+			b   ports.StatsBucket
+		)
+		if err := rows.Scan(append([]interface{}{&k, &sec}, statsDest(&b.StatsSummary)...)...); err != nil {
+			return nil, err
+		}
+		b.Time = time.Unix(sec, 0).UTC()
+		g := &groups[index[k]]
+		g.Buckets = append(g.Buckets, b)
+	}
+	return groups, rows.Err()
+}
+
+// statsColumns 返回统计值的聚合表达式，列名与 ports 中的排序字段一致
+func (s *sqlStore) statsColumns() string {
+	return `COUNT(*) AS count,
+            SUM(CASE WHEN status_code >= 500 THEN 1 ELSE 0 END) AS errors,
+            CAST(SUM(CASE WHEN status_code >= 500 THEN 1 ELSE 0 END) AS DOUBLE PRECISION) / COUNT(*) AS error_rate,
+            ` + s.percentile("0.5") + ` AS p50,
+            ` + s.percentile("0.9") + ` AS p90,
+            ` + s.percentile("0.99") + ` AS p99,
+            CAST(AVG(COALESCE(response_size, 0)) AS DOUBLE PRECISION) AS avg_response_size`
+}
+
+// statsDest 返回与 statsColumns 顺序一致的扫描目标
+func statsDest(s *ports.StatsSummary) []interface{} {
+	return []interface{}{&s.Count, &s.Errors, &s.ErrorRate, &s.P50, &s.P90, &s.P99, &s.AvgResponseSize}
+}
+
+// percentile 返回耗时的 p 分位数，SQLite 使用注册的 percentile_linear 聚合函数，结果与 percentile_cont 一致
+func (s *sqlStore) percentile(p string) string {
+	if s.dialect.name == postgresDialect.name {
+		return "percentile_cont(" + p + ") WITHIN GROUP (ORDER BY COALESCE(duration_ms, 0))"
+	}
+	return "percentile_linear(COALESCE(duration_ms, 0), " + p + ")"
+}
+
+// statsKeyExpr 返回 group 维度的分组键表达式，与内存实现的 statsKey 一致
+func (s *sqlStore) statsKeyExpr(group string) string {
+	switch group {
+	case ports.GroupByRoute:
+		// 旧数据没有路由模板，按不含查询参数的路径分组
+		if s.dialect.name == postgresDialect.name {
+			return "COALESCE(NULLIF(route, ''), split_part(COALESCE(url, ''), '?', 1))"
+		}
+		return "COALESCE(NULLIF(route, ''), CASE WHEN instr(url, '?') > 0 THEN substr(url, 1, instr(url, '?') - 1) ELSE COALESCE(url, '') END)"
+	case ports.GroupByMethod:
+		return "COALESCE(method, '')"
+	case ports.GroupByService:
+		return "COALESCE(service, '')"
+	case ports.GroupByStatusClass:
+		return "CAST(status_code / 100 AS TEXT) || 'xx'"
+	}
+	return "''"
+}
+
+// statsBucketExpr 返回时间段起点的 Unix 秒，width 为时间段宽度 (秒) 的占位符
+func (s *sqlStore) statsBucketExpr(width string) string {
+	if s.dialect.name == postgresDialect.name {
+		return "CAST(floor(EXTRACT(EPOCH FROM timestamp) / CAST(" + width + " AS BIGINT)) AS BIGINT) * CAST(" + width + " AS BIGINT)"
+	}
+	// SQLite 的时间为 Unix 纳秒
+	return "timestamp / 1000000000 / " + width + " * " + width
+}
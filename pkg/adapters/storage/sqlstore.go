@@ -0,0 +1,613 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+	"github.com/MCCodingMan/TraceBuddy/pkg/utils"
+)
+
+// logColumns 是 logs 表读写时统一使用的列顺序
+const logColumns = `id, track_id, timestamp, duration_ms, method, url, status_code,
+            client_ip, service, environment, level, message,
+            request_headers, request_query_params, request_body,
+            response_headers, response_body, response_size,
+            request_size, request_body_truncated, response_body_truncated,
+            trace_id, span_id, parent_span_id, kind, route, handler`
+
+// sqlDialect 屏蔽 Postgres 与 SQLite 在占位符、模糊匹配和时间存储上的差异
+type sqlDialect struct {
+	name        string // 对应 migrations/ 下的目录
+	placeholder func(n int) string
+	ilike       string                        // 大小写不敏感的模糊匹配运算符
+	timeArg     func(t time.Time) interface{} // 时间参数的写入形式
+	logKey      string                        // logs 表的唯一键，用作 ON CONFLICT 目标
+}
+
+var postgresDialect = sqlDialect{
+	name:        "postgres",
+	placeholder: func(n int) string { return fmt.Sprintf("$%d", n) },
+	ilike:       "ILIKE",
+	timeArg:     func(t time.Time) interface{} { return t },
+	// 分区表的主键必须包含分区键；重放的日志 id 和时间都相同，仍然是幂等的
+	logKey: "(id, timestamp)",
+}
+
+// SQLite 以 Unix 纳秒整数保存时间，保证范围查询和排序正确
+var sqliteDialect = sqlDialect{
+	name:        "sqlite",
+	placeholder: func(n int) string { return fmt.Sprintf("?%d", n) },
+	ilike:       "LIKE",
+	timeArg:     func(t time.Time) interface{} { return t.UnixNano() },
+	logKey:      "(id)",
+}
+
+// sqlStore 是 Postgres 与 SQLite 共用的日志和用户读写实现，各仓库只负责建连，表结构由迁移管理
+type sqlStore struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+// Close 关闭数据库连接
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// logUpsertSet 是幂等写入的更新部分，冲突键由方言决定，重放同一条日志时覆盖旧值
+const logUpsertSet = ` DO UPDATE SET
+            track_id = EXCLUDED.track_id,
+            timestamp = EXCLUDED.timestamp,
+            duration_ms = EXCLUDED.duration_ms,
+            method = EXCLUDED.method,
+            url = EXCLUDED.url,
+            status_code = EXCLUDED.status_code,
+            client_ip = EXCLUDED.client_ip,
+            service = EXCLUDED.service,
+            environment = EXCLUDED.environment,
+            level = EXCLUDED.level,
+            message = EXCLUDED.message,
+            request_headers = EXCLUDED.request_headers,
+            request_query_params = EXCLUDED.request_query_params,
+            request_body = EXCLUDED.request_body,
+            response_headers = EXCLUDED.response_headers,
+            response_body = EXCLUDED.response_body,
+            response_size = EXCLUDED.response_size,
+            request_size = EXCLUDED.request_size,
+            request_body_truncated = EXCLUDED.request_body_truncated,
+            response_body_truncated = EXCLUDED.response_body_truncated,
+            trace_id = EXCLUDED.trace_id,
+            span_id = EXCLUDED.span_id,
+            parent_span_id = EXCLUDED.parent_span_id,
+            kind = EXCLUDED.kind,
+            route = EXCLUDED.route,
+            handler = EXCLUDED.handler`
+
+func (s *sqlStore) upsertClause() string {
+	return "\n        ON CONFLICT " + s.dialect.logKey + logUpsertSet
+}
+
+// maxRowsPerInsert 单条多行 INSERT 的最大行数，避免超过 Postgres 65535 个、SQLite 32766 个参数的上限
+const maxRowsPerInsert = 1000
+
+func (s *sqlStore) Save(ctx context.Context, entry domain.LogEntry) error {
+	args := s.logEntryArgs(normalizeEntry(entry))
+	_, err := s.db.ExecContext(ctx, "INSERT INTO logs ("+logColumns+") VALUES "+s.valuesPlaceholders(1, len(args))+s.upsertClause(), args...)
+	return err
+}
+
+// SaveBatch 在一个事务内以多行 INSERT 写入一批日志
+func (s *sqlStore) SaveBatch(ctx context.Context, entries []domain.LogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	// 同一条 INSERT 中重复的 id 会触发 "cannot affect row a second time"，保留最后一次
+	unique := make([]domain.LogEntry, 0, len(entries))
+	seen := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		entry = normalizeEntry(entry)
+		if i, ok := seen[entry.ID]; ok {
+			unique[i] = entry
+			continue
+		}
+		seen[entry.ID] = len(unique)
+		unique = append(unique, entry)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(unique); start += maxRowsPerInsert {
+		end := start + maxRowsPerInsert
+		if end > len(unique) {
+			end = len(unique)
+		}
+		var (
+			values []string
+			args   []interface{}
+		)
+		for _, entry := range unique[start:end] {
+			row := s.logEntryArgs(entry)
+			values = append(values, s.valuesPlaceholders(len(args)+1, len(row)))
+			args = append(args, row...)
+		}
+		stmt := "INSERT INTO logs (" + logColumns + ") VALUES " + strings.Join(values, ", ") + s.upsertClause()
+		if _, err := tx.ExecContext(ctx, stmt, args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// normalizeEntry 补齐写入前必需的字段
+func normalizeEntry(entry domain.LogEntry) domain.LogEntry {
+	if entry.ID == "" {
+		entry.ID = utils.GenerateEntryID()
+	}
+	if entry.Kind == "" {
+		entry.Kind = domain.KindServer
+	}
+	return entry
+}
+
+// valuesPlaceholders 生成 "($from, ..., $from+n-1)"
+func (s *sqlStore) valuesPlaceholders(from, n int) string {
+	var b strings.Builder
+	b.WriteString("(")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(s.dialect.placeholder(from + i))
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// logEntryArgs 按 logColumns 的顺序展开日志条目的写入参数
+func (s *sqlStore) logEntryArgs(entry domain.LogEntry) []interface{} {
+	reqHeaders, _ := json.Marshal(entry.Request.Headers)
+	reqQuery, _ := json.Marshal(entry.Request.QueryParams)
+	reqBody, _ := json.Marshal(entry.Request.Body)
+	respHeaders, _ := json.Marshal(entry.Response.Headers)
+	respBody, _ := json.Marshal(entry.Response.Body)
+
+	return []interface{}{
+		entry.ID,
+		entry.TrackID,
+		s.dialect.timeArg(entry.Timestamp),
+		entry.DurationMs,
+		entry.Request.Method,
+		entry.Request.URL,
+		entry.Response.StatusCode,
+		entry.ClientIP,
+		entry.Service,
+		entry.Environment,
+		entry.Level,
+		entry.Message,
+		reqHeaders,
+		reqQuery,
+		reqBody,
+		respHeaders,
+		respBody,
+		entry.Response.Size,
+		entry.Request.Size,
+		entry.Request.BodyTruncated,
+		entry.Response.BodyTruncated,
+		entry.TraceID,
+		entry.SpanID,
+		entry.ParentSpanID,
+		entry.Kind,
+		entry.Route,
+		entry.Handler,
+	}
+}
+
+// rowScanner 同时适配 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// timeColumn 读取时间列，兼容 Postgres 的 TIMESTAMPTZ 和 SQLite 的 Unix 纳秒整数
+type timeColumn struct {
+	t *time.Time
+}
+
+func (c timeColumn) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case time.Time:
+		*c.t = v
+	case int64:
+		*c.t = time.Unix(0, v).UTC()
+	case nil:
+		*c.t = time.Time{}
+	default:
+		return fmt.Errorf("unsupported time column type %T", src)
+	}
+	return nil
+}
+
+// scanLogEntry 按 logColumns 的顺序扫描一行日志，extra 接收 logColumns 之后的附加列
+func scanLogEntry(row rowScanner, extra ...interface{}) (domain.LogEntry, error) {
+	var (
+		entry       domain.LogEntry
+		reqHeaders  []byte
+		reqQuery    []byte
+		reqBody     []byte
+		respHeaders []byte
+		respBody    []byte
+	)
+
+	dest := []interface{}{
+		&entry.ID,
+		&entry.TrackID,
+		timeColumn{&entry.Timestamp},
+		&entry.DurationMs,
+		&entry.Request.Method,
+		&entry.Request.URL,
+		&entry.Response.StatusCode,
+		&entry.ClientIP,
+		&entry.Service,
+		&entry.Environment,
+		&entry.Level,
+		&entry.Message,
+		&reqHeaders,
+		&reqQuery,
+		&reqBody,
+		&respHeaders,
+		&respBody,
+		&entry.Response.Size,
+		&entry.Request.Size,
+		&entry.Request.BodyTruncated,
+		&entry.Response.BodyTruncated,
+		&entry.TraceID,
+		&entry.SpanID,
+		&entry.ParentSpanID,
+		&entry.Kind,
+		&entry.Route,
+		&entry.Handler,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return entry, err
+	}
+
+	if len(reqHeaders) > 0 {
+		_ = json.Unmarshal(reqHeaders, &entry.Request.Headers)
+	}
+	if len(reqQuery) > 0 {
+		_ = json.Unmarshal(reqQuery, &entry.Request.QueryParams)
+	}
+	if len(reqBody) > 0 {
+		var body interface{}
+		_ = json.Unmarshal(reqBody, &body)
+		entry.Request.Body = body
+	}
+	if len(respHeaders) > 0 {
+		_ = json.Unmarshal(respHeaders, &entry.Response.Headers)
+	}
+	if len(respBody) > 0 {
+		var body interface{}
+		_ = json.Unmarshal(respBody, &body)
+		entry.Response.Body = body
+	}
+	return entry, nil
+}
+
+// scanLogEntries 扫描所有结果行
+func scanLogEntries(rows *sql.Rows) ([]domain.LogEntry, error) {
+	defer rows.Close()
+	entries := []domain.LogEntry{}
+	for rows.Next() {
+		entry, err := scanLogEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (s *sqlStore) FindByID(ctx context.Context, id string) (*domain.LogEntry, error) {
+	p := s.dialect.placeholder(1)
+	row := s.db.QueryRowContext(ctx, `
+        SELECT `+logColumns+`
+        FROM logs WHERE id = `+p+` OR track_id = `+p+`
+        ORDER BY (id = `+p+`) DESC, timestamp DESC
+        LIMIT 1
+    `, id)
+
+	entry, err := scanLogEntry(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (s *sqlStore) FindByTrace(ctx context.Context, traceID string) ([]domain.LogEntry, error) {
+	trackID, w3cTraceID := traceKeys(traceID)
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT `+logColumns+`
+        FROM logs WHERE track_id = `+s.dialect.placeholder(1)+` OR trace_id = `+s.dialect.placeholder(2)+`
+        ORDER BY timestamp ASC, id ASC
+    `, trackID, w3cTraceID)
+	if err != nil {
+		return nil, err
+	}
+	return scanLogEntries(rows)
+}
+
+func (s *sqlStore) Search(ctx context.Context, query ports.LogSearchQuery) ([]domain.LogEntry, int64, error) {
+	args := []interface{}{}
+	// arg 追加一个参数并返回其占位符
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return s.dialect.placeholder(len(args))
+	}
+	conditions, text, err := s.searchConditions(query, arg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	order := " ORDER BY timestamp DESC, id DESC"
+	switch query.SortOrder() {
+	case ports.SortTime:
+	case ports.SortRelevance:
+		if query.Cursor != "" {
+			return nil, 0, ports.ErrCursorNeedsTimeSort
+		}
+		if text != "" {
+			order = " ORDER BY " + s.textRank(text) + " DESC, timestamp DESC, id DESC"
+		}
+	default:
+		return nil, 0, fmt.Errorf("unknown sort %q", query.Sort)
+	}
+
+	var cursor *ports.SearchCursor
+	if query.Cursor != "" {
+		c, err := ports.DecodeCursor(query.Cursor)
+		if err != nil {
+			return nil, 0, err
+		}
+		cursor = &c
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	total, err := s.count(ctx, query.Count, where, args)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	page := query.Page
+	size := query.Size
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = 10
+	}
+
+	// 键集分页直接定位到游标之后，不需要扫描并跳过前面的行
+	pagination := " LIMIT " + arg(size)
+	if cursor != nil {
+		conditions = append(conditions, "(timestamp, id) < ("+arg(s.dialect.timeArg(cursor.Timestamp))+", "+arg(cursor.ID)+")")
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	} else {
+		pagination += " OFFSET " + arg((page-1)*size)
+	}
+
+	if text == "" {
+		rows, err := s.db.QueryContext(ctx, "SELECT "+logColumns+" FROM logs "+where+order+pagination, args...)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries, err := scanLogEntries(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		return entries, total, nil
+	}
+
+	// 全文搜索额外返回匹配摘要
+	rows, err := s.db.QueryContext(ctx, "SELECT "+logColumns+", "+s.textHighlight(text)+" FROM logs "+where+order+pagination, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	entries := []domain.LogEntry{}
+	for rows.Next() {
+		var highlight sql.NullString
+		entry, err := scanLogEntry(rows, &highlight)
+		if err != nil {
+			return nil, 0, err
+		}
+		entry.Highlight = highlight.String
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// searchConditions 把搜索条件编译为 WHERE 子句的条件，arg 追加参数并返回占位符
+// 设置了全文搜索时 text 为搜索词的占位符，用于排序和摘要
+func (s *sqlStore) searchConditions(query ports.LogSearchQuery, arg func(interface{}) string) (conditions []string, text string, err error) {
+	start, end, err := query.TimeRange(time.Now())
+	if err != nil {
+		return nil, "", err
+	}
+	if !start.IsZero() {
+		conditions = append(conditions, "timestamp >= "+arg(s.dialect.timeArg(start)))
+	}
+	if !end.IsZero() {
+		conditions = append(conditions, "timestamp <= "+arg(s.dialect.timeArg(end)))
+	}
+	if query.Method != "" {
+		conditions = append(conditions, "method = "+arg(query.Method))
+	}
+	if query.Status != 0 {
+		conditions = append(conditions, "status_code = "+arg(query.Status))
+	}
+	if query.Path != "" {
+		conditions = append(conditions, "url "+s.dialect.ilike+" "+arg("%"+query.Path+"%"))
+	}
+	if query.Level != "" {
+		conditions = append(conditions, "level = "+arg(query.Level))
+	}
+	if query.Keyword != "" {
+		kw := arg("%" + query.Keyword + "%")
+		conditions = append(conditions, fmt.Sprintf("(message %[1]s %[2]s OR url %[1]s %[2]s OR track_id %[1]s %[2]s)", s.dialect.ilike, kw))
+	}
+	expr, err := query.Expr()
+	if err != nil {
+		return nil, "", err
+	}
+	if expr != nil {
+		conditions = append(conditions, s.compileQuery(expr, arg))
+	}
+	terms, err := query.TextTerms()
+	if err != nil {
+		return nil, "", err
+	}
+	if len(terms) > 0 {
+		text = arg(s.textQuery(terms))
+		conditions = append(conditions, s.textMatch(text))
+	}
+	return conditions, text, nil
+}
+
+// count 按统计方式返回满足条件的总数，CountNone 时返回 -1
+// Postgres 的估算取自查询计划的行数，SQLite 没有廉价的估算方式，返回精确值
+func (s *sqlStore) count(ctx context.Context, mode, where string, args []interface{}) (int64, error) {
+	switch mode {
+	case "", ports.CountExact:
+	case ports.CountNone:
+		return -1, nil
+	case ports.CountEstimate:
+		if s.dialect.name == postgresDialect.name {
+			return s.estimate(ctx, "SELECT 1 FROM logs "+where, args)
+		}
+	default:
+		return 0, fmt.Errorf("unknown count mode %q", mode)
+	}
+	var total int64
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM logs "+where, args...).Scan(&total)
+	return total, err
+}
+
+// estimate 读取 Postgres 查询计划估算的行数
+func (s *sqlStore) estimate(ctx context.Context, query string, args []interface{}) (int64, error) {
+	var plan []byte
+	if err := s.db.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&plan); err != nil {
+		return 0, err
+	}
+	var explain []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &explain); err != nil {
+		return 0, fmt.Errorf("parse query plan: %w", err)
+	}
+	if len(explain) == 0 {
+		return 0, errors.New("empty query plan")
+	}
+	return int64(explain[0].Plan.Rows), nil
+}
+
+// retentionWhere 构造第 rule 条规则负责清理的条件：匹配该规则、不匹配之前的任何规则且早于过期时间
+// 规则永久保留时返回 false。旧版本的表中部分列可以为 NULL，比较前统一转换为空值
+func (s *sqlStore) retentionWhere(policy domain.RetentionPolicy, rule int, now time.Time) (string, []interface{}, bool) {
+	cutoff := policy[rule].Cutoff(now)
+	if cutoff.IsZero() {
+		return "", nil, false
+	}
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return s.dialect.placeholder(len(args))
+	}
+	match := func(r domain.RetentionRule) string {
+		conditions := []string{}
+		if r.Service != "" {
+			conditions = append(conditions, "COALESCE(service, '') = "+arg(r.Service))
+		}
+		if r.Environment != "" {
+			conditions = append(conditions, "COALESCE(environment, '') = "+arg(r.Environment))
+		}
+		if r.Level != "" {
+			conditions = append(conditions, "COALESCE(level, '') = "+arg(r.Level))
+		}
+		if r.StatusMin != 0 {
+			conditions = append(conditions, "COALESCE(status_code, 0) >= "+arg(r.StatusMin))
+		}
+		if r.StatusMax != 0 {
+			conditions = append(conditions, "COALESCE(status_code, 0) <= "+arg(r.StatusMax))
+		}
+		if len(conditions) == 0 {
+			return "1 = 1"
+		}
+		return strings.Join(conditions, " AND ")
+	}
+
+	conditions := []string{"timestamp < " + arg(s.dialect.timeArg(cutoff)), match(policy[rule])}
+	for _, earlier := range policy[:rule] {
+		conditions = append(conditions, "NOT ("+match(earlier)+")")
+	}
+	return strings.Join(conditions, " AND "), args, true
+}
+
+func (s *sqlStore) CountExpired(ctx context.Context, policy domain.RetentionPolicy, rule int, now time.Time) (int64, error) {
+	where, args, ok := s.retentionWhere(policy, rule, now)
+	if !ok {
+		return 0, nil
+	}
+	var count int64
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM logs WHERE "+where, args...).Scan(&count)
+	return count, err
+}
+
+// DeleteExpired 每次最多删除 limit 条，避免长事务和长时间持有行锁
+func (s *sqlStore) DeleteExpired(ctx context.Context, policy domain.RetentionPolicy, rule int, now time.Time, limit int) (int64, error) {
+	where, args, ok := s.retentionWhere(policy, rule, now)
+	if !ok || limit <= 0 {
+		return 0, nil
+	}
+	// 分区表的 ctid 在分区间不唯一，按主键 (id, timestamp) 定位要删除的行
+	stmt := "DELETE FROM logs WHERE (id, timestamp) IN (SELECT id, timestamp FROM logs WHERE " + where +
+		" LIMIT " + s.dialect.placeholder(len(args)+1) + ")"
+	res, err := s.db.ExecContext(ctx, stmt, append(args, limit)...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *sqlStore) FindUserByUsername(ctx context.Context, username string) (*domain.User, error) {
+	row := s.db.QueryRowContext(ctx, `
+        SELECT username, password_hash, role, created_at, updated_at FROM users WHERE username = `+s.dialect.placeholder(1), username)
+	var u domain.User
+	err := row.Scan(&u.Username, &u.PasswordHash, &u.Role, timeColumn{&u.CreatedAt}, timeColumn{&u.UpdatedAt})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"strings"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/query"
+)
+
+// textQuery 把全文搜索条件转换为 tsquery (Postgres) 或 FTS5 MATCH (SQLite) 表达式，作为参数绑定
+// 词只包含字母和数字，不需要转义
+func (s *sqlStore) textQuery(terms []query.TextTerm) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		if s.dialect.name == "postgres" {
+			words := make([]string, len(t.Words))
+			for j, w := range t.Words {
+				words[j] = "'" + w + "'"
+			}
+			parts[i] = strings.Join(words, " <-> ")
+			if t.Prefix {
+				parts[i] += ":*"
+			}
+			continue
+		}
+		parts[i] = `"` + strings.Join(t.Words, " ") + `"`
+		if t.Prefix {
+			parts[i] += " *"
+		}
+	}
+	if s.dialect.name == "postgres" {
+		return strings.Join(parts, " & ")
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// textMatch 返回全文匹配的条件，p 为 textQuery 结果的占位符
+func (s *sqlStore) textMatch(p string) string {
+	if s.dialect.name == "postgres" {
+		return "search_vector @@ to_tsquery('simple', " + p + ")"
+	}
+	return "logs.rowid IN (SELECT rowid FROM logs_fts WHERE logs_fts MATCH " + p + ")"
+}
+
+// textRank 返回相关度表达式，值越大越相关
+func (s *sqlStore) textRank(p string) string {
+	if s.dialect.name == "postgres" {
+		return "ts_rank_cd(search_vector, to_tsquery('simple', " + p + "))"
+	}
+	// bm25 越小越相关，列权重与 Postgres 的 A、B、C 对应
+	return "-(SELECT bm25(logs_fts, 4.0, 2.0, 1.0) FROM logs_fts WHERE logs_fts MATCH " + p + " AND rowid = logs.rowid)"
+}
+
+// textHighlight 返回匹配摘要的表达式，匹配的词用 <mark></mark> 包围
+func (s *sqlStore) textHighlight(p string) string {
+	if s.dialect.name == "postgres" {
+		return "ts_headline('simple', concat_ws(' ', message, url, request_body::text, response_body::text), to_tsquery('simple', " + p + "), " +
+			"'StartSel=\"" + query.HighlightStart + "\", StopSel=\"" + query.HighlightStop + "\", MaxFragments=2, MinWords=5, MaxWords=20')"
+	}
+	return "(SELECT snippet(logs_fts, -1, '" + query.HighlightStart + "', '" + query.HighlightStop + "', '…', 16) " +
+		"FROM logs_fts WHERE logs_fts MATCH " + p + " AND rowid = logs.rowid)"
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+)
+
+// statsKey 返回条目在 group 维度下的分组键，与 SQL 实现的 statsKeyExpr 一致
+func statsKey(e domain.LogEntry, group string) string {
+	switch group {
+	case ports.GroupByRoute:
+		if e.Route != "" {
+			return e.Route
+		}
+		path, _, _ := strings.Cut(e.Request.URL, "?")
+		return path
+	case ports.GroupByMethod:
+		return e.Request.Method
+	case ports.GroupByService:
+		return e.Service
+	case ports.GroupByStatusClass:
+		return fmt.Sprintf("%dxx", e.Response.StatusCode/100)
+	}
+	return ""
+}
+
+// statsAccumulator 累积一组请求的统计值
+type statsAccumulator struct {
+	count, errors, responseSize int64
+	durations                   []float64
+}
+
+func (a *statsAccumulator) add(e domain.LogEntry) {
+	a.count++
+	if e.Response.StatusCode >= 500 {
+		a.errors++
+	}
+	a.responseSize += e.Response.Size
+	a.durations = append(a.durations, float64(e.DurationMs))
+}
+
+func (a *statsAccumulator) summary() ports.StatsSummary {
+	sort.Float64s(a.durations)
+	return ports.StatsSummary{
+		Count:           a.count,
+		Errors:          a.errors,
+		ErrorRate:       float64(a.errors) / float64(a.count),
+		P50:             percentile(a.durations, 0.5),
+		P90:             percentile(a.durations, 0.9),
+		P99:             percentile(a.durations, 0.99),
+		AvgResponseSize: float64(a.responseSize) / float64(a.count),
+	}
+}
+
+// percentile 返回升序数据的 p 分位数，在相邻两个值之间线性插值，与 Postgres 的 percentile_cont 一致
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := p * float64(len(sorted)-1)
+	lower := math.Floor(pos)
+	i := int(lower)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (pos-lower)*(sorted[i+1]-sorted[i])
+}
+
+// statsOrderValue 返回分组按 order 排序时比较的值
+func statsOrderValue(s ports.StatsSummary, order string) float64 {
+	switch order {
+	case ports.StatsOrderErrorRate:
+		return s.ErrorRate
+	case ports.StatsOrderP50:
+		return s.P50
+	case ports.StatsOrderP90:
+		return s.P90
+	case ports.StatsOrderP99:
+		return s.P99
+	case ports.StatsOrderAvgResponseSize:
+		return s.AvgResponseSize
+	}
+	return float64(s.Count)
+}
+
+// statsEntries 在内存中统计已过滤的条目，内存存储和文件存储共用
+func statsEntries(entries []domain.LogEntry, query ports.StatsQuery) ([]ports.StatsGroup, error) {
+	width, err := query.BucketWidth()
+	if err != nil {
+		return nil, err
+	}
+	type group struct {
+		total   statsAccumulator
+		buckets map[int64]*statsAccumulator
+	}
+	groups := map[string]*group{}
+	// 时间段的起点按 Unix 秒向下取整，与 SQL 实现一致
+	sec := int64(width / time.Second)
+	for _, e := range entries {
+		if e.Response.StatusCode == 0 {
+			continue
+		}
+		key := statsKey(e, query.GroupBy)
+		g := groups[key]
+		if g == nil {
+			g = &group{buckets: map[int64]*statsAccumulator{}}
+			groups[key] = g
+		}
+		g.total.add(e)
+		bucket := e.Timestamp.Unix() / sec * sec
+		if g.buckets[bucket] == nil {
+			g.buckets[bucket] = &statsAccumulator{}
+		}
+		g.buckets[bucket].add(e)
+	}
+
+	result := make([]ports.StatsGroup, 0, len(groups))
+	for key, g := range groups {
+		sg := ports.StatsGroup{Key: key, StatsSummary: g.total.summary(), Buckets: []ports.StatsBucket{}}
+		for bucket, acc := range g.buckets {
+			sg.Buckets = append(sg.Buckets, ports.StatsBucket{Time: time.Unix(bucket, 0).UTC(), StatsSummary: acc.summary()})
+		}
+		sort.Slice(sg.Buckets, func(i, j int) bool { return sg.Buckets[i].Time.Before(sg.Buckets[j].Time) })
+		result = append(result, sg)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := statsOrderValue(result[i].StatsSummary, query.OrderBy), statsOrderValue(result[j].StatsSummary, query.OrderBy)
+		if a != b {
+			return a > b
+		}
+		return result[i].Key < result[j].Key
+	})
+	if len(result) > query.Size {
+		result = result[:query.Size]
+	}
+	return result, nil
+}
@@ -0,0 +1,780 @@
+// Package storagetest 提供 ports.LogRepository 的一致性测试，
+// 任何日志存储实现都可以在自己的测试中调用 RunLogRepositoryTests 验证行为与其他实现一致
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/ports"
+)
+
+// Factory 为每个子测试返回一个空的存储，需要时通过 t.Cleanup 释放资源
+type Factory func(t *testing.T) ports.LogRepository
+
+// base 测试数据的基准时间，取整到秒以兼容各存储的时间精度
+var base = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// RunLogRepositoryTests 运行全部一致性测试
+func RunLogRepositoryTests(t *testing.T, newRepo Factory) {
+	t.Run("SaveAndFindByID", func(t *testing.T) { testSaveAndFindByID(t, newRepo(t)) })
+	t.Run("SaveIsIdempotent", func(t *testing.T) { testSaveIsIdempotent(t, newRepo(t)) })
+	t.Run("SaveBatch", func(t *testing.T) { testSaveBatch(t, newRepo(t)) })
+	t.Run("FindByIDFallsBackToTrackID", func(t *testing.T) { testFindByIDFallsBackToTrackID(t, newRepo(t)) })
+	t.Run("FindByTrace", func(t *testing.T) { testFindByTrace(t, newRepo(t)) })
+	t.Run("SearchFilters", func(t *testing.T) { testSearchFilters(t, newRepo(t)) })
+	t.Run("SearchInvalidTime", func(t *testing.T) { testSearchInvalidTime(t, newRepo(t)) })
+	t.Run("SearchPaging", func(t *testing.T) { testSearchPaging(t, newRepo(t)) })
+	t.Run("SearchCursor", func(t *testing.T) { testSearchCursor(t, newRepo(t)) })
+	t.Run("SearchQuery", func(t *testing.T) { testSearchQuery(t, newRepo(t)) })
+	t.Run("SearchJSON", func(t *testing.T) { testSearchJSON(t, newRepo(t)) })
+	t.Run("SearchText", func(t *testing.T) { testSearchText(t, newRepo(t)) })
+	t.Run("SearchFilterLists", func(t *testing.T) { testSearchFilterLists(t, newRepo(t)) })
+	t.Run("SearchRoute", func(t *testing.T) { testSearchRoute(t, newRepo(t)) })
+	t.Run("PurgeExpired", func(t *testing.T) { testPurgeExpired(t, newRepo(t)) })
+	t.Run("Stats", func(t *testing.T) { testStats(t, newRepo(t)) })
+}
+
+func save(t *testing.T, repo ports.LogRepository, entries ...domain.LogEntry) {
+	t.Helper()
+	for _, e := range entries {
+		if err := repo.Save(context.Background(), e); err != nil {
+			t.Fatalf("Save(%s): %v", e.ID, err)
+		}
+	}
+}
+
+func ids(entries []domain.LogEntry) []string {
+	out := []string{}
+	for _, e := range entries {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func testSaveAndFindByID(t *testing.T, repo ports.LogRepository) {
+	want := domain.LogEntry{
+		ID:           "entry-1",
+		TrackID:      "4bf92f35-77b3-4da6-a3ce-929d0e0e4736",
+		TraceID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:       "00f067aa0ba902b7",
+		ParentSpanID: "b7ad6b7169203331",
+		Kind:         domain.KindClient,
+		Route:        "/api/orders",
+		Handler:      "main.createOrder",
+		Timestamp:    base,
+		DurationMs:   42,
+		ClientIP:     "10.0.0.1",
+		Service:      "orders",
+		Environment:  "test",
+		Level:        "warn",
+		Message:      "slow response",
+		Request: domain.RequestInfo{
+			Method:        "POST",
+			URL:           "/api/orders?debug=1",
+			Headers:       map[string]string{"Content-Type": "application/json"},
+			QueryParams:   map[string]string{"debug": "1"},
+			Body:          map[string]interface{}{"sku": "A-1", "qty": float64(2)},
+			Size:          128,
+			BodyTruncated: true,
+		},
+		Response: domain.ResponseInfo{
+			StatusCode:    429,
+			Headers:       map[string]string{"Retry-After": "1"},
+			Body:          "slow down",
+			Size:          9,
+			BodyTruncated: false,
+		},
+	}
+	save(t, repo, want)
+
+	got, err := repo.FindByID(context.Background(), want.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("FindByID 未找到刚写入的条目")
+	}
+	if !got.Timestamp.Equal(want.Timestamp) {
+		t.Errorf("Timestamp = %v，期望 %v", got.Timestamp, want.Timestamp)
+	}
+	got.Timestamp = want.Timestamp
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("读回的条目不一致\n得到 %+v\n期望 %+v", *got, want)
+	}
+
+	missing, err := repo.FindByID(context.Background(), "missing")
+	if err != nil || missing != nil {
+		t.Errorf("不存在的 ID 应返回 nil, nil，得到 %+v, %v", missing, err)
+	}
+}
+
+func testSaveIsIdempotent(t *testing.T, repo ports.LogRepository) {
+	save(t, repo,
+		domain.LogEntry{ID: "dup", TrackID: "t", Timestamp: base, Message: "first"},
+		domain.LogEntry{ID: "dup", TrackID: "t", Timestamp: base, Message: "second"},
+	)
+	entries, total, err := repo.Search(context.Background(), ports.LogSearchQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(entries) != 1 || entries[0].Message != "second" {
+		t.Errorf("重复写入同一 ID 应覆盖旧值，得到 %d 条 %+v", total, entries)
+	}
+	if entries[0].Kind != domain.KindServer {
+		t.Errorf("缺省 Kind 应为 server，得到 %q", entries[0].Kind)
+	}
+}
+
+func testSaveBatch(t *testing.T, repo ports.LogRepository) {
+	saver, ok := repo.(ports.BatchSaver)
+	if !ok {
+		t.Skip("未实现 ports.BatchSaver")
+	}
+	err := saver.SaveBatch(context.Background(), []domain.LogEntry{
+		{ID: "a", TrackID: "t", Timestamp: base, Message: "old"},
+		{ID: "b", TrackID: "t", Timestamp: base.Add(time.Second)},
+		{ID: "a", TrackID: "t", Timestamp: base, Message: "new"},
+		{TrackID: "t", Timestamp: base.Add(2 * time.Second)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, total, err := repo.Search(context.Background(), ports.LogSearchQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 {
+		t.Fatalf("期望 3 条 (重复 ID 只保留最后一次，缺少 ID 时自动生成)，得到 %d: %v", total, ids(entries))
+	}
+	if entries[0].ID == "" {
+		t.Error("缺少 ID 的条目应自动生成 ID")
+	}
+	if a, _ := repo.FindByID(context.Background(), "a"); a == nil || a.Message != "new" {
+		t.Errorf("同一批次中重复的 ID 应保留最后一次，得到 %+v", a)
+	}
+}
+
+func testFindByIDFallsBackToTrackID(t *testing.T, repo ports.LogRepository) {
+	save(t, repo,
+		domain.LogEntry{ID: "x1", TrackID: "legacy", Timestamp: base},
+		domain.LogEntry{ID: "x2", TrackID: "legacy", Timestamp: base.Add(time.Minute)},
+		domain.LogEntry{ID: "legacy-id", TrackID: "other", Timestamp: base},
+	)
+	got, err := repo.FindByID(context.Background(), "legacy")
+	if err != nil || got == nil || got.ID != "x2" {
+		t.Errorf("按 Track ID 查找应返回最新的一条，得到 %+v, %v", got, err)
+	}
+}
+
+func testFindByTrace(t *testing.T, repo ports.LogRepository) {
+	trackID := "4bf92f35-77b3-4da6-a3ce-929d0e0e4736"
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	save(t, repo,
+		domain.LogEntry{ID: "s2", TrackID: trackID, TraceID: traceID, Timestamp: base.Add(time.Second)},
+		domain.LogEntry{ID: "s1", TrackID: trackID, TraceID: traceID, Timestamp: base},
+		// 只携带 trace-id 的下游服务日志
+		domain.LogEntry{ID: "s3", TrackID: "downstream", TraceID: traceID, Timestamp: base.Add(2 * time.Second)},
+		domain.LogEntry{ID: "other", TrackID: "other", Timestamp: base},
+	)
+	for _, key := range []string{trackID, traceID} {
+		entries, err := repo.FindByTrace(context.Background(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(entries); !reflect.DeepEqual(got, []string{"s1", "s2", "s3"}) {
+			t.Errorf("FindByTrace(%s) = %v，期望按时间升序的 [s1 s2 s3]", key, got)
+		}
+	}
+	entries, err := repo.FindByTrace(context.Background(), "missing")
+	if err != nil || len(entries) != 0 {
+		t.Errorf("不存在的链路应返回空结果，得到 %v, %v", entries, err)
+	}
+}
+
+// searchFixture 写入 Search 测试使用的数据，时间两两不同以保证排序确定
+func searchFixture(t *testing.T, repo ports.LogRepository) {
+	save(t, repo,
+		domain.LogEntry{ID: "a", TrackID: "track-a", Timestamp: base, Level: "info", Message: "Created order",
+			Request: domain.RequestInfo{Method: "POST", URL: "/api/Orders"}, Response: domain.ResponseInfo{StatusCode: 201}},
+		domain.LogEntry{ID: "b", TrackID: "track-b", Timestamp: base.Add(time.Minute), Level: "error",
+			Request: domain.RequestInfo{Method: "GET", URL: "/api/orders/1"}, Response: domain.ResponseInfo{StatusCode: 500}},
+		domain.LogEntry{ID: "c", TrackID: "track-c", Timestamp: base.Add(2 * time.Minute), Level: "info",
+			Request: domain.RequestInfo{Method: "GET", URL: "/api/users"}, Response: domain.ResponseInfo{StatusCode: 200}},
+		domain.LogEntry{ID: "d", TrackID: "special-track", Timestamp: base.Add(3 * time.Minute), Level: "warn",
+			Request: domain.RequestInfo{Method: "DELETE", URL: "/api/users/7"}, Response: domain.ResponseInfo{StatusCode: 404}},
+	)
+}
+
+func testSearchFilters(t *testing.T, repo ports.LogRepository) {
+	searchFixture(t, repo)
+	cases := []struct {
+		name  string
+		query ports.LogSearchQuery
+		want  []string
+	}{
+		{"无条件按时间倒序", ports.LogSearchQuery{}, []string{"d", "c", "b", "a"}},
+		{"起始时间包含边界", ports.LogSearchQuery{StartTime: base.Add(2 * time.Minute).Format(time.RFC3339)}, []string{"d", "c"}},
+		{"结束时间包含边界", ports.LogSearchQuery{EndTime: base.Add(time.Minute).Format(time.RFC3339)}, []string{"b", "a"}},
+		{"Unix 秒", ports.LogSearchQuery{StartTime: strconv.FormatInt(base.Add(2*time.Minute).Unix(), 10)}, []string{"d", "c"}},
+		{"Unix 毫秒", ports.LogSearchQuery{EndTime: strconv.FormatInt(base.Add(time.Minute).UnixMilli(), 10)}, []string{"b", "a"}},
+		{"相对时间", ports.LogSearchQuery{StartTime: "now-30d", EndTime: "now"}, []string{}},
+		{"相对时间以天为单位", ports.LogSearchQuery{StartTime: "now-36500d"}, []string{"d", "c", "b", "a"}},
+		{"方法", ports.LogSearchQuery{Method: "GET"}, []string{"c", "b"}},
+		{"状态码", ports.LogSearchQuery{Status: 404}, []string{"d"}},
+		{"路径子串不区分大小写", ports.LogSearchQuery{Path: "ORDERS"}, []string{"b", "a"}},
+		{"级别", ports.LogSearchQuery{Level: "info"}, []string{"c", "a"}},
+		{"关键字匹配消息", ports.LogSearchQuery{Keyword: "created"}, []string{"a"}},
+		{"关键字匹配 URL", ports.LogSearchQuery{Keyword: "users/7"}, []string{"d"}},
+		{"关键字匹配 Track ID", ports.LogSearchQuery{Keyword: "SPECIAL"}, []string{"d"}},
+		{"组合条件", ports.LogSearchQuery{Method: "GET", Level: "error", Path: "/api"}, []string{"b"}},
+		{"无匹配", ports.LogSearchQuery{Status: 418}, []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, total, err := repo.Search(context.Background(), tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(entries); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("得到 %v，期望 %v", got, tc.want)
+			}
+			if total != int64(len(tc.want)) {
+				t.Errorf("total = %d，期望 %d", total, len(tc.want))
+			}
+		})
+	}
+}
+
+func testSearchInvalidTime(t *testing.T, repo ports.LogRepository) {
+	for _, q := range []ports.LogSearchQuery{{StartTime: "yesterday"}, {EndTime: "now-15x"}} {
+		_, _, err := repo.Search(context.Background(), q)
+		var invalid *ports.ValidationError
+		if !errors.As(err, &invalid) || invalid.Code != ports.CodeInvalidTime {
+			t.Errorf("%+v: 无法解析的时间应返回 invalid_time，得到 %v", q, err)
+		}
+	}
+}
+
+func testSearchQuery(t *testing.T, repo ports.LogRepository) {
+	searchFixture(t, repo)
+	save(t, repo,
+		domain.LogEntry{ID: "e", TrackID: "track-e", Timestamp: base.Add(4 * time.Minute), DurationMs: 450, Service: "orders",
+			Request: domain.RequestInfo{Method: "POST", URL: "/api/orders/9", Headers: map[string]string{"X-Tenant": "acme"},
+				Body: map[string]interface{}{"user_id": 42, "tags": []interface{}{"vip"}, "note": "100%_off", "paid": true, "coupon": nil}},
+			Response: domain.ResponseInfo{StatusCode: 502, Body: map[string]interface{}{"error": "Upstream timeout"}}},
+		domain.LogEntry{ID: "f", TrackID: "track-f", Timestamp: base.Add(5 * time.Minute), DurationMs: 120, Service: "orders",
+			Request:  domain.RequestInfo{Method: "POST", URL: "/api/orders/10", Body: map[string]interface{}{"user_id": "42"}},
+			Response: domain.ResponseInfo{StatusCode: 503, Body: "service unavailable"}},
+	)
+	cases := []struct {
+		q    string
+		want []string
+	}{
+		{"status>=500 AND method:POST AND path:/api/orders/* AND duration_ms>300 AND req.body.user_id=42", []string{"e"}},
+		{"status>=500", []string{"f", "e", "b"}},
+		{"status:500 OR status=404", []string{"d", "b"}},
+		{"method:post AND NOT path:*/10", []string{"e", "a"}},
+		{"path:/API/ORDERS", []string{"a"}},
+		{"path=/api/Orders", []string{"a"}},
+		{"path!=/api/Orders AND status<500", []string{"d", "c"}},
+		{"(level=info OR level=warn) AND status!=200", []string{"d", "a"}},
+		{"message:*ORDER*", []string{"a"}},
+		{`req.body.user_id="42"`, []string{"f"}},
+		{"req.body.user_id>=42", []string{"e"}},
+		{"req.body.user_id!=42 AND service=orders", []string{"f"}},
+		{"req.body.tags.0=vip", []string{"e"}},
+		{"req.body.paid=true", []string{"e"}},
+		{"req.body.coupon=null", []string{"e"}},
+		{`req.body.note:"100%_*"`, []string{"e"}},
+		{`req.body.note:"100*"`, []string{"e"}},
+		{"req.body.note:100_*", []string{}},
+		{"resp.body.error:*timeout", []string{"e"}},
+		{"req.header.x-tenant=acme", []string{"e"}},
+		{"NOT req.header.x-tenant=acme AND service=orders", []string{"f"}},
+		{"timestamp>=" + base.Add(4*time.Minute).Format(time.RFC3339) + " AND timestamp<" + base.Add(5*time.Minute).Format(time.RFC3339), []string{"e"}},
+		{"", []string{"f", "e", "d", "c", "b", "a"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.q, func(t *testing.T) {
+			entries, total, err := repo.Search(context.Background(), ports.LogSearchQuery{Q: tc.q})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(entries); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("得到 %v，期望 %v", got, tc.want)
+			}
+			if total != int64(len(tc.want)) {
+				t.Errorf("total = %d，期望 %d", total, len(tc.want))
+			}
+		})
+	}
+
+	if _, _, err := repo.Search(context.Background(), ports.LogSearchQuery{Q: "status>="}); err == nil {
+		t.Error("语法错误的查询应返回错误")
+	}
+}
+
+func testSearchJSON(t *testing.T, repo ports.LogRepository) {
+	save(t, repo,
+		domain.LogEntry{ID: "a", TrackID: "track-a", Timestamp: base,
+			Request: domain.RequestInfo{Headers: map[string]string{"X-Tenant": "acme"}, Body: map[string]interface{}{"amount": 100}},
+			Response: domain.ResponseInfo{StatusCode: 402, Body: map[string]interface{}{
+				"error": map[string]interface{}{"code": "INSUFFICIENT_FUNDS", "retry": false}}}},
+		domain.LogEntry{ID: "b", TrackID: "track-b", Timestamp: base.Add(time.Minute),
+			Request: domain.RequestInfo{Headers: map[string]string{"X-Tenant": "globex"}, Body: map[string]interface{}{"amount": "100"}},
+			Response: domain.ResponseInfo{StatusCode: 402, Body: map[string]interface{}{
+				"error": map[string]interface{}{"code": "INSUFFICIENT_FUNDS", "retry": true}}}},
+		domain.LogEntry{ID: "c", TrackID: "track-c", Timestamp: base.Add(2 * time.Minute),
+			Request:  domain.RequestInfo{Headers: map[string]string{"X-Tenant": "acme"}, Body: "not json"},
+			Response: domain.ResponseInfo{StatusCode: 200, Body: map[string]interface{}{"error": nil}}},
+	)
+	cases := []struct {
+		name string
+		json map[string]interface{}
+		want []string
+	}{
+		{"嵌套字段", map[string]interface{}{"response_body.error.code": "INSUFFICIENT_FUNDS"}, []string{"b", "a"}},
+		{"Header", map[string]interface{}{"request_headers.X-Tenant": "acme"}, []string{"c", "a"}},
+		{"Header 名称规范化", map[string]interface{}{"request_headers.x-tenant": "globex"}, []string{"b"}},
+		{"多个条件同时满足", map[string]interface{}{"response_body.error.code": "INSUFFICIENT_FUNDS", "request_headers.X-Tenant": "acme"}, []string{"a"}},
+		{"数字与字符串区分", map[string]interface{}{"request_body.amount": float64(100)}, []string{"a"}},
+		{"字符串", map[string]interface{}{"request_body.amount": "100"}, []string{"b"}},
+		{"布尔值", map[string]interface{}{"response_body.error.retry": false}, []string{"a"}},
+		{"null", map[string]interface{}{"response_body.error": nil}, []string{"c"}},
+		{"注入的值按字面比较", map[string]interface{}{"request_headers.X-Tenant": "acme' OR '1'='1"}, []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, total, err := repo.Search(context.Background(), ports.LogSearchQuery{JSON: tc.json})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(entries); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("得到 %v，期望 %v", got, tc.want)
+			}
+			if total != int64(len(tc.want)) {
+				t.Errorf("total = %d，期望 %d", total, len(tc.want))
+			}
+		})
+	}
+
+	for _, bad := range []map[string]interface{}{
+		{"status_code.x": 1},
+		{"response_body.error": map[string]interface{}{"code": "X"}},
+		{"request_headers.X-Tenant": 1},
+	} {
+		if _, _, err := repo.Search(context.Background(), ports.LogSearchQuery{JSON: bad}); err == nil {
+			t.Errorf("%v 应返回错误", bad)
+		}
+	}
+}
+
+func testSearchText(t *testing.T, repo ports.LogRepository) {
+	save(t, repo,
+		domain.LogEntry{ID: "a", TrackID: "track-a", Timestamp: base.Add(2 * time.Minute), Message: "Payment gateway timeout",
+			Response: domain.ResponseInfo{Body: map[string]interface{}{"error": map[string]interface{}{"detail": "card declined by issuer"}}}},
+		domain.LogEntry{ID: "b", TrackID: "track-b", Timestamp: base.Add(time.Minute), Message: "Payment succeeded",
+			Response: domain.ResponseInfo{Body: map[string]interface{}{"status": "captured", "amount": 1999}}},
+		domain.LogEntry{ID: "c", TrackID: "track-c", Timestamp: base, Message: "Gateway timeout while contacting issuer",
+			Request: domain.RequestInfo{Body: "retry scheduled"}},
+	)
+	cases := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"单词", "timeout", []string{"a", "c"}},
+		{"不区分大小写", "PAYMENT", []string{"a", "b"}},
+		{"多个词同时满足", "payment timeout", []string{"a"}},
+		{"Body 中的文本", "captured", []string{"b"}},
+		{"Body 中的数字", "1999", []string{"b"}},
+		{"字符串 Body", "scheduled", []string{"c"}},
+		{"短语", `"card declined"`, []string{"a"}},
+		{"短语顺序", `"declined card"`, []string{}},
+		{"前缀", "decl*", []string{"a"}},
+		{"短语前缀", `"card decl"*`, []string{"a"}},
+		{"不完整的词不匹配", "decl", []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, total, err := repo.Search(context.Background(), ports.LogSearchQuery{Text: tc.text, Sort: ports.SortTime})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(entries); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("得到 %v，期望 %v", got, tc.want)
+			}
+			if total != int64(len(tc.want)) {
+				t.Errorf("total = %d，期望 %d", total, len(tc.want))
+			}
+		})
+	}
+
+	// 默认按相关度排序：消息中的匹配比 Body 中的权重高
+	entries, _, err := repo.Search(context.Background(), ports.LogSearchQuery{Text: "issuer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(entries); !reflect.DeepEqual(got, []string{"c", "a"}) {
+		t.Errorf("按相关度排序得到 %v，期望 [c a]", got)
+	}
+	for _, e := range entries {
+		if !strings.Contains(e.Highlight, "<mark>issuer</mark>") {
+			t.Errorf("%s 的摘要未标记匹配: %q", e.ID, e.Highlight)
+		}
+	}
+
+	if _, _, err := repo.Search(context.Background(), ports.LogSearchQuery{Text: "issuer", Cursor: ports.CursorAfter(entries[0])}); err == nil {
+		t.Error("相关度排序不支持游标分页")
+	}
+	entries, _, err = repo.Search(context.Background(), ports.LogSearchQuery{Text: "timeout", Sort: ports.SortTime, Size: 1})
+	if err != nil || len(entries) != 1 {
+		t.Fatal(entries, err)
+	}
+	entries, _, err = repo.Search(context.Background(), ports.LogSearchQuery{Text: "timeout", Sort: ports.SortTime, Cursor: ports.CursorAfter(entries[0])})
+	if err != nil || !reflect.DeepEqual(ids(entries), []string{"c"}) {
+		t.Errorf("按时间排序时游标分页: %v %v", ids(entries), err)
+	}
+}
+
+func testSearchFilterLists(t *testing.T, repo ports.LogRepository) {
+	save(t, repo,
+		domain.LogEntry{ID: "a", TrackID: "track-a", Timestamp: base, DurationMs: 120, ClientIP: "10.1.2.3",
+			Service: "orders", Environment: "prod",
+			Request: domain.RequestInfo{Method: "GET"}, Response: domain.ResponseInfo{StatusCode: 200}},
+		domain.LogEntry{ID: "b", TrackID: "track-b", Timestamp: base.Add(time.Minute), DurationMs: 800, ClientIP: "192.168.0.9",
+			Service: "orders", Environment: "staging",
+			Request: domain.RequestInfo{Method: "HEAD"}, Response: domain.ResponseInfo{StatusCode: 404}},
+		domain.LogEntry{ID: "c", TrackID: "track-c", Timestamp: base.Add(2 * time.Minute), DurationMs: 1500, ClientIP: "2001:db8::1",
+			Service: "payments", Environment: "prod",
+			Request: domain.RequestInfo{Method: "POST"}, Response: domain.ResponseInfo{StatusCode: 503}},
+		domain.LogEntry{ID: "d", TrackID: "track-d", Timestamp: base.Add(3 * time.Minute), DurationMs: 2500, ClientIP: "unknown",
+			Service: "healthcheck",
+			Request: domain.RequestInfo{Method: "OPTIONS"}, Response: domain.ResponseInfo{StatusCode: 500}},
+	)
+	cases := []struct {
+		name  string
+		query ports.LogSearchQuery
+		want  []string
+	}{
+		{"状态码类别", ports.LogSearchQuery{LogFilter: ports.LogFilter{StatusClasses: []string{"5XX"}}}, []string{"d", "c"}},
+		{"多个状态码类别", ports.LogSearchQuery{LogFilter: ports.LogFilter{StatusClasses: []string{"2xx", "4xx"}}}, []string{"b", "a"}},
+		{"状态码范围", ports.LogSearchQuery{LogFilter: ports.LogFilter{StatusMin: 404, StatusMax: 500}}, []string{"d", "b"}},
+		{"方法列表", ports.LogSearchQuery{LogFilter: ports.LogFilter{Methods: []string{"get", "HEAD"}}}, []string{"b", "a"}},
+		{"耗时范围", ports.LogSearchQuery{LogFilter: ports.LogFilter{DurationMin: 500, DurationMax: 2000}}, []string{"c", "b"}},
+		{"耗时下限", ports.LogSearchQuery{LogFilter: ports.LogFilter{DurationMin: 1500}}, []string{"d", "c"}},
+		{"网段", ports.LogSearchQuery{LogFilter: ports.LogFilter{ClientIPs: []string{"10.0.0.0/8"}}}, []string{"a"}},
+		{"多个网段和单个地址", ports.LogSearchQuery{LogFilter: ports.LogFilter{ClientIPs: []string{"2001:db8::/32", "192.168.0.9"}}}, []string{"c", "b"}},
+		{"主机位被清零", ports.LogSearchQuery{LogFilter: ports.LogFilter{ClientIPs: []string{"192.168.5.5/16"}}}, []string{"b"}},
+		{"服务列表", ports.LogSearchQuery{LogFilter: ports.LogFilter{Services: []string{"payments", "healthcheck"}}}, []string{"d", "c"}},
+		{"环境列表", ports.LogSearchQuery{LogFilter: ports.LogFilter{Environments: []string{"prod"}}}, []string{"c", "a"}},
+		{"不同字段同时满足", ports.LogSearchQuery{LogFilter: ports.LogFilter{Services: []string{"orders"}, StatusClasses: []string{"4xx"}}}, []string{"b"}},
+		{"与单值条件组合", ports.LogSearchQuery{Method: "POST", LogFilter: ports.LogFilter{Environments: []string{"prod"}}}, []string{"c"}},
+		{"排除方法", ports.LogSearchQuery{Exclude: &ports.LogFilter{Methods: []string{"OPTIONS", "HEAD"}}}, []string{"c", "a"}},
+		{"排除任一条件", ports.LogSearchQuery{Exclude: &ports.LogFilter{Services: []string{"healthcheck"}, StatusClasses: []string{"2xx"}}}, []string{"c", "b"}},
+		{"排除网段时保留无法解析的地址", ports.LogSearchQuery{Exclude: &ports.LogFilter{ClientIPs: []string{"0.0.0.0/0", "::/0"}}}, []string{"d"}},
+		{"排除缺失的环境", ports.LogSearchQuery{Exclude: &ports.LogFilter{Environments: []string{""}}}, []string{"c", "b", "a"}},
+		{"包含与排除", ports.LogSearchQuery{LogFilter: ports.LogFilter{Services: []string{"orders", "payments"}}, Exclude: &ports.LogFilter{DurationMax: 1000}}, []string{"c"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, total, err := repo.Search(context.Background(), tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(entries); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("得到 %v，期望 %v", got, tc.want)
+			}
+			if total != int64(len(tc.want)) {
+				t.Errorf("total = %d，期望 %d", total, len(tc.want))
+			}
+		})
+	}
+
+	for _, bad := range []ports.LogSearchQuery{
+		{LogFilter: ports.LogFilter{StatusClasses: []string{"6xx"}}},
+		{LogFilter: ports.LogFilter{ClientIPs: []string{"10.0.0.0/33"}}},
+		{Exclude: &ports.LogFilter{ClientIPs: []string{"not-an-ip"}}},
+	} {
+		if _, _, err := repo.Search(context.Background(), bad); err == nil {
+			t.Errorf("%+v 应返回错误", bad)
+		}
+	}
+}
+
+func testSearchRoute(t *testing.T, repo ports.LogRepository) {
+	save(t, repo,
+		domain.LogEntry{ID: "a", TrackID: "t", Timestamp: base, Route: "/users/:id", Handler: "main.getUser",
+			Request: domain.RequestInfo{Method: "GET", URL: "/users/1"}, Response: domain.ResponseInfo{StatusCode: 200}},
+		domain.LogEntry{ID: "b", TrackID: "t", Timestamp: base.Add(time.Minute), Route: "/users/:id", Handler: "main.getUser",
+			Request: domain.RequestInfo{Method: "GET", URL: "/users/2"}, Response: domain.ResponseInfo{StatusCode: 404}},
+		domain.LogEntry{ID: "c", TrackID: "t", Timestamp: base.Add(2 * time.Minute), Route: "/users/:id/orders", Handler: "main.listOrders",
+			Request: domain.RequestInfo{Method: "GET", URL: "/users/1/orders"}, Response: domain.ResponseInfo{StatusCode: 200}},
+		domain.LogEntry{ID: "d", TrackID: "t", Timestamp: base.Add(3 * time.Minute),
+			Request: domain.RequestInfo{Method: "GET", URL: "/health"}, Response: domain.ResponseInfo{StatusCode: 200}},
+	)
+	cases := []struct {
+		name  string
+		query ports.LogSearchQuery
+		want  []string
+	}{
+		{"路由列表按原样比较", ports.LogSearchQuery{LogFilter: ports.LogFilter{Routes: []string{"/users/:id"}}}, []string{"b", "a"}},
+		{"多个路由", ports.LogSearchQuery{LogFilter: ports.LogFilter{Routes: []string{"/users/:id/orders", "/USERS/:ID"}}}, []string{"c"}},
+		{"排除路由", ports.LogSearchQuery{Exclude: &ports.LogFilter{Routes: []string{"/users/:id"}}}, []string{"d", "c"}},
+		{"查询语言", ports.LogSearchQuery{Q: `route="/users/:id" AND status>=400`}, []string{"b"}},
+		{"通配符", ports.LogSearchQuery{Q: `route:"/users/*"`}, []string{"c", "b", "a"}},
+		{"处理函数名", ports.LogSearchQuery{Q: `handler:*listOrders`}, []string{"c"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, _, err := repo.Search(context.Background(), tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(entries); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("得到 %v，期望 %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func testSearchPaging(t *testing.T, repo ports.LogRepository) {
+	searchFixture(t, repo)
+	cases := []struct {
+		page, size int
+		want       []string
+	}{
+		{1, 3, []string{"d", "c", "b"}},
+		{2, 3, []string{"a"}},
+		{3, 3, []string{}},
+		{0, 0, []string{"d", "c", "b", "a"}}, // 缺省为第 1 页、每页 10 条
+	}
+	for _, tc := range cases {
+		entries, total, err := repo.Search(context.Background(), ports.LogSearchQuery{Page: tc.page, Size: tc.size})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(entries); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("page=%d size=%d: 得到 %v，期望 %v", tc.page, tc.size, got, tc.want)
+		}
+		if total != 4 {
+			t.Errorf("page=%d size=%d: total = %d，期望不受分页影响的 4", tc.page, tc.size, total)
+		}
+	}
+}
+
+func testSearchCursor(t *testing.T, repo ports.LogRepository) {
+	// 时间相同的条目按 id 倒序，翻页时既不重复也不遗漏
+	save(t, repo,
+		domain.LogEntry{ID: "a", TrackID: "t", Timestamp: base},
+		domain.LogEntry{ID: "b", TrackID: "t", Timestamp: base.Add(time.Second)},
+		domain.LogEntry{ID: "c", TrackID: "t", Timestamp: base.Add(time.Second)},
+		domain.LogEntry{ID: "d", TrackID: "t", Timestamp: base.Add(time.Second)},
+		domain.LogEntry{ID: "e", TrackID: "t", Timestamp: base.Add(2 * time.Second)},
+	)
+	ctx := context.Background()
+
+	var got []string
+	query := ports.LogSearchQuery{Size: 2, Count: ports.CountNone}
+	for i := 0; i < 5; i++ {
+		entries, total, err := repo.Search(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != -1 {
+			t.Errorf("count=none 时 total 应为 -1，得到 %d", total)
+		}
+		if len(entries) == 0 {
+			break
+		}
+		got = append(got, ids(entries)...)
+		query.Cursor = ports.CursorAfter(entries[len(entries)-1])
+	}
+	if want := []string{"e", "d", "c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("按游标翻页得到 %v，期望 %v", got, want)
+	}
+
+	// 游标与其他条件组合时，总数统计不受游标位置影响
+	cursor := ports.CursorAfter(domain.LogEntry{ID: "c", Timestamp: base.Add(time.Second)})
+	entries, total, err := repo.Search(ctx, ports.LogSearchQuery{Cursor: cursor, Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids(entries), []string{"b", "a"}) || total != 5 {
+		t.Errorf("游标之后得到 %v total=%d", ids(entries), total)
+	}
+	if _, total, err := repo.Search(ctx, ports.LogSearchQuery{Count: ports.CountEstimate}); err != nil || total < 0 {
+		t.Errorf("count=estimate: %d %v", total, err)
+	}
+	if _, _, err := repo.Search(ctx, ports.LogSearchQuery{Cursor: "not a cursor"}); err == nil {
+		t.Error("无效的游标应返回错误")
+	}
+}
+
+func testPurgeExpired(t *testing.T, repo ports.LogRepository) {
+	purger, ok := repo.(ports.LogPurger)
+	if !ok {
+		t.Skip("未实现 ports.LogPurger")
+	}
+	ctx := context.Background()
+	day := func(n int) time.Time { return base.AddDate(0, 0, n) }
+	now := day(100)
+	policy := domain.RetentionPolicy{
+		{Name: "errors", Level: "error", KeepDays: 90},
+		{Name: "audit", Service: "audit"},
+		{Name: "prod-ok", Environment: "production", StatusMax: 399, KeepDays: 7},
+		{Name: "default", KeepDays: 30},
+	}
+	save(t, repo,
+		domain.LogEntry{ID: "old-error", TrackID: "t", Timestamp: day(0), Level: "error", Environment: "production"},
+		domain.LogEntry{ID: "recent-error", TrackID: "t", Timestamp: day(20), Level: "error"},
+		domain.LogEntry{ID: "audit", TrackID: "t", Timestamp: day(0), Service: "audit"},
+		domain.LogEntry{ID: "old-ok", TrackID: "t", Timestamp: day(90), Environment: "production", Response: domain.ResponseInfo{StatusCode: 200}},
+		domain.LogEntry{ID: "recent-ok", TrackID: "t", Timestamp: day(95), Environment: "production", Response: domain.ResponseInfo{StatusCode: 200}},
+		domain.LogEntry{ID: "staging", TrackID: "t", Timestamp: day(60), Environment: "staging", Response: domain.ResponseInfo{StatusCode: 200}},
+		domain.LogEntry{ID: "prod-500", TrackID: "t", Timestamp: day(80), Environment: "production", Response: domain.ResponseInfo{StatusCode: 500}},
+	)
+
+	// 每条日志只由第一条匹配的规则决定：old-error 同时匹配 prod-ok 和 default，但按 errors 保留 90 天
+	want := []int64{1, 0, 1, 1}
+	for i, n := range want {
+		got, err := purger.CountExpired(ctx, policy, i, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != n {
+			t.Errorf("规则 %s 应统计到 %d 条，得到 %d", policy[i].Name, n, got)
+		}
+	}
+	for i, n := range want {
+		var deleted int64
+		for {
+			d, err := purger.DeleteExpired(ctx, policy, i, now, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d == 0 {
+				break
+			}
+			deleted += d
+		}
+		if deleted != n {
+			t.Errorf("规则 %s 应删除 %d 条，得到 %d", policy[i].Name, n, deleted)
+		}
+	}
+
+	entries, _, err := repo.Search(ctx, ports.LogSearchQuery{Size: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(entries); !reflect.DeepEqual(got, []string{"recent-ok", "prod-500", "recent-error", "audit"}) {
+		t.Errorf("清理后剩余 %v", got)
+	}
+}
+
+// statsLine 把一组统计值格式化为便于比较的字符串，浮点数保留固定精度以容忍各存储的舍入差异
+func statsLine(key string, s ports.StatsSummary) string {
+	return fmt.Sprintf("%s n=%d err=%d rate=%.3f p50=%.2f p90=%.2f p99=%.2f size=%.1f",
+		key, s.Count, s.Errors, s.ErrorRate, s.P50, s.P90, s.P99, s.AvgResponseSize)
+}
+
+func testStats(t *testing.T, repo ports.LogRepository) {
+	analyzer, ok := repo.(ports.LogAnalyzer)
+	if !ok {
+		t.Skip("未实现 ports.LogAnalyzer")
+	}
+	// a 没有路由模板，按不含查询参数的路径与 b、c 归为一组
+	save(t, repo,
+		domain.LogEntry{ID: "a", TrackID: "t", Timestamp: base, DurationMs: 10, Service: "orders",
+			Request: domain.RequestInfo{Method: "GET", URL: "/api/orders?page=2"}, Response: domain.ResponseInfo{StatusCode: 200, Size: 100}},
+		domain.LogEntry{ID: "b", TrackID: "t", Timestamp: base.Add(30 * time.Second), DurationMs: 20, Service: "orders", Route: "/api/orders",
+			Request: domain.RequestInfo{Method: "GET", URL: "/api/orders?page=3"}, Response: domain.ResponseInfo{StatusCode: 500, Size: 300}},
+		domain.LogEntry{ID: "c", TrackID: "t", Timestamp: base.Add(90 * time.Second), DurationMs: 40, Service: "orders", Route: "/api/orders",
+			Request: domain.RequestInfo{Method: "GET", URL: "/api/orders"}, Response: domain.ResponseInfo{StatusCode: 200, Size: 200}},
+		domain.LogEntry{ID: "d", TrackID: "t", Timestamp: base.Add(2 * time.Minute), DurationMs: 100, Service: "users", Route: "/api/users/:id",
+			Request: domain.RequestInfo{Method: "POST", URL: "/api/users/7"}, Response: domain.ResponseInfo{StatusCode: 201}},
+		// 没有状态码的普通日志不参与统计
+		domain.LogEntry{ID: "e", TrackID: "t", Timestamp: base.Add(3 * time.Minute), Message: "worker started"},
+		// 时间范围之外
+		domain.LogEntry{ID: "f", TrackID: "t", Timestamp: base.Add(time.Hour), DurationMs: 5,
+			Request: domain.RequestInfo{Method: "GET", URL: "/api/orders"}, Response: domain.ResponseInfo{StatusCode: 200}},
+	)
+	window := func(q ports.StatsQuery) ports.StatsQuery {
+		q.StartTime = base.Add(-time.Minute).Format(time.RFC3339)
+		q.EndTime = base.Add(10 * time.Minute).Format(time.RFC3339)
+		q.Interval = "1m"
+		return q
+	}
+	cases := []struct {
+		name  string
+		query ports.StatsQuery
+		want  []string
+	}{
+		{"按路由分组", window(ports.StatsQuery{GroupBy: ports.GroupByRoute}), []string{
+			"/api/orders n=3 err=1 rate=0.333 p50=20.00 p90=36.00 p99=39.60 size=200.0",
+			"/api/users/:id n=1 err=0 rate=0.000 p50=100.00 p90=100.00 p99=100.00 size=0.0",
+		}},
+		{"按 p99 排序", window(ports.StatsQuery{GroupBy: ports.GroupByRoute, OrderBy: ports.StatsOrderP99}), []string{
+			"/api/users/:id n=1 err=0 rate=0.000 p50=100.00 p90=100.00 p99=100.00 size=0.0",
+			"/api/orders n=3 err=1 rate=0.333 p50=20.00 p90=36.00 p99=39.60 size=200.0",
+		}},
+		{"限制分组数量", window(ports.StatsQuery{GroupBy: ports.GroupByService, LogSearchQuery: ports.LogSearchQuery{Size: 1}}), []string{
+			"orders n=3 err=1 rate=0.333 p50=20.00 p90=36.00 p99=39.60 size=200.0",
+		}},
+		{"按状态码类别分组", window(ports.StatsQuery{GroupBy: ports.GroupByStatusClass}), []string{
+			"2xx n=3 err=0 rate=0.000 p50=40.00 p90=88.00 p99=98.80 size=100.0",
+			"5xx n=1 err=1 rate=1.000 p50=20.00 p90=20.00 p99=20.00 size=300.0",
+		}},
+		{"不分组并使用搜索条件", window(ports.StatsQuery{LogSearchQuery: ports.LogSearchQuery{Method: "GET"}}), []string{
+			" n=3 err=1 rate=0.333 p50=20.00 p90=36.00 p99=39.60 size=200.0",
+		}},
+		{"无匹配", window(ports.StatsQuery{GroupBy: ports.GroupByMethod, LogSearchQuery: ports.LogSearchQuery{
+			LogFilter: ports.LogFilter{Methods: []string{"PUT"}}}}), []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			groups, err := analyzer.Stats(context.Background(), tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, g := range groups {
+				got = append(got, statsLine(g.Key, g.StatsSummary))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("得到 %q，期望 %q", got, tc.want)
+			}
+		})
+	}
+
+	groups, err := analyzer.Stats(context.Background(), window(ports.StatsQuery{GroupBy: ports.GroupByRoute}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buckets []string
+	for _, b := range groups[0].Buckets {
+		buckets = append(buckets, statsLine(b.Time.UTC().Format(time.RFC3339), b.StatsSummary))
+	}
+	want := []string{
+		"2024-05-01T12:00:00Z n=2 err=1 rate=0.500 p50=15.00 p90=19.00 p99=19.90 size=200.0",
+		"2024-05-01T12:01:00Z n=1 err=0 rate=0.000 p50=40.00 p90=40.00 p99=40.00 size=200.0",
+	}
+	if !reflect.DeepEqual(buckets, want) {
+		t.Errorf("时间段得到 %q，期望 %q", buckets, want)
+	}
+
+	for _, bad := range []ports.StatsQuery{{GroupBy: "host"}, {OrderBy: "p95"}, {Interval: "500ms"}, {Interval: "1s", LogSearchQuery: ports.LogSearchQuery{StartTime: "now-1d"}}} {
+		var invalid *ports.ValidationError
+		if _, err := analyzer.Stats(context.Background(), bad); !errors.As(err, &invalid) {
+			t.Errorf("%+v 应返回校验错误，得到 %v", bad, err)
+		}
+	}
+}
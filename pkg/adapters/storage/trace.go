@@ -0,0 +1,15 @@
+package storage
+
+import "github.com/MCCodingMan/TraceBuddy/pkg/utils"
+
+// traceKeys 将外部传入的链路 ID 换算为 Track ID 和 W3C trace-id 两种形式，
+// 使得无论调用方持有哪一种都能查到完整链路
+func traceKeys(id string) (trackID, traceID string) {
+	if t, ok := utils.TraceIDFromTrackID(id); ok {
+		if len(id) == 32 {
+			return utils.TrackIDFromTraceID(t), t
+		}
+		return id, t
+	}
+	return id, id
+}
@@ -2,18 +2,33 @@ package domain
 
 import "time"
 
+// 日志条目的类型，与 OpenTelemetry 的 SpanKind 对应
+const (
+	KindServer = "server"
+	KindClient = "client"
+)
+
 // LogEntry 代表核心日志实体
 type LogEntry struct {
-	TrackID     string       `json:"track_id"`
-	Timestamp   time.Time    `json:"timestamp"`
-	DurationMs  int64        `json:"duration_ms"`
-	Request     RequestInfo  `json:"request"`
-	Response    ResponseInfo `json:"response"`
-	ClientIP    string       `json:"client_ip"`
-	Service     string       `json:"service,omitempty"`
-	Environment string       `json:"environment,omitempty"`
-	Level       string       `json:"level,omitempty"`   // 日志级别: info, warn, error
-	Message     string       `json:"message,omitempty"` // 日志内容
+	ID           string       `json:"id"`                       // 单次请求/响应的唯一 ID
+	TrackID      string       `json:"track_id"`                 // 链路关联 ID，同一链路的多个服务共享
+	TraceID      string       `json:"trace_id,omitempty"`       // W3C trace-id，TrackID 为 UUID 时可互相换算
+	SpanID       string       `json:"span_id,omitempty"`        // 本次请求的 span-id
+	ParentSpanID string       `json:"parent_span_id,omitempty"` // 上游 span-id，根 span 为空
+	Kind         string       `json:"kind,omitempty"`           // server: 服务收到的请求, client: 服务发出的请求
+	Route        string       `json:"route,omitempty"`          // 路由模板，如 /users/:id，不含路径参数的具体值
+	Handler      string       `json:"handler,omitempty"`        // 处理请求的函数名，只有 gin 中间件能记录
+	Timestamp    time.Time    `json:"timestamp"`
+	DurationMs   int64        `json:"duration_ms"`
+	Request      RequestInfo  `json:"request"`
+	Response     ResponseInfo `json:"response"`
+	ClientIP     string       `json:"client_ip"`
+	Service      string       `json:"service,omitempty"`
+	Environment  string       `json:"environment,omitempty"`
+	Level        string       `json:"level,omitempty"`   // 日志级别: debug, info, warn, error
+	Message      string       `json:"message,omitempty"` // 日志内容
+	// Highlight 全文搜索时的匹配摘要，匹配的词用 <mark></mark> 包围，只出现在搜索结果中，不会持久化
+	Highlight string `json:"highlight,omitempty"`
 }
 
 // RequestInfo 捕获HTTP请求详情
@@ -24,6 +39,9 @@ type RequestInfo struct {
 	Headers     map[string]string `json:"headers"`
 	QueryParams map[string]string `json:"query_params,omitempty"`
 	Body        interface{}       `json:"body,omitempty"` // 可以是字符串或 map[string]interface{}
+	Size        int64             `json:"size"`           // 请求体原始字节数
+	// BodyTruncated 表示 Body 只记录了捕获上限内的前缀
+	BodyTruncated bool `json:"body_truncated,omitempty"`
 }
 
 // ResponseInfo 捕获HTTP响应详情
@@ -31,5 +49,7 @@ type ResponseInfo struct {
 	StatusCode int               `json:"status_code"`
 	Headers    map[string]string `json:"headers"`
 	Body       interface{}       `json:"body,omitempty"`
-	Size       int64             `json:"size"`
+	Size       int64             `json:"size"` // 响应体原始字节数
+	// BodyTruncated 表示 Body 只记录了捕获上限内的前缀
+	BodyTruncated bool `json:"body_truncated,omitempty"`
 }
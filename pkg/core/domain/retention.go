@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetentionRule 日志保留规则，为空的条件不参与匹配
+type RetentionRule struct {
+	Name        string `json:"name"`
+	Service     string `json:"service,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	Level       string `json:"level,omitempty"`
+	StatusMin   int    `json:"status_min,omitempty"` // 状态码下限 (含)
+	StatusMax   int    `json:"status_max,omitempty"` // 状态码上限 (含)
+	KeepDays    int    `json:"keep_days"`            // 保留天数，0 表示永久保留
+}
+
+// Matches 判断条目是否满足规则的所有条件
+func (r RetentionRule) Matches(e LogEntry) bool {
+	if r.Service != "" && e.Service != r.Service {
+		return false
+	}
+	if r.Environment != "" && e.Environment != r.Environment {
+		return false
+	}
+	if r.Level != "" && e.Level != r.Level {
+		return false
+	}
+	if r.StatusMin != 0 && e.Response.StatusCode < r.StatusMin {
+		return false
+	}
+	if r.StatusMax != 0 && e.Response.StatusCode > r.StatusMax {
+		return false
+	}
+	return true
+}
+
+// Cutoff 返回规则的过期时间点，早于该时间的条目可以删除；永久保留时返回零值
+func (r RetentionRule) Cutoff(now time.Time) time.Time {
+	if r.KeepDays <= 0 {
+		return time.Time{}
+	}
+	return now.AddDate(0, 0, -r.KeepDays)
+}
+
+// RetentionPolicy 按顺序匹配的保留规则，条目由第一条匹配的规则决定保留时长，没有匹配的规则时永久保留
+type RetentionPolicy []RetentionRule
+
+// Match 返回第一条匹配的规则下标，没有匹配时返回 -1
+func (p RetentionPolicy) Match(e LogEntry) int {
+	for i, r := range p {
+		if r.Matches(e) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Expired 判断条目按策略在 now 时是否已过期
+func (p RetentionPolicy) Expired(e LogEntry, now time.Time) bool {
+	i := p.Match(e)
+	if i < 0 {
+		return false
+	}
+	cutoff := p[i].Cutoff(now)
+	return !cutoff.IsZero() && e.Timestamp.Before(cutoff)
+}
+
+// Validate 检查规则是否有效，并为未命名的规则补充名称
+func (p RetentionPolicy) Validate() error {
+	names := map[string]bool{}
+	for i := range p {
+		r := &p[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate retention rule name %q", r.Name)
+		}
+		names[r.Name] = true
+		if r.KeepDays < 0 {
+			return fmt.Errorf("retention rule %q: keep_days must not be negative", r.Name)
+		}
+		if r.StatusMin < 0 || r.StatusMax < 0 || (r.StatusMax != 0 && r.StatusMin > r.StatusMax) {
+			return fmt.Errorf("retention rule %q: invalid status range %d-%d", r.Name, r.StatusMin, r.StatusMax)
+		}
+	}
+	return nil
+}
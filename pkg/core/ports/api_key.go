@@ -0,0 +1,8 @@
+package ports
+
+import "context"
+
+// APIKeyValidator 校验远程采集端使用的 API Key
+type APIKeyValidator interface {
+	ValidateAPIKey(ctx context.Context, apiKey string) (bool, error)
+}
@@ -0,0 +1,50 @@
+package ports
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+)
+
+// 搜索结果总数的统计方式
+const (
+	CountExact    = "exact"    // 精确统计 (默认)
+	CountEstimate = "estimate" // 使用查询计划的估算值，不支持估算的存储返回精确值
+	CountNone     = "none"     // 不统计，Search 返回的总数为 -1
+)
+
+// ErrInvalidCursor 游标格式错误
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SearchCursor 键集分页的位置，搜索结果按 (timestamp, id) 倒序排列，下一页从该位置之后开始
+type SearchCursor struct {
+	Timestamp time.Time
+	ID        string
+}
+
+// CursorAfter 返回从 entry 之后继续翻页的游标
+func CursorAfter(entry domain.LogEntry) string {
+	raw := strconv.FormatInt(entry.Timestamp.UnixNano(), 10) + ":" + entry.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor 解析 CursorAfter 生成的游标，对调用方而言游标是不透明的字符串
+func DecodeCursor(cursor string) (SearchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return SearchCursor{}, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return SearchCursor{}, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return SearchCursor{}, ErrInvalidCursor
+	}
+	return SearchCursor{Timestamp: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
@@ -0,0 +1,138 @@
+package ports
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/query"
+)
+
+// LogFilter 多值和范围筛选：同一字段的多个值满足其一即可，不同字段需同时满足
+// 范围的边界包含在内，为 0 时表示不限
+type LogFilter struct {
+	StatusMin int `json:"status_min,omitempty" form:"status_min"`
+	StatusMax int `json:"status_max,omitempty" form:"status_max"`
+	// StatusClasses 状态码类别，如 4xx、5xx
+	StatusClasses []string `json:"status_classes,omitempty" form:"status_classes"`
+	Methods       []string `json:"methods,omitempty" form:"methods"`
+	// DurationMin、DurationMax 耗时范围，单位毫秒
+	DurationMin int64 `json:"duration_min,omitempty" form:"duration_min"`
+	DurationMax int64 `json:"duration_max,omitempty" form:"duration_max"`
+	// ClientIPs 客户端 IP 或网段，如 10.0.0.0/8、2001:db8::/32，无法解析的客户端 IP 不属于任何网段
+	ClientIPs    []string `json:"client_ips,omitempty" form:"client_ips"`
+	Services     []string `json:"services,omitempty" form:"services"`
+	Environments []string `json:"environments,omitempty" form:"environments"`
+	// Routes 路由模板，如 /users/:id，按原样比较
+	Routes []string `json:"routes,omitempty" form:"routes"`
+}
+
+// Conditions 把筛选条件转换为表达式，每个设置了的字段对应一个，没有设置任何字段时返回空
+// prefix 为参数名的前缀，只用于错误信息；值无效时返回 *ValidationError
+func (f LogFilter) Conditions(prefix string) ([]query.Expr, error) {
+	var (
+		conds []query.Expr
+		err   error
+	)
+	add := func(field string, op query.CompareOp, value interface{}) query.Expr {
+		c, cerr := query.NewCompare(field, op, value)
+		if cerr != nil {
+			err = cerr
+			return nil
+		}
+		return c
+	}
+
+	var status []query.Expr
+	if f.StatusMin != 0 {
+		status = append(status, add("status_code", query.OpGe, int64(f.StatusMin)))
+	}
+	if f.StatusMax != 0 {
+		status = append(status, add("status_code", query.OpLe, int64(f.StatusMax)))
+	}
+	if s := query.And(status...); s != nil {
+		conds = append(conds, s)
+	}
+	if len(f.StatusClasses) > 0 {
+		var classes []query.Expr
+		for _, class := range f.StatusClasses {
+			min, ok := statusClass(class)
+			if !ok {
+				return nil, &ValidationError{Field: prefix + "status_classes", Code: CodeInvalidValue, Msg: fmt.Sprintf("invalid status class %q, expected 1xx to 5xx", class)}
+			}
+			classes = append(classes, query.And(
+				add("status_code", query.OpGe, min),
+				add("status_code", query.OpLe, min+99),
+			))
+		}
+		conds = append(conds, query.Or(classes...))
+	}
+	if len(f.Methods) > 0 {
+		conds = append(conds, add("method", query.OpIn, f.Methods))
+	}
+
+	var duration []query.Expr
+	if f.DurationMin != 0 {
+		duration = append(duration, add("duration_ms", query.OpGe, f.DurationMin))
+	}
+	if f.DurationMax != 0 {
+		duration = append(duration, add("duration_ms", query.OpLe, f.DurationMax))
+	}
+	if d := query.And(duration...); d != nil {
+		conds = append(conds, d)
+	}
+
+	if len(f.ClientIPs) > 0 {
+		var nets []query.Expr
+		for _, s := range f.ClientIPs {
+			p, err := parsePrefix(s)
+			if err != nil {
+				return nil, &ValidationError{Field: prefix + "client_ips", Code: CodeInvalidValue, Msg: fmt.Sprintf("invalid IP or CIDR %q", s)}
+			}
+			nets = append(nets, add("client_ip", query.OpWithin, p))
+		}
+		conds = append(conds, query.Or(nets...))
+	}
+	if len(f.Services) > 0 {
+		conds = append(conds, add("service", query.OpIn, f.Services))
+	}
+	if len(f.Environments) > 0 {
+		conds = append(conds, add("environment", query.OpIn, f.Environments))
+	}
+	if len(f.Routes) > 0 {
+		conds = append(conds, add("route", query.OpIn, f.Routes))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return conds, nil
+}
+
+// statusClass 解析 4xx 形式的状态码类别，返回类别的最小状态码
+func statusClass(class string) (int64, bool) {
+	class = strings.ToLower(strings.TrimSpace(class))
+	if len(class) != 3 || class[1:] != "xx" || class[0] < '1' || class[0] > '5' {
+		return 0, false
+	}
+	return int64(class[0]-'0') * 100, true
+}
+
+// parsePrefix 解析网段，单个地址视为只包含自身的网段；主机位被清零，10.1.2.3/8 等同于 10.0.0.0/8
+func parsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if addr.Zone() != "" {
+		return netip.Prefix{}, fmt.Errorf("IP with zone %q", s)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
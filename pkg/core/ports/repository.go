@@ -2,17 +2,50 @@ package ports
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
 )
 
+// LogWriter 定义日志写入的接口，AsyncLogger 只依赖该接口
+// 远程上报等只写的实现无需支持查询
+type LogWriter interface {
+	Save(ctx context.Context, entry domain.LogEntry) error
+}
+
 // LogRepository 定义日志存储和检索的接口
 type LogRepository interface {
-	Save(ctx context.Context, entry domain.LogEntry) error
-	FindByID(ctx context.Context, trackID string) (*domain.LogEntry, error)
+	LogWriter
+	// FindByID 按条目 ID 查找，兼容旧链接时也接受 Track ID (返回该链路最新的一条)
+	FindByID(ctx context.Context, id string) (*domain.LogEntry, error)
+	// FindByTrace 返回同一链路 (Track ID 或 trace-id) 的所有条目，按时间升序
+	FindByTrace(ctx context.Context, traceID string) ([]domain.LogEntry, error)
+	// Search 按 query.SortOrder() 排序返回一页结果和总数，query.Count 为 CountNone 时总数为 -1
 	Search(ctx context.Context, query LogSearchQuery) ([]domain.LogEntry, int64, error)
 }
 
+// BatchSaver 是 LogWriter 的可选扩展，支持一次写入多条日志
+// AsyncLogger 检测到该接口时会按批次调用 SaveBatch，否则逐条调用 Save
+type BatchSaver interface {
+	SaveBatch(ctx context.Context, entries []domain.LogEntry) error
+}
+
+// PartialSaveError SaveBatch 只写入了部分条目，Failed 为未写入的条目
+// AsyncLogger 只把 Failed 转存到暂存区，避免已写入的条目重复写入
+type PartialSaveError struct {
+	Failed []domain.LogEntry
+	Err    error
+}
+
+func (e *PartialSaveError) Error() string {
+	return fmt.Sprintf("%d log entries not saved: %v", len(e.Failed), e.Err)
+}
+
+func (e *PartialSaveError) Unwrap() error {
+	return e.Err
+}
+
 // LogSearchQuery 定义日志搜索参数
 type LogSearchQuery struct {
 	Page      int    `json:"page" form:"page"` // 页码
@@ -25,4 +58,30 @@ type LogSearchQuery struct {
 	Path      string `json:"path" form:"path"`
 	Level     string `json:"level" form:"level"`     // 日志级别筛选
 	Keyword   string `json:"keyword" form:"keyword"` // 关键字模糊搜索
+	// Cursor 上一页返回的 next_cursor，设置后按键集分页并忽略 Page
+	Cursor string `json:"cursor,omitempty" form:"cursor"`
+	// Count 总数的统计方式：exact (默认)、estimate 或 none
+	Count string `json:"count,omitempty" form:"count"`
+	// Q 查询语言表达式，如 status>=500 AND path:/api/orders/*，与其他条件同时生效
+	Q string `json:"q,omitempty" form:"q"`
+	// JSON Body 和 Header 的等值条件，键为 列名.路径，如 response_body.error.code、request_headers.X-Tenant
+	JSON map[string]interface{} `json:"json,omitempty" form:"-"`
+	// Text 全文搜索，覆盖消息、URL 和 Body 中的文本；双引号内为短语，以 * 结尾的词按前缀匹配
+	Text string `json:"text,omitempty" form:"text"`
+	// Sort 排序方式：time 或 relevance，设置 Text 时默认为 relevance
+	Sort string `json:"sort,omitempty" form:"sort"`
+	// LogFilter 多值和范围筛选，与 Method、Status 等单值条件同时生效
+	LogFilter
+	// Exclude 排除条件，满足其中任一字段的日志都被排除，如 methods=[OPTIONS] 且 services=[healthcheck]
+	// 排除 OPTIONS 请求和 healthcheck 服务的全部日志
+	Exclude *LogFilter `json:"exclude,omitempty" form:"-"`
+}
+
+// LogPurger 是 LogRepository 的可选扩展，支持按保留策略删除过期日志
+// rule 为策略中的规则下标，只处理第一条匹配的规则为 rule 且早于该规则过期时间的日志
+type LogPurger interface {
+	// CountExpired 统计 rule 负责清理的过期日志数量
+	CountExpired(ctx context.Context, policy domain.RetentionPolicy, rule int, now time.Time) (int64, error)
+	// DeleteExpired 删除其中最多 limit 条，返回实际删除的数量
+	DeleteExpired(ctx context.Context, policy domain.RetentionPolicy, rule int, now time.Time, limit int) (int64, error)
 }
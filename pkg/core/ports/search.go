@@ -0,0 +1,74 @@
+package ports
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/query"
+)
+
+// 搜索结果的排序方式
+const (
+	SortTime      = "time"      // 按 (timestamp, id) 倒序
+	SortRelevance = "relevance" // 按全文搜索的相关度倒序，相关度相同时按时间倒序
+)
+
+// ErrCursorNeedsTimeSort 键集分页依赖时间排序，不能与相关度排序同时使用
+var ErrCursorNeedsTimeSort = errors.New("cursor requires sort=time")
+
+// SortOrder 返回实际使用的排序方式：未指定时，设置了 Text 按相关度排序，否则按时间排序
+func (q LogSearchQuery) SortOrder() string {
+	switch {
+	case q.Sort != "":
+		return q.Sort
+	case q.Text != "":
+		return SortRelevance
+	}
+	return SortTime
+}
+
+// TextTerms 解析全文搜索条件，见 query.ParseText；语法错误返回 *ValidationError
+func (q LogSearchQuery) TextTerms() ([]query.TextTerm, error) {
+	terms, err := query.ParseText(q.Text)
+	if err != nil {
+		return nil, invalid("text", CodeSyntaxError, err)
+	}
+	return terms, nil
+}
+
+// Expr 把 Q、JSON 条件和 LogFilter、Exclude 筛选合并为一个查询表达式，没有条件时返回 nil
+// 条件无效时返回 *ValidationError，Q 的语法错误可以通过 errors.As 取出 *query.SyntaxError
+func (q LogSearchQuery) Expr() (query.Expr, error) {
+	expr, err := query.Parse(q.Q)
+	if err != nil {
+		return nil, invalid("q", CodeSyntaxError, err)
+	}
+	conds, err := q.LogFilter.Conditions("")
+	if err != nil {
+		return nil, err
+	}
+	expr = query.And(append([]query.Expr{expr}, conds...)...)
+	if q.Exclude != nil {
+		excluded, err := q.Exclude.Conditions("exclude.")
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range excluded {
+			expr = query.And(expr, query.Negate(c))
+		}
+	}
+	// 按键排序，生成的 SQL 和参数顺序保持稳定
+	keys := make([]string, 0, len(q.JSON))
+	for k := range q.JSON {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		c, err := query.Equals(k, q.JSON[k])
+		if err != nil {
+			return nil, invalid("json."+k, CodeInvalidValue, err)
+		}
+		expr = query.And(expr, c)
+	}
+	return expr, nil
+}
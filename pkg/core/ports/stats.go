@@ -0,0 +1,135 @@
+package ports
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 统计的分组维度
+const (
+	GroupByRoute       = "route"        // 路由模板，没有记录路由时为不含查询参数的路径
+	GroupByMethod      = "method"       // 请求方法
+	GroupByService     = "service"      // 服务名
+	GroupByStatusClass = "status_class" // 状态码类别，如 5xx
+)
+
+// 分组的排序字段，均按降序排列
+const (
+	StatsOrderCount           = "count"
+	StatsOrderErrorRate       = "error_rate"
+	StatsOrderP50             = "p50"
+	StatsOrderP90             = "p90"
+	StatsOrderP99             = "p99"
+	StatsOrderAvgResponseSize = "avg_response_size"
+)
+
+const (
+	// DefaultStatsWindow 未设置 StartTime 时统计的时间范围
+	DefaultStatsWindow = 24 * time.Hour
+	// MaxStatsBuckets 每组最多的时间段数量
+	MaxStatsBuckets = 1000
+)
+
+// autoStatsIntervals 未设置 Interval 时依次尝试的时间段宽度，取时间段数量不超过 autoStatsBuckets 的第一个
+var autoStatsIntervals = []string{"1m", "5m", "15m", "1h", "6h", "1d", "1w"}
+
+const autoStatsBuckets = 120
+
+// LogAnalyzer 是 LogRepository 的可选扩展，支持按时间段聚合请求日志
+type LogAnalyzer interface {
+	// Stats 按 query.GroupBy 分组统计状态码不为 0 的请求日志，分组按 query.OrderBy 降序排列，最多 query.Size 组
+	// 每组的 Buckets 按时间升序，只包含有数据的时间段
+	Stats(ctx context.Context, query StatsQuery) ([]StatsGroup, error)
+}
+
+// StatsQuery 统计参数，LogSearchQuery 中的筛选条件同样生效，Size 为最多返回的分组数量
+type StatsQuery struct {
+	LogSearchQuery
+	// GroupBy 分组维度：route、method、service 或 status_class，为空时不分组
+	GroupBy string `json:"group_by,omitempty"`
+	// Interval 时间段宽度，如 1m、5m、1h、1d，为空时按时间范围自动选择
+	Interval string `json:"interval,omitempty"`
+	// OrderBy 分组的排序字段，默认为 count
+	OrderBy string `json:"order_by,omitempty"`
+}
+
+// StatsSummary 一组请求的统计值，状态码 >= 500 计为错误，耗时单位为毫秒
+type StatsSummary struct {
+	Count           int64   `json:"count"`
+	Errors          int64   `json:"errors"`
+	ErrorRate       float64 `json:"error_rate"`
+	P50             float64 `json:"p50_ms"`
+	P90             float64 `json:"p90_ms"`
+	P99             float64 `json:"p99_ms"`
+	AvgResponseSize float64 `json:"avg_response_size"`
+}
+
+// StatsBucket 一个时间段的统计值，Time 为时间段的起点
+type StatsBucket struct {
+	Time time.Time `json:"time"`
+	StatsSummary
+}
+
+// StatsGroup 一个分组在整个时间范围内的统计值和按时间段的统计值
+type StatsGroup struct {
+	Key string `json:"key"`
+	StatsSummary
+	Buckets []StatsBucket `json:"buckets"`
+}
+
+// Normalize 校验统计参数并就地规范化：StartTime 缺省为 EndTime 之前 24 小时，EndTime 缺省为 now，
+// Interval 缺省时按时间范围自动选择。参数无效时返回 *ValidationError
+func (q *StatsQuery) Normalize(now time.Time) error {
+	if q.EndTime == "" {
+		q.EndTime = now.UTC().Format(time.RFC3339Nano)
+	}
+	if err := q.LogSearchQuery.Normalize(now); err != nil {
+		return err
+	}
+	end, _ := time.Parse(time.RFC3339Nano, q.EndTime)
+	if q.StartTime == "" {
+		q.StartTime = end.Add(-DefaultStatsWindow).Format(time.RFC3339Nano)
+	}
+	start, _ := time.Parse(time.RFC3339Nano, q.StartTime)
+
+	switch q.GroupBy {
+	case "", GroupByRoute, GroupByMethod, GroupByService, GroupByStatusClass:
+	default:
+		return &ValidationError{Field: "group_by", Code: CodeInvalidValue, Msg: "group_by must be route, method, service or status_class"}
+	}
+	switch q.OrderBy {
+	case "":
+		q.OrderBy = StatsOrderCount
+	case StatsOrderCount, StatsOrderErrorRate, StatsOrderP50, StatsOrderP90, StatsOrderP99, StatsOrderAvgResponseSize:
+	default:
+		return &ValidationError{Field: "order_by", Code: CodeInvalidValue, Msg: "order_by must be count, error_rate, p50, p90, p99 or avg_response_size"}
+	}
+
+	window := end.Sub(start)
+	if q.Interval == "" {
+		for _, interval := range autoStatsIntervals {
+			q.Interval = interval
+			if d, _ := parseOffset(interval); window/d < autoStatsBuckets {
+				break
+			}
+		}
+	}
+	width, err := q.BucketWidth()
+	if err != nil {
+		return err
+	}
+	if window/width >= MaxStatsBuckets {
+		return &ValidationError{Field: "interval", Code: CodeOutOfRange, Msg: fmt.Sprintf("interval splits the time range into more than %d buckets", MaxStatsBuckets)}
+	}
+	return nil
+}
+
+// BucketWidth 解析 Interval，宽度须为整秒。时间段按 Unix 时间对齐，如 1h 的时间段从整点开始，1d 从 UTC 零点开始
+func (q StatsQuery) BucketWidth() (time.Duration, error) {
+	d, err := parseOffset(q.Interval)
+	if err != nil || d < time.Second || d%time.Second != 0 {
+		return 0, &ValidationError{Field: "interval", Code: CodeInvalidValue, Msg: "interval must be a whole number of seconds such as 30s, 5m, 1h or 1d"}
+	}
+	return d, nil
+}
@@ -0,0 +1,178 @@
+package ports
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 搜索参数校验失败的错误码，随 400 响应返回，客户端据此区分错误类型
+const (
+	CodeInvalidValue = "invalid_value" // 值的格式或取值无效
+	CodeInvalidTime  = "invalid_time"  // 时间无法解析
+	CodeOutOfRange   = "out_of_range"  // 数值超出允许的范围
+	CodeSyntaxError  = "syntax_error"  // 查询语言或全文搜索的语法错误
+)
+
+// 每页数量的缺省值和上限
+const (
+	DefaultSearchSize = 10
+	MaxSearchSize     = 1000
+)
+
+// ValidationError 搜索参数无效，Field 为参数名 (排除条件带 exclude. 前缀)，Code 为错误码
+// Err 为原始错误，如 *query.SyntaxError，可以通过 errors.As 取出
+type ValidationError struct {
+	Field string
+	Code  string
+	Msg   string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Msg
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// invalid 把 err 包装为 field 的校验错误
+func invalid(field, code string, err error) *ValidationError {
+	return &ValidationError{Field: field, Code: code, Msg: err.Error(), Err: err}
+}
+
+// errInvalidTime 时间参数的格式错误
+var errInvalidTime = errors.New("expected an RFC3339 time, a Unix timestamp or a relative time such as now-15m")
+
+// ParseTime 解析搜索的时间参数，支持以下格式：
+//   - RFC3339，如 2024-05-01T12:00:00Z
+//   - Unix 时间戳，12 位及以内按秒，13 位及以上按毫秒
+//   - 相对于 now 的时间，如 now、now-15m、now-1h30m、now-7d、now+1w，单位为 s、m、h、d、w
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	if rest, ok := strings.CutPrefix(s, "now"); ok {
+		if rest == "" {
+			return now, nil
+		}
+		d, err := parseOffset(rest[1:])
+		if err != nil {
+			return time.Time{}, errInvalidTime
+		}
+		switch rest[0] {
+		case '-':
+			return now.Add(-d), nil
+		case '+':
+			return now.Add(d), nil
+		}
+		return time.Time{}, errInvalidTime
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && n >= 0 {
+		if n >= 1e12 {
+			return time.UnixMilli(n).UTC(), nil
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+	return time.Time{}, errInvalidTime
+}
+
+// parseOffset 解析相对时间的偏移量，在 time.ParseDuration 的基础上支持天 (d) 和周 (w)
+func parseOffset(s string) (time.Duration, error) {
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	default:
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return 0, errInvalidTime
+		}
+		return d, nil
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 || n > int64(1<<63-1)/int64(unit) {
+		return 0, errInvalidTime
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// TimeRange 解析 StartTime 和 EndTime，未设置的一端返回零值；相对时间以 now 为基准
+func (q LogSearchQuery) TimeRange(now time.Time) (start, end time.Time, err error) {
+	if q.StartTime != "" {
+		if start, err = ParseTime(q.StartTime, now); err != nil {
+			return start, end, invalid("start_time", CodeInvalidTime, err)
+		}
+	}
+	if q.EndTime != "" {
+		if end, err = ParseTime(q.EndTime, now); err != nil {
+			return start, end, invalid("end_time", CodeInvalidTime, err)
+		}
+	}
+	return start, end, nil
+}
+
+// Normalize 校验搜索参数并就地规范化：StartTime、EndTime 转换为 RFC3339 格式的绝对时间，
+// 补齐 Page、Size 的缺省值。参数无效时返回 *ValidationError
+func (q *LogSearchQuery) Normalize(now time.Time) error {
+	start, end, err := q.TimeRange(now)
+	if err != nil {
+		return err
+	}
+	if !start.IsZero() {
+		q.StartTime = start.UTC().Format(time.RFC3339Nano)
+	}
+	if !end.IsZero() {
+		q.EndTime = end.UTC().Format(time.RFC3339Nano)
+	}
+	if !start.IsZero() && !end.IsZero() && end.Before(start) {
+		return &ValidationError{Field: "end_time", Code: CodeInvalidValue, Msg: "end_time is before start_time"}
+	}
+
+	switch {
+	case q.Page < 0:
+		return &ValidationError{Field: "page", Code: CodeOutOfRange, Msg: "page must not be negative"}
+	case q.Size < 0 || q.Size > MaxSearchSize:
+		return &ValidationError{Field: "size", Code: CodeOutOfRange, Msg: fmt.Sprintf("size must be between 1 and %d", MaxSearchSize)}
+	case q.Status != 0 && (q.Status < 100 || q.Status > 599):
+		return &ValidationError{Field: "status", Code: CodeOutOfRange, Msg: "status must be between 100 and 599"}
+	}
+	if q.Page == 0 {
+		q.Page = 1
+	}
+	if q.Size == 0 {
+		q.Size = DefaultSearchSize
+	}
+
+	switch q.Count {
+	case "", CountExact, CountEstimate, CountNone:
+	default:
+		return &ValidationError{Field: "count", Code: CodeInvalidValue, Msg: "count must be exact, estimate or none"}
+	}
+	if q.Cursor != "" {
+		if _, err := DecodeCursor(q.Cursor); err != nil {
+			return invalid("cursor", CodeInvalidValue, err)
+		}
+	}
+	switch q.SortOrder() {
+	case SortTime:
+	case SortRelevance:
+		if q.Cursor != "" {
+			return invalid("cursor", CodeInvalidValue, ErrCursorNeedsTimeSort)
+		}
+	default:
+		return &ValidationError{Field: "sort", Code: CodeInvalidValue, Msg: "sort must be time or relevance"}
+	}
+
+	if _, err := q.Expr(); err != nil {
+		return err
+	}
+	_, err = q.TextTerms()
+	return err
+}
@@ -0,0 +1,70 @@
+package ports
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"2024-05-01T10:00:00Z":        now.Add(-2 * time.Hour),
+		"2024-05-01T20:00:00.5+08:00": now.Add(500 * time.Millisecond),
+		"1714564800":                  now,
+		"1714564800250":               now.Add(250 * time.Millisecond),
+		"now":                         now,
+		"now-15m":                     now.Add(-15 * time.Minute),
+		"now-1h30m":                   now.Add(-90 * time.Minute),
+		"now+1h":                      now.Add(time.Hour),
+		"now-7d":                      now.AddDate(0, 0, -7),
+		" now-2w ":                    now.AddDate(0, 0, -14),
+	}
+	for in, want := range cases {
+		got, err := ParseTime(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("%q: 得到 %v %v，期望 %v", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "yesterday", "now-", "now-15", "now*1h", "now--1h", "now-1.5d", "-1", "2024-05-01"} {
+		if _, err := ParseTime(bad, now); err == nil {
+			t.Errorf("%q 应返回错误", bad)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	q := LogSearchQuery{StartTime: "now-15m", EndTime: "1714564800"}
+	if err := q.Normalize(now); err != nil {
+		t.Fatal(err)
+	}
+	if q.StartTime != "2024-05-01T11:45:00Z" || q.EndTime != "2024-05-01T12:00:00Z" || q.Page != 1 || q.Size != DefaultSearchSize {
+		t.Errorf("规范化结果: %+v", q)
+	}
+
+	cases := []struct {
+		query       LogSearchQuery
+		field, code string
+	}{
+		{LogSearchQuery{StartTime: "yesterday"}, "start_time", CodeInvalidTime},
+		{LogSearchQuery{StartTime: "now", EndTime: "now-1h"}, "end_time", CodeInvalidValue},
+		{LogSearchQuery{Size: MaxSearchSize + 1}, "size", CodeOutOfRange},
+		{LogSearchQuery{Page: -1}, "page", CodeOutOfRange},
+		{LogSearchQuery{Status: 5000}, "status", CodeOutOfRange},
+		{LogSearchQuery{Count: "all"}, "count", CodeInvalidValue},
+		{LogSearchQuery{Sort: "random"}, "sort", CodeInvalidValue},
+		{LogSearchQuery{Cursor: "!"}, "cursor", CodeInvalidValue},
+		{LogSearchQuery{Q: "status>="}, "q", CodeSyntaxError},
+		{LogSearchQuery{Text: `"open`}, "text", CodeSyntaxError},
+		{LogSearchQuery{JSON: map[string]interface{}{"status.x": 1}}, "json.status.x", CodeInvalidValue},
+		{LogSearchQuery{Exclude: &LogFilter{StatusClasses: []string{"9xx"}}}, "exclude.status_classes", CodeInvalidValue},
+	}
+	for _, tc := range cases {
+		err := tc.query.Normalize(now)
+		var invalid *ValidationError
+		if !errors.As(err, &invalid) || invalid.Field != tc.field || invalid.Code != tc.code {
+			t.Errorf("%+v: 期望 %s %s，得到 %v", tc.query, tc.field, tc.code, err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package query 实现日志搜索的查询语言，例如
+//
+//	status>=500 AND method:POST AND path:/api/orders/* AND duration_ms>300 AND req.body.user_id=42
+//
+// Parse 把查询解析为带类型的语法树，存储层再把语法树编译为参数化 SQL 或内存中的匹配函数
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expr 查询表达式：And、Or、Not 或 Compare
+type Expr interface {
+	// Pos 返回表达式在查询中的起始位置 (从 0 开始的字符偏移)
+	Pos() int
+	String() string
+}
+
+// BoolOp 逻辑运算符
+type BoolOp string
+
+const (
+	OpAnd BoolOp = "AND"
+	OpOr  BoolOp = "OR"
+)
+
+// Binary 由 AND 或 OR 连接的两个表达式
+type Binary struct {
+	Op          BoolOp
+	Left, Right Expr
+}
+
+func (b *Binary) Pos() int { return b.Left.Pos() }
+
+func (b *Binary) String() string {
+	return "(" + b.Left.String() + " " + string(b.Op) + " " + b.Right.String() + ")"
+}
+
+// Not 对表达式取反
+type Not struct {
+	X   Expr
+	pos int
+}
+
+func (n *Not) Pos() int { return n.pos }
+
+func (n *Not) String() string { return "NOT " + n.X.String() }
+
+// CompareOp 比较运算符
+type CompareOp string
+
+const (
+	OpEq   CompareOp = "="
+	OpNe   CompareOp = "!="
+	OpGt   CompareOp = ">"
+	OpGe   CompareOp = ">="
+	OpLt   CompareOp = "<"
+	OpLe   CompareOp = "<="
+	OpLike CompareOp = ":" // 字符串不区分大小写匹配，* 匹配任意字符
+	// 以下运算符只由 NewCompare 构造，查询语言中没有对应的语法
+	OpIn     CompareOp = "in"     // 等于列表中的任一值
+	OpWithin CompareOp = "within" // IP 地址属于网段，只用于 client_ip
+)
+
+// Compare 字段与值的比较
+// Value 的类型由字段决定：字符串字段为 string，整数字段为 int64，时间字段为 time.Time，
+// JSON 字段为 string、float64、bool 或 nil (JSON null)；OpIn 的值为对应类型的切片，OpWithin 的值为 netip.Prefix
+type Compare struct {
+	Field Field
+	Op    CompareOp
+	Value interface{}
+	pos   int
+}
+
+func (c *Compare) Pos() int { return c.pos }
+
+func (c *Compare) String() string {
+	var v string
+	switch x := c.Value.(type) {
+	case string:
+		v = strconv.Quote(x)
+	case []string:
+		quoted := make([]string, len(x))
+		for i, item := range x {
+			quoted[i] = strconv.Quote(item)
+		}
+		v = "[" + strings.Join(quoted, ", ") + "]"
+	case []int64:
+		items := make([]string, len(x))
+		for i, n := range x {
+			items[i] = strconv.FormatInt(n, 10)
+		}
+		v = "[" + strings.Join(items, ", ") + "]"
+	case time.Time:
+		v = x.Format(time.RFC3339Nano)
+	case nil:
+		v = "null"
+	default:
+		v = fmt.Sprint(x)
+	}
+	return c.Field.String() + " " + string(c.Op) + " " + v
+}
+
+// FieldType 字段的值类型
+type FieldType int
+
+const (
+	FieldString FieldType = iota
+	FieldInt
+	FieldTime
+	FieldJSON   // 请求或响应 Body 中的 JSON 路径
+	FieldHeader // 请求或响应头
+)
+
+// Field 查询中引用的字段
+// 普通字段的 Name 与 logs 表的列名一致；JSON 字段的 Name 为 request_body 或 response_body，
+// Path 为 JSON 路径；Header 字段的 Name 为 request_headers 或 response_headers，Path 只有一个元素，为规范化后的头名称
+type Field struct {
+	Name string
+	Type FieldType
+	Path []string
+}
+
+func (f Field) String() string {
+	if len(f.Path) == 0 {
+		return f.Name
+	}
+	return f.Name + "." + strings.Join(f.Path, ".")
+}
@@ -0,0 +1,78 @@
+package query
+
+import (
+	"net/textproto"
+	"strings"
+)
+
+// columns 可查询的普通字段，键为查询中使用的名称
+var columns = map[string]Field{
+	"timestamp":      {Name: "timestamp", Type: FieldTime},
+	"status":         {Name: "status_code", Type: FieldInt},
+	"status_code":    {Name: "status_code", Type: FieldInt},
+	"duration":       {Name: "duration_ms", Type: FieldInt},
+	"duration_ms":    {Name: "duration_ms", Type: FieldInt},
+	"request_size":   {Name: "request_size", Type: FieldInt},
+	"response_size":  {Name: "response_size", Type: FieldInt},
+	"method":         {Name: "method", Type: FieldString},
+	"path":           {Name: "url", Type: FieldString},
+	"route":          {Name: "route", Type: FieldString},
+	"handler":        {Name: "handler", Type: FieldString},
+	"url":            {Name: "url", Type: FieldString},
+	"level":          {Name: "level", Type: FieldString},
+	"service":        {Name: "service", Type: FieldString},
+	"env":            {Name: "environment", Type: FieldString},
+	"environment":    {Name: "environment", Type: FieldString},
+	"kind":           {Name: "kind", Type: FieldString},
+	"id":             {Name: "id", Type: FieldString},
+	"track_id":       {Name: "track_id", Type: FieldString},
+	"trace_id":       {Name: "trace_id", Type: FieldString},
+	"span_id":        {Name: "span_id", Type: FieldString},
+	"parent_span_id": {Name: "parent_span_id", Type: FieldString},
+	"client_ip":      {Name: "client_ip", Type: FieldString},
+	"message":        {Name: "message", Type: FieldString},
+}
+
+// prefixed 以前缀引用的 Body 和 Header 字段，如 req.body.user_id、resp.header.content-type，
+// 也可以直接使用列名，如 response_body.error.code、request_headers.X-Tenant
+var prefixed = map[string]Field{
+	"req.body":         {Name: "request_body", Type: FieldJSON},
+	"resp.body":        {Name: "response_body", Type: FieldJSON},
+	"req.header":       {Name: "request_headers", Type: FieldHeader},
+	"resp.header":      {Name: "response_headers", Type: FieldHeader},
+	"request.body":     {Name: "request_body", Type: FieldJSON},
+	"response.body":    {Name: "response_body", Type: FieldJSON},
+	"request_body":     {Name: "request_body", Type: FieldJSON},
+	"response_body":    {Name: "response_body", Type: FieldJSON},
+	"request_headers":  {Name: "request_headers", Type: FieldHeader},
+	"response_headers": {Name: "response_headers", Type: FieldHeader},
+}
+
+// lookupField 解析字段名，未知字段返回 false
+func lookupField(name string) (Field, bool) {
+	lower := strings.ToLower(name)
+	if f, ok := columns[lower]; ok {
+		return f, true
+	}
+	for prefix, f := range prefixed {
+		rest, ok := strings.CutPrefix(lower, prefix+".")
+		if !ok || rest == "" {
+			continue
+		}
+		if f.Type == FieldHeader {
+			// 捕获时保存的是规范化的头名称
+			f.Path = []string{textproto.CanonicalMIMEHeaderKey(rest)}
+			return f, true
+		}
+		// JSON 路径区分大小写，使用原始输入
+		path := strings.Split(name[len(prefix)+1:], ".")
+		for _, seg := range path {
+			if seg == "" {
+				return Field{}, false
+			}
+		}
+		f.Path = path
+		return f, true
+	}
+	return Field{}, false
+}
@@ -0,0 +1,119 @@
+package query
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Equals 构造 Body 或 Header 字段的等值条件，field 形如 response_body.error.code 或 request_headers.X-Tenant，
+// value 为 JSON 标量：字符串、数字、布尔值或 nil，Header 只接受字符串
+func Equals(field string, value interface{}) (*Compare, error) {
+	f, ok := lookupField(field)
+	if !ok || (f.Type != FieldJSON && f.Type != FieldHeader) {
+		return nil, fmt.Errorf("unknown JSON field %q", field)
+	}
+	switch v := value.(type) {
+	case string:
+	case int:
+		value = float64(v)
+	case int64:
+		value = float64(v)
+	case float64, bool, nil:
+	default:
+		return nil, fmt.Errorf("JSON field %q: value must be a string, number, boolean or null", field)
+	}
+	if _, isString := value.(string); f.Type == FieldHeader && !isString {
+		return nil, fmt.Errorf("header field %q: value must be a string", field)
+	}
+	return &Compare{Field: f, Op: OpEq, Value: value}, nil
+}
+
+// NewCompare 构造普通字段的比较，用于把结构化的筛选条件转换为表达式
+// value 的类型需与字段一致：整数字段为 int64，字符串字段为 string，时间字段为 time.Time；
+// OpIn 的值为对应类型的非空切片，OpWithin 只用于 client_ip，值为 netip.Prefix。method 的值转换为大写
+func NewCompare(field string, op CompareOp, value interface{}) (*Compare, error) {
+	f, ok := columns[strings.ToLower(field)]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", field)
+	}
+	if f.Name == "method" {
+		switch v := value.(type) {
+		case string:
+			value = strings.ToUpper(v)
+		case []string:
+			upper := make([]string, len(v))
+			for i, m := range v {
+				upper[i] = strings.ToUpper(m)
+			}
+			value = upper
+		}
+	}
+
+	var valid bool
+	switch op {
+	case OpIn:
+		switch v := value.(type) {
+		case []string:
+			valid = f.Type == FieldString && len(v) > 0
+		case []int64:
+			valid = f.Type == FieldInt && len(v) > 0
+		}
+	case OpWithin:
+		p, isPrefix := value.(netip.Prefix)
+		valid = f.Name == "client_ip" && isPrefix && p.IsValid()
+	case OpEq, OpNe, OpLike:
+		_, isString := value.(string)
+		valid = f.Type == FieldString && isString || op != OpLike && validOrdered(f, value)
+	case OpGt, OpGe, OpLt, OpLe:
+		valid = validOrdered(f, value)
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid comparison %s %s %v", f, op, value)
+	}
+	return &Compare{Field: f, Op: op, Value: value}, nil
+}
+
+// validOrdered 判断值能否与整数或时间字段比较大小
+func validOrdered(f Field, value interface{}) bool {
+	switch value.(type) {
+	case int64:
+		return f.Type == FieldInt
+	case time.Time:
+		return f.Type == FieldTime
+	}
+	return false
+}
+
+// And 用 AND 连接多个表达式，忽略其中的 nil
+func And(exprs ...Expr) Expr {
+	return join(OpAnd, exprs)
+}
+
+// Or 用 OR 连接多个表达式，忽略其中的 nil
+func Or(exprs ...Expr) Expr {
+	return join(OpOr, exprs)
+}
+
+func join(op BoolOp, exprs []Expr) Expr {
+	var out Expr
+	for _, e := range exprs {
+		switch {
+		case e == nil:
+		case out == nil:
+			out = e
+		default:
+			out = &Binary{Op: op, Left: out, Right: e}
+		}
+	}
+	return out
+}
+
+// Negate 对表达式取反，nil 保持为 nil
+func Negate(e Expr) Expr {
+	if e == nil {
+		return nil
+	}
+	return &Not{X: e, pos: e.Pos()}
+}
@@ -0,0 +1,254 @@
+package query
+
+import (
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+)
+
+// Match 判断条目是否满足表达式，语义与存储层生成的 SQL 一致：
+// 缺失的字段按零值比较，JSON 路径不存在或类型不符时比较结果为 false
+func Match(expr Expr, e domain.LogEntry) bool {
+	switch x := expr.(type) {
+	case nil:
+		return true
+	case *Binary:
+		if x.Op == OpAnd {
+			return Match(x.Left, e) && Match(x.Right, e)
+		}
+		return Match(x.Left, e) || Match(x.Right, e)
+	case *Not:
+		return !Match(x.X, e)
+	case *Compare:
+		return matchCompare(x, e)
+	}
+	return false
+}
+
+func matchCompare(c *Compare, e domain.LogEntry) bool {
+	switch c.Op {
+	case OpIn:
+		switch values := c.Value.(type) {
+		case []int64:
+			return slices.Contains(values, intColumn(c.Field.Name, e))
+		case []string:
+			return slices.Contains(values, stringColumn(c.Field.Name, e))
+		}
+		return false
+	case OpWithin:
+		// 无法解析的地址不属于任何网段
+		addr, err := netip.ParseAddr(stringColumn(c.Field.Name, e))
+		return err == nil && c.Value.(netip.Prefix).Contains(addr)
+	}
+
+	switch c.Field.Type {
+	case FieldInt:
+		return compareOrdered(intColumn(c.Field.Name, e), c.Value.(int64), c.Op)
+	case FieldTime:
+		t := c.Value.(time.Time)
+		return compareOrdered(e.Timestamp.UnixNano(), t.UnixNano(), c.Op)
+	case FieldString:
+		return compareText(stringColumn(c.Field.Name, e), c.Value.(string), c.Op)
+	case FieldHeader:
+		headers := e.Request.Headers
+		if c.Field.Name == "response_headers" {
+			headers = e.Response.Headers
+		}
+		return compareText(headers[c.Field.Path[0]], c.Value.(string), c.Op)
+	case FieldJSON:
+		body := e.Request.Body
+		if c.Field.Name == "response_body" {
+			body = e.Response.Body
+		}
+		v, ok := lookupJSON(body, c.Field.Path)
+		if c.Op == OpNe {
+			return !ok || !jsonEqual(v, c.Value)
+		}
+		if !ok {
+			return false
+		}
+		switch c.Op {
+		case OpEq:
+			return jsonEqual(v, c.Value)
+		case OpLike:
+			s, isString := v.(string)
+			return isString && Glob(c.Value.(string), s)
+		}
+		n, isNumber := jsonNumber(v)
+		return isNumber && compareOrdered(n, c.Value.(float64), c.Op)
+	}
+	return false
+}
+
+func compareOrdered[T int64 | float64](a, b T, op CompareOp) bool {
+	switch op {
+	case OpEq:
+		return a == b
+	case OpNe:
+		return a != b
+	case OpGt:
+		return a > b
+	case OpGe:
+		return a >= b
+	case OpLt:
+		return a < b
+	case OpLe:
+		return a <= b
+	}
+	return false
+}
+
+func compareText(a, b string, op CompareOp) bool {
+	switch op {
+	case OpEq:
+		return a == b
+	case OpNe:
+		return a != b
+	case OpLike:
+		return Glob(b, a)
+	}
+	return false
+}
+
+func intColumn(name string, e domain.LogEntry) int64 {
+	switch name {
+	case "status_code":
+		return int64(e.Response.StatusCode)
+	case "duration_ms":
+		return e.DurationMs
+	case "request_size":
+		return e.Request.Size
+	case "response_size":
+		return e.Response.Size
+	}
+	return 0
+}
+
+func stringColumn(name string, e domain.LogEntry) string {
+	switch name {
+	case "id":
+		return e.ID
+	case "method":
+		return e.Request.Method
+	case "url":
+		return e.Request.URL
+	case "route":
+		return e.Route
+	case "handler":
+		return e.Handler
+	case "level":
+		return e.Level
+	case "service":
+		return e.Service
+	case "environment":
+		return e.Environment
+	case "kind":
+		return e.Kind
+	case "track_id":
+		return e.TrackID
+	case "trace_id":
+		return e.TraceID
+	case "span_id":
+		return e.SpanID
+	case "parent_span_id":
+		return e.ParentSpanID
+	case "client_ip":
+		return e.ClientIP
+	case "message":
+		return e.Message
+	}
+	return ""
+}
+
+// lookupJSON 按路径读取 JSON 值，数字段可以作为数组下标
+func lookupJSON(v interface{}, path []string) (interface{}, bool) {
+	for _, seg := range path {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			next, ok := x[seg]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, false
+			}
+			v = x[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// jsonNumber 返回 JSON 数字的值，兼容解码后可能出现的各种数值类型
+func jsonNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func jsonEqual(v, want interface{}) bool {
+	switch w := want.(type) {
+	case float64:
+		n, ok := jsonNumber(v)
+		return ok && n == w
+	case nil:
+		return v == nil
+	default:
+		return v == want
+	}
+}
+
+// Glob 不区分大小写地匹配 pattern，* 匹配任意长度的字符
+func Glob(pattern, s string) bool {
+	pattern, s = strings.ToLower(pattern), strings.ToLower(s)
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, last)
+}
+
+// LikePattern 把 Glob 模式转换为 LIKE 模式，% _ \ 使用 \ 转义
+func LikePattern(pattern string) string {
+	var b strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '%', '_', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '*':
+			b.WriteByte('%')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
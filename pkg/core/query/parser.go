@@ -0,0 +1,355 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxDepth 括号和 NOT 的最大嵌套层数
+const MaxDepth = 32
+
+// SyntaxError 查询语法错误，Pos 为出错位置 (从 0 开始的字符偏移)
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("query syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string // 字符串去掉引号并处理转义后的内容
+	pos  int
+}
+
+func (t token) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// lexer 按需切分 token，值的切分规则与字段不同：值中可以包含 : = < > 等字符
+type lexer struct {
+	src []rune
+	pos int
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.src) && unicode.IsSpace(l.src[l.pos]) {
+		l.pos++
+	}
+}
+
+func isOpRune(r rune) bool {
+	return r == ':' || r == '=' || r == '!' || r == '<' || r == '>'
+}
+
+// next 返回下一个 token，value 为 true 时按值的规则切分
+func (l *lexer) next(value bool) (token, error) {
+	l.skipSpace()
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	switch r := l.src[l.pos]; {
+	case r == '(' && !value:
+		l.pos++
+		return token{kind: tokLParen, text: "(", pos: start}, nil
+	case r == ')':
+		l.pos++
+		return token{kind: tokRParen, text: ")", pos: start}, nil
+	case r == '"':
+		return l.quoted()
+	case isOpRune(r) && !value:
+		l.pos++
+		if l.pos < len(l.src) && l.src[l.pos] == '=' && r != ':' && r != '=' {
+			l.pos++
+		}
+		op := string(l.src[start:l.pos])
+		if op == "!" {
+			return token{}, &SyntaxError{Pos: start, Msg: `expected "!=" after "!"`}
+		}
+		return token{kind: tokOp, text: op, pos: start}, nil
+	}
+	for l.pos < len(l.src) {
+		r := l.src[l.pos]
+		if unicode.IsSpace(r) || r == ')' || r == '"' || (!value && (r == '(' || isOpRune(r))) {
+			break
+		}
+		l.pos++
+	}
+	return token{kind: tokWord, text: string(l.src[start:l.pos]), pos: start}, nil
+}
+
+// quoted 读取双引号字符串，支持 \" 和 \\ 转义
+func (l *lexer) quoted() (token, error) {
+	start := l.pos
+	l.pos++
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		r := l.src[l.pos]
+		switch r {
+		case '"':
+			l.pos++
+			return token{kind: tokString, text: b.String(), pos: start}, nil
+		case '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, &SyntaxError{Pos: l.pos, Msg: "unterminated escape sequence"}
+			}
+			l.pos++
+			r = l.src[l.pos]
+		}
+		b.WriteRune(r)
+		l.pos++
+	}
+	return token{}, &SyntaxError{Pos: start, Msg: "unterminated string"}
+}
+
+type parser struct {
+	lex   lexer
+	tok   token // 当前 token，按字段规则切分
+	depth int
+}
+
+// Parse 解析查询字符串，空查询返回 nil
+//
+// 语法：
+//
+//	expr    = and { "OR" and }
+//	and     = unary { "AND" unary }
+//	unary   = "NOT" unary | "(" expr ")" | field op value
+//	op      = ":" | "=" | "!=" | ">" | ">=" | "<" | "<="
+//
+// 关键字不区分大小写；值可以是不含空白的单词或双引号字符串
+func Parse(input string) (Expr, error) {
+	p := &parser{lex: lexer{src: []rune(input)}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokEOF {
+		return nil, nil
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf(p.tok.pos, "expected AND, OR or end of query, found %s", p.tok.describe())
+	}
+	return expr, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next(false)
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(pos int, format string, args ...interface{}) error {
+	return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) keyword(kw string) bool {
+	return p.tok.kind == tokWord && strings.EqualFold(p.tok.text, kw)
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: OpOr, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: OpAnd, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.depth >= MaxDepth {
+		return nil, p.errorf(p.tok.pos, "query nested too deeply")
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+
+	start := p.tok.pos
+	switch {
+	case p.keyword("NOT"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x, pos: start}, nil
+	case p.tok.kind == tokLParen:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf(p.tok.pos, "expected \")\" to close \"(\" at position %d, found %s", start, p.tok.describe())
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (Expr, error) {
+	name := p.tok
+	if name.kind != tokWord || p.keyword("AND") || p.keyword("OR") {
+		return nil, p.errorf(name.pos, "expected field name, found %s", name.describe())
+	}
+	field, ok := lookupField(name.text)
+	if !ok {
+		return nil, p.errorf(name.pos, "unknown field %q", name.text)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokOp {
+		return nil, p.errorf(p.tok.pos, "expected operator after %q, found %s", name.text, p.tok.describe())
+	}
+	opTok := p.tok
+
+	val, err := p.lex.next(true)
+	if err != nil {
+		return nil, err
+	}
+	if val.kind != tokWord && val.kind != tokString || val.kind == tokWord && val.text == "" {
+		return nil, p.errorf(val.pos, "expected value after %q, found %s", opTok.text, val.describe())
+	}
+	c, err := typed(field, CompareOp(opTok.text), opTok.pos, val)
+	if err != nil {
+		return nil, err
+	}
+	c.pos = name.pos
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// typed 按字段类型检查运算符并转换值
+func typed(field Field, op CompareOp, opPos int, val token) (*Compare, error) {
+	c := &Compare{Field: field, Op: op}
+	ordered := op == OpGt || op == OpGe || op == OpLt || op == OpLe
+	fail := func(format string, args ...interface{}) (*Compare, error) {
+		return nil, &SyntaxError{Pos: val.pos, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	switch field.Type {
+	case FieldString, FieldHeader:
+		if ordered {
+			return nil, &SyntaxError{Pos: opPos, Msg: fmt.Sprintf("operator %q is not supported for text field %s", op, field)}
+		}
+		c.Value = val.text
+		if field.Name == "method" {
+			c.Value = strings.ToUpper(val.text)
+		}
+	case FieldInt:
+		n, err := strconv.ParseInt(val.text, 10, 64)
+		if err != nil {
+			return fail("%s expects an integer, found %s", field, val.describe())
+		}
+		c.Value = n
+		if op == OpLike {
+			c.Op = OpEq
+		}
+	case FieldTime:
+		t, err := time.Parse(time.RFC3339Nano, val.text)
+		if err != nil {
+			return fail("%s expects an RFC3339 time, found %s", field, val.describe())
+		}
+		c.Value = t
+		if op == OpLike {
+			c.Op = OpEq
+		}
+	case FieldJSON:
+		c.Value = jsonValue(val)
+		if ordered {
+			if _, ok := c.Value.(float64); !ok {
+				return fail("operator %q expects a number, found %s", op, val.describe())
+			}
+		}
+		if _, ok := c.Value.(string); !ok && op == OpLike {
+			c.Op = OpEq
+		}
+	}
+	return c, nil
+}
+
+// jsonValue 转换 JSON 字段的值：未加引号的数字、true、false、null 按 JSON 类型处理，其余为字符串
+func jsonValue(val token) interface{} {
+	if val.kind == tokString {
+		return val.text
+	}
+	switch val.text {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if f, err := strconv.ParseFloat(val.text, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f
+	}
+	return val.text
+}
@@ -0,0 +1,262 @@
+package query
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/MCCodingMan/TraceBuddy/pkg/core/domain"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"status>=500", "status_code >= 500"},
+		{"method:post", `method : "POST"`},
+		{"status>=500 AND method:POST OR level=error", `((status_code >= 500 AND method : "POST") OR level = "error")`},
+		{"status>=500 and (method:POST or level=error)", `(status_code >= 500 AND (method : "POST" OR level = "error"))`},
+		{"NOT NOT path:/a/*", `NOT NOT url : "/a/*"`},
+		{`message:"hello \"world\""`, `message : "hello \"world\""`},
+		{"status:500", "status_code = 500"},
+		{"req.body.user.ID=42", "request_body.user.ID = 42"},
+		{`req.body.id="42"`, `request_body.id = "42"`},
+		{"req.body.ok:true", "request_body.ok = true"},
+		{"req.header.x-request-id:abc*", `request_headers.X-Request-Id : "abc*"`},
+		{"timestamp>2024-05-01T12:00:00Z", "timestamp > 2024-05-01T12:00:00Z"},
+		{"url:http://a/b?c=1", `url : "http://a/b?c=1"`},
+		{"  ", ""},
+	}
+	for _, tc := range cases {
+		expr, err := Parse(tc.in)
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		got := ""
+		if expr != nil {
+			got = expr.String()
+		}
+		if got != tc.want {
+			t.Errorf("%q: 得到 %s，期望 %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		in  string
+		pos int
+	}{
+		{"status>=", 8},
+		{"status 500", 7},
+		{"foo=1", 0},
+		{"status=abc", 7},
+		{"status>=500 AND", 15},
+		{"status>=500 level=error", 12},
+		{"(status=500", 11},
+		{"status=500)", 10},
+		{`message:"open`, 8},
+		{"method>GET", 6},
+		{"req.body.x>abc", 11},
+		{"timestamp>yesterday", 10},
+		{"status!500", 6},
+		{"状态=1", 0},
+		{"level=error AND 状态=1", 16},
+	}
+	for _, tc := range cases {
+		_, err := Parse(tc.in)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%q: 期望语法错误，得到 %v", tc.in, err)
+			continue
+		}
+		if syntaxErr.Pos != tc.pos {
+			t.Errorf("%q: 位置 %d，期望 %d (%v)", tc.in, syntaxErr.Pos, tc.pos, err)
+		}
+	}
+}
+
+func TestParseDepthLimit(t *testing.T) {
+	in := ""
+	for i := 0; i <= MaxDepth; i++ {
+		in += "("
+	}
+	if _, err := Parse(in + "status=1"); err == nil {
+		t.Error("超过嵌套层数应返回错误")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	e := domain.LogEntry{
+		Timestamp:  time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		DurationMs: 350,
+		Request: domain.RequestInfo{Method: "POST", URL: "/api/orders/1",
+			Body: map[string]interface{}{"user_id": float64(42), "items": []interface{}{map[string]interface{}{"sku": "A-1"}}}},
+		Response: domain.ResponseInfo{StatusCode: 502, Headers: map[string]string{"Content-Type": "application/json"}},
+	}
+	cases := map[string]bool{
+		"status>=500 AND method:POST AND path:/api/orders/* AND duration_ms>300 AND req.body.user_id=42": true,
+		"req.body.items.0.sku:a-*":                        true,
+		"req.body.items.1.sku:a-*":                        false,
+		"req.body.user_id!=42":                            false,
+		"req.body.missing!=42":                            true,
+		"req.body.missing=null":                           false,
+		`req.body.user_id="42"`:                           false,
+		"resp.header.content-type:application/*":          true,
+		"req.header.content-type:*":                       true, // 缺失的头按空字符串处理
+		"req.header.content-type=application/json":        false,
+		"service=orders OR NOT status<500":                true,
+		"timestamp>=2024-05-01T12:00:00Z AND level=error": false,
+	}
+	for in, want := range cases {
+		expr, err := Parse(in)
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+			continue
+		}
+		if got := Match(expr, e); got != want {
+			t.Errorf("%q: 得到 %v，期望 %v", in, got, want)
+		}
+	}
+}
+
+func TestGlobAndLikePattern(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"/api/*", "/API/orders", true},
+		{"*orders*", "/api/orders/1", true},
+		{"a*b*b", "abb", true},
+		{"a*a", "a", false},
+		{"100%", "100%", true},
+		{"exact", "exactly", false},
+	}
+	for _, tc := range cases {
+		if got := Glob(tc.pattern, tc.s); got != tc.want {
+			t.Errorf("Glob(%q, %q) = %v", tc.pattern, tc.s, got)
+		}
+	}
+	if got := LikePattern(`a*_%\`); got != `a%\_\%\\` {
+		t.Errorf("LikePattern: %s", got)
+	}
+}
+
+func TestEquals(t *testing.T) {
+	c, err := Equals("response_body.error.code", "INSUFFICIENT_FUNDS")
+	if err != nil || c.String() != `response_body.error.code = "INSUFFICIENT_FUNDS"` {
+		t.Errorf("得到 %v %v", c, err)
+	}
+	if c, err := Equals("request_headers.x-tenant", "acme"); err != nil || c.Field.Path[0] != "X-Tenant" {
+		t.Errorf("Header 名称应规范化: %v %v", c, err)
+	}
+	if c, err := Equals("req.body.n", 1); err != nil || c.Value != float64(1) {
+		t.Errorf("整数应转换为 float64: %v %v", c, err)
+	}
+	for _, bad := range []struct {
+		field string
+		value interface{}
+	}{
+		{"status", 500},
+		{"request_body", "x"},
+		{"request_body.a", []interface{}{1}},
+		{"request_headers.X-Tenant", true},
+	} {
+		if _, err := Equals(bad.field, bad.value); err == nil {
+			t.Errorf("%s=%v 应返回错误", bad.field, bad.value)
+		}
+	}
+	if And(nil, nil) != nil {
+		t.Error("And 没有表达式时应返回 nil")
+	}
+}
+
+func TestNewCompare(t *testing.T) {
+	e := domain.LogEntry{ClientIP: "10.1.2.3", Request: domain.RequestInfo{Method: "HEAD"}, Response: domain.ResponseInfo{StatusCode: 503}}
+	methods, err := NewCompare("method", OpIn, []string{"get", "head"})
+	if err != nil || methods.String() != `method in ["GET", "HEAD"]` || !Match(methods, e) {
+		t.Errorf("方法列表: %v %v", methods, err)
+	}
+	status, err := NewCompare("status", OpIn, []int64{500, 502})
+	if err != nil || Match(status, e) {
+		t.Errorf("状态码列表: %v %v", status, err)
+	}
+	for ip, want := range map[string]bool{"10.0.0.0/8": true, "10.1.2.3/32": true, "192.168.0.0/16": false, "::/0": false} {
+		c, err := NewCompare("client_ip", OpWithin, netip.MustParsePrefix(ip))
+		if err != nil || Match(c, e) != want {
+			t.Errorf("%s: %v %v", ip, err, Match(c, e))
+		}
+	}
+	if c, _ := NewCompare("client_ip", OpWithin, netip.MustParsePrefix("0.0.0.0/0")); Match(c, domain.LogEntry{ClientIP: "unknown"}) {
+		t.Error("无法解析的地址不应属于任何网段")
+	}
+	for _, bad := range []struct {
+		field string
+		op    CompareOp
+		value interface{}
+	}{
+		{"status", OpIn, []string{"500"}},
+		{"method", OpIn, []string{}},
+		{"service", OpWithin, netip.MustParsePrefix("10.0.0.0/8")},
+		{"client_ip", OpWithin, "10.0.0.0/8"},
+		{"status", OpGe, 500},
+		{"method", OpGt, "GET"},
+		{"req.body.a", OpEq, "x"},
+	} {
+		if _, err := NewCompare(bad.field, bad.op, bad.value); err == nil {
+			t.Errorf("%s %s %v 应返回错误", bad.field, bad.op, bad.value)
+		}
+	}
+}
+
+func TestParseText(t *testing.T) {
+	terms, err := ParseText(`Timeout "card declined" decl* user-42 "a b"* *** `)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TextTerm{
+		{Words: []string{"timeout"}},
+		{Words: []string{"card", "declined"}},
+		{Words: []string{"decl"}, Prefix: true},
+		{Words: []string{"user", "42"}},
+		{Words: []string{"a", "b"}, Prefix: true},
+	}
+	if !reflect.DeepEqual(terms, want) {
+		t.Errorf("得到 %+v", terms)
+	}
+	var syntaxErr *SyntaxError
+	if _, err := ParseText(`ok "open`); !errors.As(err, &syntaxErr) || syntaxErr.Pos != 3 {
+		t.Errorf("未闭合的短语应在位置 3 报错: %v", err)
+	}
+}
+
+func TestMatchTextAndHighlight(t *testing.T) {
+	fields := TextFields("Gateway timeout", "/api/pay", map[string]interface{}{"detail": "Card declined", "code": float64(402)})
+	terms, _ := ParseText(`"card declined" 402`)
+	if score, ok := MatchText(terms, fields); !ok || score != 2 {
+		t.Errorf("应匹配 Body 中的短语和数字: %d %v", score, ok)
+	}
+	terms, _ = ParseText("timeout")
+	if score, _ := MatchText(terms, fields); score != 4 {
+		t.Errorf("消息中的匹配权重应为 4，得到 %d", score)
+	}
+	terms, _ = ParseText("declined gateway")
+	if h := Highlight(terms, fields); h != "<mark>Gateway</mark> timeout" {
+		t.Errorf("摘要: %q", h)
+	}
+
+	long := strings.Repeat("前文 ", 100) + "命中 timeout 之后" + strings.Repeat(" 后文", 100)
+	terms, _ = ParseText("timeout")
+	h := Highlight(terms, []string{long})
+	if !strings.HasPrefix(h, "…") || !strings.HasSuffix(h, "…") || !strings.Contains(h, "<mark>timeout</mark>") {
+		t.Errorf("长文本应截取匹配附近的片段: %q", h)
+	}
+	if n := utf8.RuneCountInString(h); n > snippetRunes+len(HighlightStart)+len(HighlightStop)+2 {
+		t.Errorf("摘要过长: %d", n)
+	}
+}
@@ -0,0 +1,256 @@
+package query
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TextTerm 全文搜索的一个条件：单词或短语，短语中的词必须相邻且按顺序出现
+type TextTerm struct {
+	Words  []string
+	Prefix bool // 最后一个词按前缀匹配
+}
+
+// ParseText 解析全文搜索文本：空白分隔的条件需同时满足，双引号内为短语，以 * 结尾的词按前缀匹配
+// 词按 Tokenize 的规则切分，例如 user-42 等同于短语 "user 42"；不含字母和数字的条件被忽略
+func ParseText(s string) ([]TextTerm, error) {
+	var terms []TextTerm
+	add := func(text string, prefix bool) {
+		if words := Tokenize(text); len(words) > 0 {
+			terms = append(terms, TextTerm{Words: words, Prefix: prefix})
+		}
+	}
+	src := []rune(s)
+	for i := 0; i < len(src); {
+		switch {
+		case unicode.IsSpace(src[i]):
+			i++
+		case src[i] == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				end++
+			}
+			if end == len(src) {
+				return nil, &SyntaxError{Pos: i, Msg: "unterminated phrase"}
+			}
+			prefix := end+1 < len(src) && src[end+1] == '*'
+			add(string(src[i+1:end]), prefix)
+			i = end + 1
+			if prefix {
+				i++
+			}
+		default:
+			end := i
+			for end < len(src) && !unicode.IsSpace(src[end]) && src[end] != '"' {
+				end++
+			}
+			word := string(src[i:end])
+			add(word, strings.HasSuffix(word, "*"))
+			i = end
+		}
+	}
+	return terms, nil
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type span struct {
+	word       string
+	start, end int // 字节偏移
+}
+
+func tokenSpans(s string) []span {
+	var spans []span
+	start := -1
+	for i, r := range s {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			spans = append(spans, span{strings.ToLower(s[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{strings.ToLower(s[start:]), start, len(s)})
+	}
+	return spans
+}
+
+// Tokenize 把文本切分为小写的词，字母和数字以外的字符都是分隔符
+func Tokenize(s string) []string {
+	spans := tokenSpans(s)
+	words := make([]string, len(spans))
+	for i, sp := range spans {
+		words[i] = sp.word
+	}
+	return words
+}
+
+// TextFields 返回全文搜索覆盖的文本：消息、URL 以及请求和响应 Body 中的字符串和数字，对象按键排序
+func TextFields(message, url string, bodies ...interface{}) []string {
+	fields := []string{message, url}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch x := v.(type) {
+		case string:
+			fields = append(fields, x)
+		case float64:
+			fields = append(fields, strconv.FormatFloat(x, 'f', -1, 64))
+		case []interface{}:
+			for _, item := range x {
+				walk(item)
+			}
+		case map[string]interface{}:
+			keys := make([]string, 0, len(x))
+			for k := range x {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(x[k])
+			}
+		}
+	}
+	for _, body := range bodies {
+		walk(body)
+	}
+	return fields
+}
+
+// termHits 返回条件在词序列中出现的起始下标
+func termHits(t TextTerm, words []string) []int {
+	var hits []int
+	n := len(t.Words)
+	for i := 0; i+n <= len(words); i++ {
+		ok := true
+		for j, w := range t.Words {
+			if j == n-1 && t.Prefix {
+				ok = strings.HasPrefix(words[i+j], w)
+			} else {
+				ok = words[i+j] == w
+			}
+			if !ok {
+				break
+			}
+		}
+		if ok {
+			hits = append(hits, i)
+		}
+	}
+	return hits
+}
+
+// fieldWeight 按 TextFields 的顺序给字段加权：消息 4、URL 2、Body 1，与 Postgres 的 A、B、C 权重对应
+func fieldWeight(i int) int {
+	switch i {
+	case 0:
+		return 4
+	case 1:
+		return 2
+	}
+	return 1
+}
+
+// MatchText 判断 fields 是否满足所有条件，短语不跨字段匹配
+// fields 为 TextFields 的结果，score 为各条件出现次数按字段加权的和，用于相关度排序
+func MatchText(terms []TextTerm, fields []string) (score int, ok bool) {
+	if len(terms) == 0 {
+		return 0, true
+	}
+	tokenized := make([][]string, len(fields))
+	for i, f := range fields {
+		tokenized[i] = Tokenize(f)
+	}
+	for _, t := range terms {
+		found := 0
+		for i, words := range tokenized {
+			found += len(termHits(t, words)) * fieldWeight(i)
+		}
+		if found == 0 {
+			return 0, false
+		}
+		score += found
+	}
+	return score, true
+}
+
+// 摘要的标记和长度
+const (
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+	snippetRunes   = 160
+)
+
+// Highlight 在第一个包含匹配的字段中截取摘要，匹配的词用 <mark></mark> 包围，文本不做 HTML 转义
+func Highlight(terms []TextTerm, fields []string) string {
+	for _, f := range fields {
+		spans := tokenSpans(f)
+		words := make([]string, len(spans))
+		for i, sp := range spans {
+			words[i] = sp.word
+		}
+		marked := make([]bool, len(spans))
+		first := -1
+		for _, t := range terms {
+			for _, hit := range termHits(t, words) {
+				for j := range t.Words {
+					marked[hit+j] = true
+				}
+				if first < 0 || hit < first {
+					first = hit
+				}
+			}
+		}
+		if first < 0 {
+			continue
+		}
+		return snippet(f, spans, marked, first)
+	}
+	return ""
+}
+
+// snippet 以第一个匹配为中心截取约 snippetRunes 个字符，并标记匹配的词
+func snippet(f string, spans []span, marked []bool, first int) string {
+	from, to := 0, len(f)
+	if utf8.RuneCountInString(f) > snippetRunes {
+		from = spans[first].start
+		// 匹配前保留约四分之一的上下文
+		for back := snippetRunes / 4; back > 0 && from > 0; back-- {
+			_, size := utf8.DecodeLastRuneInString(f[:from])
+			from -= size
+		}
+		to = from
+		for n := 0; n < snippetRunes && to < len(f); n++ {
+			_, size := utf8.DecodeRuneInString(f[to:])
+			to += size
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for i, sp := range spans {
+		if !marked[i] || sp.start < from || sp.end > to {
+			continue
+		}
+		b.WriteString(f[pos:sp.start])
+		b.WriteString(HighlightStart + f[sp.start:sp.end] + HighlightStop)
+		pos = sp.end
+	}
+	b.WriteString(f[pos:to])
+	if to < len(f) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
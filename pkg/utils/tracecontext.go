@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidTraceparent traceparent 头格式不合法
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// FlagSampled W3C trace-flags 中的采样标记
+const FlagSampled byte = 0x01
+
+// TraceContext W3C Trace Context 中的链路信息
+type TraceContext struct {
+	TraceID      string // 32 位小写十六进制
+	SpanID       string // 16 位小写十六进制，当前 span
+	ParentSpanID string // 上游 span，根 span 为空
+	Flags        byte
+	State        string // tracestate，原样透传
+}
+
+// GenerateTraceID 生成一个新的 32 位十六进制 trace-id
+func GenerateTraceID() string {
+	return randomHex(16)
+}
+
+// GenerateSpanID 生成一个新的 16 位十六进制 span-id
+func GenerateSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	for {
+		_, _ = rand.Read(b)
+		for _, c := range b {
+			if c != 0 {
+				return hex.EncodeToString(b)
+			}
+		}
+	}
+}
+
+// ParseTraceparent 解析 traceparent 头，返回的 SpanID 为上游的 parent-id
+func ParseTraceparent(s string) (TraceContext, error) {
+	s = strings.TrimSpace(s)
+	// version-traceid-parentid-flags，更高版本允许在末尾追加字段
+	if len(s) < 55 || (len(s) > 55 && s[55] != '-') {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	parts := strings.Split(s[:55], "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	for _, p := range parts {
+		if !isLowerHex(p) {
+			return TraceContext{}, ErrInvalidTraceparent
+		}
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(s) != 55) {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	if isZeroHex(parts[1]) || isZeroHex(parts[2]) {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	flags, _ := hex.DecodeString(parts[3])
+	return TraceContext{TraceID: parts[1], SpanID: parts[2], Flags: flags[0]}, nil
+}
+
+// Traceparent 以 version 00 格式输出 traceparent 头
+func (tc TraceContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", tc.TraceID, tc.SpanID, tc.Flags)
+}
+
+// NewChild 创建同一 trace 下的子 span
+func (tc TraceContext) NewChild() TraceContext {
+	return TraceContext{
+		TraceID:      tc.TraceID,
+		SpanID:       GenerateSpanID(),
+		ParentSpanID: tc.SpanID,
+		Flags:        tc.Flags,
+		State:        tc.State,
+	}
+}
+
+// TraceIDFromTrackID 将 UUID 或 32 位十六进制的 Track ID 转换为 trace-id
+func TraceIDFromTrackID(trackID string) (string, bool) {
+	id := strings.ToLower(strings.ReplaceAll(trackID, "-", ""))
+	if len(id) != 32 || !isLowerHex(id) || isZeroHex(id) {
+		return "", false
+	}
+	if len(trackID) != 32 && !IsValidUUID(trackID) {
+		return "", false
+	}
+	return id, true
+}
+
+// TrackIDFromTraceID 将 trace-id 格式化为 UUID 形式的 Track ID，与 TraceIDFromTrackID 互逆
+func TrackIDFromTraceID(traceID string) string {
+	if len(traceID) != 32 {
+		return traceID
+	}
+	return traceID[0:8] + "-" + traceID[8:12] + "-" + traceID[12:16] + "-" + traceID[16:20] + "-" + traceID[20:]
+}
+
+// IsValidTraceID 检查是否为非全零的 32 位小写十六进制 trace-id
+func IsValidTraceID(s string) bool {
+	return len(s) == 32 && isLowerHex(s) && !isZeroHex(s)
+}
+
+// IsValidSpanID 检查是否为非全零的 16 位小写十六进制 span-id
+func IsValidSpanID(s string) bool {
+	return len(s) == 16 && isLowerHex(s) && !isZeroHex(s)
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZeroHex(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+type traceContextKey struct{}
+
+type tracedRequest struct {
+	trackID string
+	tc      TraceContext
+}
+
+// ContextWithTrace 将当前请求的 Track ID 和 span 写入 context，供出站调用继续传播
+func ContextWithTrace(ctx context.Context, trackID string, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tracedRequest{trackID: trackID, tc: tc})
+}
+
+// TraceFromContext 读取 ContextWithTrace 写入的链路信息
+func TraceFromContext(ctx context.Context) (string, TraceContext, bool) {
+	v, ok := ctx.Value(traceContextKey{}).(tracedRequest)
+	return v.trackID, v.tc, ok
+}
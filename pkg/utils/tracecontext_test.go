@@ -0,0 +1,47 @@
+package utils
+
+import "testing"
+
+func TestParseTraceparent(t *testing.T) {
+	tc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatalf("期望解析成功，得到 %v", err)
+	}
+	if tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tc.SpanID != "00f067aa0ba902b7" || tc.Flags != FlagSampled {
+		t.Errorf("解析结果错误: %+v", tc)
+	}
+	if tc.Traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("格式化结果错误: %s", tc.Traceparent())
+	}
+
+	invalid := []string{
+		"",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	}
+	for _, s := range invalid {
+		if _, err := ParseTraceparent(s); err == nil {
+			t.Errorf("期望 %q 解析失败", s)
+		}
+	}
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); err != nil {
+		t.Errorf("更高版本应允许追加字段: %v", err)
+	}
+}
+
+func TestTrackIDTraceIDRoundTrip(t *testing.T) {
+	trackID := GenerateTrackID()
+	traceID, ok := TraceIDFromTrackID(trackID)
+	if !ok || len(traceID) != 32 {
+		t.Fatalf("UUID 应可转换为 trace-id，得到 %q", traceID)
+	}
+	if TrackIDFromTraceID(traceID) != trackID {
+		t.Errorf("期望换算回 %s，得到 %s", trackID, TrackIDFromTraceID(traceID))
+	}
+	if _, ok := TraceIDFromTrackID("order-123"); ok {
+		t.Error("非 UUID 的 Track ID 不应转换")
+	}
+}
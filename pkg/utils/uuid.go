@@ -9,6 +9,28 @@ func GenerateTrackID() string {
 	return uuid.New().String()
 }
 
+// GenerateEntryID 生成日志条目的唯一 ID (UUID v7，按时间递增，利于索引写入)
+func GenerateEntryID() string {
+	if id, err := uuid.NewV7(); err == nil {
+		return id.String()
+	}
+	return uuid.New().String()
+}
+
+// entryIDNamespace 派生远程上报条目 ID 所用的 UUID v5 命名空间
+var entryIDNamespace = uuid.MustParse("8cff7141-cad4-4bb9-b35c-af6bbcc9dca2")
+
+// NamespacedEntryID 在 namespace (如采集端的 API Key) 下由客户端提供的 UUID 派生条目 ID (UUID v5)
+// 同一命名空间下相同的 id 总是得到相同结果，不同命名空间互不冲突
+func NamespacedEntryID(namespace, id string) (string, error) {
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return "", err
+	}
+	ns := uuid.NewSHA1(entryIDNamespace, []byte(namespace))
+	return uuid.NewSHA1(ns, u[:]).String(), nil
+}
+
 // IsValidUUID 检查字符串是否为有效的 UUID
 func IsValidUUID(u string) bool {
 	_, err := uuid.Parse(u)
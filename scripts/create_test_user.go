@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/MCCodingMan/TraceBuddy/pkg/adapters/storage"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -22,17 +24,13 @@ func main() {
         log.Fatalf("Error pinging database: %v", err)
     }
 
-    _, err = db.Exec(`
-        CREATE TABLE IF NOT EXISTS users (
-            username TEXT PRIMARY KEY,
-            password_hash TEXT NOT NULL,
-            role TEXT NOT NULL,
-            created_at TIMESTAMPTZ NOT NULL,
-            updated_at TIMESTAMPTZ NOT NULL
-        );
-    `)
+    // 表结构由服务端的迁移统一管理
+    migrator, err := storage.NewMigrator(db, "pgx")
     if err != nil {
-        log.Fatalf("Error ensuring schema: %v", err)
+        log.Fatalf("Error loading migrations: %v", err)
+    }
+    if _, err := migrator.Up(context.Background()); err != nil {
+        log.Fatalf("Error migrating schema: %v", err)
     }
     
     password := "admin123"